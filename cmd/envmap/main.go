@@ -0,0 +1,84 @@
+// Command envmap inspects Go source code using the tools in envmap.
+//
+// Usage:
+//
+//	envmap query SELECTOR [FILE or DIRECTORY...]
+//
+// query prints the declarations matching the given selector (see
+// "pkg/query"), along with their positions.  Source is read from
+// standard input if no files or directories are given.  It exits
+// with status 1 if nothing matches, and 2 on errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/directxman12/envmap/pkg/convert"
+	"github.com/directxman12/envmap/pkg/loader"
+	"github.com/directxman12/envmap/pkg/query"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: envmap query SELECTOR [FILE or DIRECTORY...]\n")
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "query":
+		os.Exit(runQuery(flag.Args()[1:]))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		usage()
+	}
+}
+
+// runQuery runs the query command, returning the exit status.
+func runQuery(args []string) int {
+	if len(args) < 1 {
+		usage()
+	}
+	sel, err := query.Compile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	l, errs := loader.FromArgs(args[1:])
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		return 2
+	}
+
+	// files are loaded concurrently, so put them back in order
+	fset := l.FileSet()
+	files := l.Files()
+	sort.Slice(files, func(i, j int) bool {
+		return fset.Position(files[i].Package).Filename < fset.Position(files[j].Package).Filename
+	})
+
+	var matches []*query.Match
+	for _, file := range files {
+		matches = append(matches, sel.Select(convert.FromRawWithFileSet(fset, file))...)
+	}
+	query.SortByPosition(matches)
+	for _, match := range matches {
+		fmt.Printf("%s: %s %s\n", match.Position(), match.Kind, match.Path())
+	}
+
+	if len(matches) == 0 {
+		return 1
+	}
+	return 0
+}
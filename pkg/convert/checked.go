@@ -0,0 +1,250 @@
+package convert
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// FromRawChecked is like FromRaw, but checks that every declaration in the
+// file can be fully converted ahead of time, instead of panicking later on.
+// Declarations containing malformed or unsupported nodes (e.g. ast.BadExpr
+// from a file with syntax errors) are reported as *ConversionErrors,
+// positioned using the given FileSet (which may be nil), and are left out
+// of the resulting AST.  The Positions of the resulting nodes are also
+// resolved using the FileSet.
+func FromRawChecked(fset *token.FileSet, raw *ast.File) (AST, []error) {
+	res := &astImpl{
+		file: raw,
+		scope: newFileScope(fset, raw),
+		skip: make(map[ast.Node]bool),
+	}
+
+	var errs []error
+	check := func(key ast.Node, decl Declaration) {
+		if err := checkDeclaration(decl); err != nil {
+			if fset != nil && err.Node != nil {
+				err.Position = fset.Position(err.Node.Pos())
+			}
+			errs = append(errs, err)
+			res.skip[key] = true
+		}
+	}
+
+	for _, decl := range raw.Decls {
+		if badDecl, isBad := decl.(*ast.BadDecl); isBad {
+			err := conversionError(badDecl, "malformed declaration")
+			if fset != nil {
+				err.Position = fset.Position(badDecl.Pos())
+			}
+			errs = append(errs, err)
+		}
+	}
+	for _, decl := range res.Types() {
+		check(decl.(*typeDeclaration).spec.Name, decl)
+	}
+	for _, decl := range res.Values() {
+		check(decl.(*valueDeclaration).name, decl)
+	}
+	for _, decl := range res.Funcs() {
+		check(decl.(*funcDeclaration).decl, decl)
+	}
+
+	return res, errs
+}
+
+// checkDeclaration converts everything reachable from the given
+// declaration, returning the first ConversionError encountered.
+func checkDeclaration(decl Declaration) (err *ConversionError) {
+	defer func() {
+		if r := recover(); r != nil {
+			convErr, isConvErr := r.(*ConversionError)
+			if !isConvErr {
+				panic(r)
+			}
+			err = convErr
+		}
+	}()
+
+	checkNode(decl)
+	return nil
+}
+
+// checkNode recursively converts the given node and all of its children.
+func checkNode(node interface{}) {
+	for _, child := range children(node) {
+		checkNode(child)
+	}
+}
+
+// children returns the direct children of any of the convert types,
+// converting them from their raw forms as necessary.  Nil children
+// are omitted.
+func children(node interface{}) []interface{} {
+	var res []interface{}
+	add := func(children ...interface{}) {
+		for _, child := range children {
+			if child == nil {
+				continue
+			}
+			res = append(res, child)
+		}
+	}
+	addFields := func(fields []Field) {
+		for _, field := range fields {
+			add(field)
+		}
+	}
+	addExprs := func(exprs []Expression) {
+		for _, expr := range exprs {
+			add(expr)
+		}
+	}
+	addStmts := func(stmts []Statement) {
+		for _, stmt := range stmts {
+			add(stmt)
+		}
+	}
+	addTypes := func(types []TypeDefinition) {
+		for _, typ := range types {
+			add(typ)
+		}
+	}
+
+	switch typed := node.(type) {
+	// declarations
+	case TypeDeclaration:
+		addFields(typed.TypeParams())
+		add(typed.Type())
+	case ValueDeclaration:
+		add(typed.Type())
+		add(typed.Value())
+	case FuncDeclaration:
+		_, recvType := typed.Receiver()
+		add(recvType, typed.Type(), typed.Body())
+
+	// type definitions
+	case Field:
+		add(typed.Type())
+	case StructTypeDefinition:
+		addFields(typed.Fields())
+	case InterfaceTypeDefinition:
+		addFields(typed.Elements())
+	case FuncTypeDefinition:
+		addFields(typed.TypeParams())
+		addFields(typed.Params())
+		addFields(typed.Results())
+	case MapTypeDefinition:
+		add(typed.KeyType(), typed.ValueType())
+	case ArrayTypeDefinition:
+		add(typed.Length())
+		add(typed.ElemType())
+	case ArrayLength:
+		add(typed.Expr())
+	case SplatTypeDefinition:
+		add(typed.ElemType())
+	case ChanTypeDefinition:
+		add(typed.ValueType())
+	case PointerTypeDefinition:
+		add(typed.ReferentType())
+	case InstantiatedTypeDefinition:
+		add(typed.GenericType())
+		addTypes(typed.TypeArgs())
+	case UnionTypeDefinition:
+		addTypes(typed.Terms())
+	case TildeTypeDefinition:
+		add(typed.Approximates())
+
+	// expressions
+	case CompositeLiteral:
+		add(typed.LiteralType())
+		addExprs(typed.Elements())
+	case KeyValueExpression:
+		add(typed.Key(), typed.Value())
+	case FuncLiteral:
+		add(typed.Type(), typed.Body())
+	case CallExpression:
+		add(typed.Func())
+		addExprs(typed.Args())
+	case ConversionExpression:
+		add(typed.TargetType(), typed.Operand())
+	case SelectorExpression:
+		add(typed.Operand())
+	case IndexExpression:
+		add(typed.Operand())
+		addExprs(typed.Indices())
+	case SliceExpression:
+		add(typed.Operand())
+		add(typed.Low(), typed.High(), typed.Max())
+	case TypeAssertExpression:
+		add(typed.Operand())
+		add(typed.AssertedType())
+	case UnaryExpression:
+		add(typed.Operand())
+	case BinaryExpression:
+		add(typed.Left(), typed.Right())
+	case ParenExpression:
+		add(typed.Inner())
+
+	// statements
+	case ExpressionStatement:
+		add(typed.Expression())
+	case AssignStatement:
+		addExprs(typed.Left())
+		addExprs(typed.Right())
+	case IncDecStatement:
+		add(typed.Target())
+	case SendStatement:
+		add(typed.Channel(), typed.SentValue())
+	case DeclStatement:
+		for _, decl := range typed.Declarations() {
+			add(decl)
+		}
+	case IfStatement:
+		add(typed.Init())
+		add(typed.Cond(), typed.Body())
+		add(typed.ElseBranch())
+	case ForStatement:
+		add(typed.Init())
+		add(typed.Cond())
+		add(typed.Post())
+		add(typed.Body())
+	case RangeStatement:
+		add(typed.Key(), typed.Value())
+		add(typed.Range(), typed.Body())
+	case SwitchStatement:
+		add(typed.Init())
+		add(typed.Tag())
+		// parsed clauses implement both CaseClause and TypeCaseClause,
+		// so their children are added here, where their kind is known
+		for _, clause := range typed.Clauses() {
+			addExprs(clause.Values())
+			addStmts(clause.Statements())
+		}
+	case TypeSwitchStatement:
+		add(typed.Init())
+		add(typed.Subject())
+		for _, clause := range typed.Clauses() {
+			addTypes(clause.Types())
+			addStmts(clause.Statements())
+		}
+	case SelectStatement:
+		for _, clause := range typed.Clauses() {
+			add(clause)
+		}
+	case CommClause:
+		add(typed.Comm())
+		addStmts(typed.Statements())
+	case BlockStatement:
+		addStmts(typed.Statements())
+	case ReturnStatement:
+		addExprs(typed.Results())
+	case DeferStatement:
+		add(typed.Call())
+	case GoStatement:
+		add(typed.Call())
+	case LabeledStatement:
+		add(typed.Statement())
+	}
+
+	return res
+}
@@ -0,0 +1,43 @@
+package convert
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+const switchSrc = `package x
+
+func f(x interface{}) {
+	switch v := x.(type) {
+	case *int, []string:
+		_ = v
+	}
+	switch x {
+	case 1:
+	}
+}
+`
+
+func TestChildrenOfSwitchClauses(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", switchSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts := FromRawWithFileSet(fset, file).Funcs()[0].Body().Statements()
+
+	// type switch cases are types, not expressions like `*int`
+	typeSwitchChildren := children(stmts[0])
+	if _, isPtr := typeSwitchChildren[1].(PointerTypeDefinition); !isPtr {
+		t.Errorf("expected the first case of the type switch to be a pointer type, got %T", typeSwitchChildren[1])
+	}
+	if _, isSlice := typeSwitchChildren[2].(ArrayTypeDefinition); !isSlice {
+		t.Errorf("expected the second case of the type switch to be a slice type, got %T", typeSwitchChildren[2])
+	}
+
+	switchChildren := children(stmts[1])
+	if _, isLit := switchChildren[1].(BasicLiteral); !isLit {
+		t.Errorf("expected the case of the expression switch to be a literal, got %T", switchChildren[1])
+	}
+}
@@ -0,0 +1,312 @@
+package convert
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+)
+
+// constEntry is a single named constant, with implicit
+// repetition of the previous type and expression resolved.
+type constEntry struct {
+	// scope is the file that declares the constant, which
+	// its expression is evaluated against
+	scope *fileScope
+	spec *ast.ValueSpec
+	name *ast.Ident
+	iota int
+	typ ast.Expr
+	expr ast.Expr
+
+	evaluating, evaluated bool
+	value constant.Value
+}
+
+// constGroupEntries splits a const declaration into individual
+// constants, filling in the type and value of specs that omit
+// them from the last spec that didn't.
+func constGroupEntries(decl *ast.GenDecl) []*constEntry {
+	var res []*constEntry
+	var lastType ast.Expr
+	var lastValues []ast.Expr
+	for iota, specRaw := range decl.Specs {
+		spec := specRaw.(*ast.ValueSpec)
+		if len(spec.Values) > 0 {
+			lastType, lastValues = spec.Type, spec.Values
+		}
+
+		for i, name := range spec.Names {
+			entry := &constEntry{
+				spec: spec,
+				name: name,
+				iota: iota,
+				typ: lastType,
+			}
+			if i < len(lastValues) {
+				entry.expr = lastValues[i]
+			}
+			res = append(res, entry)
+		}
+	}
+	return res
+}
+
+// EvalConstExpr evaluates a self-contained constant expression, such as
+// `1 << 10`.  References to named constants cannot be resolved, so
+// expressions containing them evaluate to nil, as do expressions that
+// aren't constant.
+func EvalConstExpr(expr Expression) constant.Value {
+	var s *fileScope
+	return s.evalConstExpr(expr)
+}
+
+// EvalIotaConstExpr is like EvalConstExpr, but evaluates the expression as
+// the value of a constant at the given position (iota) in a const group.
+func EvalIotaConstExpr(expr Expression, iota int) constant.Value {
+	var s *fileScope
+	return s.evalConst(expr, iota)
+}
+
+// evalConstExpr evaluates a constant expression found outside of a const
+// declaration (so `iota` is not valid), resolving references to constants
+// in this file.  It's safe to call on a nil scope.
+func (s *fileScope) evalConstExpr(expr Expression) constant.Value {
+	if s != nil {
+		s.evalMu.Lock()
+		defer s.evalMu.Unlock()
+	}
+	return s.evalConst(expr, -1)
+}
+
+// evalConstEntry evaluates the given constant, caching the result.
+func (s *fileScope) evalConstEntry(entry *constEntry) constant.Value {
+	s.evalMu.Lock()
+	defer s.evalMu.Unlock()
+	return s.constValue(entry)
+}
+
+// constValue evaluates the given constant, caching the result.
+// s.evalMu must be held.
+func (s *fileScope) constValue(entry *constEntry) constant.Value {
+	if entry.evaluated {
+		return entry.value
+	}
+	if entry.evaluating {
+		// invalid cyclic definition
+		return nil
+	}
+
+	// the constant may have been declared in another file in the package
+	if entry.scope != nil {
+		s = entry.scope
+	}
+
+	entry.evaluating = true
+	entry.value = s.evalConst(exprToExpression(s, entry.expr), entry.iota)
+	if entry.typ != nil {
+		entry.value = s.convertConst(entry.value, exprToTypeDefinition(s, entry.typ))
+	}
+	entry.evaluating = false
+	entry.evaluated = true
+
+	return entry.value
+}
+
+// evalConst evaluates a constant expression, returning nil if the
+// expression is not constant, is invalid, or references things that cannot
+// be resolved (such as constants in other packages).  iota should be -1
+// when not evaluating an expression in a const declaration.
+func (s *fileScope) evalConst(expr Expression, iota int) constant.Value {
+	switch typed := expr.(type) {
+	case BasicLiteral:
+		return knownOrNil(constant.MakeFromLiteral(typed.Literal(), typed.Kind(), 0))
+	case QualifiedIdent:
+		// we can't see constants from other packages
+		return nil
+	case Ident:
+		if entry := s.lookupConst(typed.Name()); entry != nil {
+			return s.constValue(entry)
+		}
+		switch typed.Name() {
+		case "true":
+			return constant.MakeBool(true)
+		case "false":
+			return constant.MakeBool(false)
+		case "iota":
+			if iota >= 0 {
+				return constant.MakeInt64(int64(iota))
+			}
+		}
+		return nil
+	case ParenExpression:
+		return s.evalConst(typed.Inner(), iota)
+	case UnaryExpression:
+		operand := s.evalConst(typed.Operand(), iota)
+		if operand == nil {
+			return nil
+		}
+		return safeConstOp(func() constant.Value {
+			return constant.UnaryOp(typed.Op(), operand, 0)
+		})
+	case BinaryExpression:
+		left, right := s.evalConst(typed.Left(), iota), s.evalConst(typed.Right(), iota)
+		if left == nil || right == nil {
+			return nil
+		}
+		return safeConstOp(func() constant.Value {
+			return binaryConstOp(left, typed.Op(), right)
+		})
+	case ConversionExpression:
+		return s.convertConst(s.evalConst(typed.Operand(), iota), typed.TargetType())
+	case CallExpression:
+		return s.evalConstCall(typed, iota)
+	default:
+		return nil
+	}
+}
+
+// evalConstCall evaluates calls to builtins that may
+// appear in constant expressions.
+func (s *fileScope) evalConstCall(call CallExpression, iota int) constant.Value {
+	fun, isIdent := call.Func().(Ident)
+	if _, isQualified := fun.(QualifiedIdent); !isIdent || isQualified || call.HasEllipsis() {
+		return nil
+	}
+
+	rawArgs := call.Args()
+	args := make([]constant.Value, len(rawArgs))
+	for i, arg := range rawArgs {
+		args[i] = s.evalConst(arg, iota)
+		if args[i] == nil {
+			return nil
+		}
+	}
+
+	switch name := fun.Name(); {
+	case name == "len" && len(args) == 1 && args[0].Kind() == constant.String:
+		return constant.MakeInt64(int64(len(constant.StringVal(args[0]))))
+	case name == "real" && len(args) == 1:
+		return knownOrNil(constant.Real(args[0]))
+	case name == "imag" && len(args) == 1:
+		return knownOrNil(constant.Imag(args[0]))
+	case name == "complex" && len(args) == 2:
+		return safeConstOp(func() constant.Value {
+			return constant.BinaryOp(args[0], token.ADD, constant.MakeImag(args[1]))
+		})
+	case (name == "min" || name == "max") && len(args) > 0:
+		op := token.LSS
+		if name == "max" {
+			op = token.GTR
+		}
+		return safeConstOp(func() constant.Value {
+			res := args[0]
+			for _, arg := range args[1:] {
+				if constant.Compare(arg, op, res) {
+					res = arg
+				}
+			}
+			return res
+		})
+	default:
+		return nil
+	}
+}
+
+// convertConst converts a constant value to the given type, if the type is
+// a basic type (or a named type in this file based on one).  Other types leave
+// the value unchanged.
+func (s *fileScope) convertConst(val constant.Value, typ TypeDefinition) constant.Value {
+	if val == nil || typ == nil {
+		return val
+	}
+
+	switch s.basicTypeName(typ) {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64",
+		"uintptr", "byte", "rune":
+		return knownOrNil(constant.ToInt(val))
+	case "float32", "float64":
+		return knownOrNil(constant.ToFloat(val))
+	case "complex64", "complex128":
+		return knownOrNil(constant.ToComplex(val))
+	case "string":
+		if val.Kind() == constant.Int {
+			// string(rune)
+			codepoint, exact := constant.Int64Val(val)
+			if !exact {
+				return nil
+			}
+			return constant.MakeString(string(rune(codepoint)))
+		}
+		return val
+	default:
+		return val
+	}
+}
+
+// basicTypeName returns the name of the predeclared type that the given
+// type is, or is defined in this package in terms of, or the empty
+// string if it can't be determined.
+func (s *fileScope) basicTypeName(typ TypeDefinition) string {
+	// bound the number of lookups, in case of (invalid) cycles
+	for depth := 0; depth < 16; depth++ {
+		switch typed := typ.(type) {
+		case QualifiedIdent:
+			return ""
+		case Ident:
+			entry := s.lookupType(typed.Name())
+			if entry == nil {
+				return typed.Name()
+			}
+			s = entry.scope
+			typ = exprToTypeDefinition(s, entry.spec.Type)
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// binaryConstOp performs a binary operation, accounting for
+// the differences between Go syntax and the constant package's
+// operations (for shifts, comparisons, and integer division).
+func binaryConstOp(left constant.Value, op token.Token, right constant.Value) constant.Value {
+	switch op {
+	case token.SHL, token.SHR:
+		shift, exact := constant.Uint64Val(constant.ToInt(right))
+		if !exact {
+			return nil
+		}
+		return constant.Shift(left, op, uint(shift))
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return constant.MakeBool(constant.Compare(left, op, right))
+	case token.QUO, token.REM:
+		if constant.Sign(right) == 0 {
+			// division by zero
+			return nil
+		}
+		if op == token.QUO && left.Kind() == constant.Int && right.Kind() == constant.Int {
+			op = token.QUO_ASSIGN // integer division
+		}
+	}
+	return constant.BinaryOp(left, op, right)
+}
+
+// safeConstOp runs the given constant operation, returning nil if it
+// panics (the constant package panics on mismatched or invalid operands).
+func safeConstOp(op func() constant.Value) (res constant.Value) {
+	defer func() {
+		if recover() != nil {
+			res = nil
+		}
+	}()
+	return knownOrNil(op())
+}
+
+// knownOrNil converts unknown values to nil.
+func knownOrNil(val constant.Value) constant.Value {
+	if val == nil || val.Kind() == constant.Unknown {
+		return nil
+	}
+	return val
+}
@@ -1,6 +1,9 @@
 package convert
 
 import (
+	"strconv"
+	"sync"
+
 	"go/ast"
 	"go/token"
 )
@@ -9,94 +12,161 @@ import (
 // making them easily traversable in
 // familiar forms.
 // 
-// Note that the interfaces provided by AST "lose" some
-// structural form from the original AST by breaking up grouped
-// declarations into individual ones. This makes it more similar
-// to reflection, but means code looking for groups of declarations
-// is harder to write.  Docs from the overall declaration are merged
-// with docs from individual ones.
+// Note that the interfaces provided by AST break up grouped
+// declarations into individual ones, making them more similar
+// to reflection.  The groups themselves are available from
+// Groups, and carry the docs from the overall declaration.
 type astImpl struct {
 	file *ast.File
+	scope *fileScope
+
+	// decls holds the wrappers for each declaration, in source order,
+	// so that the same values are returned from Types, Values, and Groups.
+	declsOnce sync.Once
+	decls []Declaration
+	groups []*declGroup
+
+	// skip contains the declarations (identified by their
+	// name or func decl) that failed checking in FromRawChecked
+	skip map[ast.Node]bool
 }
 
+// FromRaw wraps the given file.  Since the file's FileSet isn't known,
+// the Positions of the resulting nodes will be invalid.
 func FromRaw(raw *ast.File) AST {
+	return FromRawWithFileSet(nil, raw)
+}
+
+// FromRawWithFileSet wraps the given file, resolving the Positions of the
+// resulting nodes against the given FileSet (e.g. from loader.Loader).
+func FromRawWithFileSet(fset *token.FileSet, raw *ast.File) AST {
 	return &astImpl{
 		file: raw,
+		scope: newFileScope(fset, raw),
 	}
 }
 
-// TODO: do iota generations and var lists with skipped types have values/types in the AST?
-
-// TODO: make this more like a visitor to avoid extra allocations
+// declVisitor receives each top-level declaration from eachDecl.
+// Any of the callbacks may be nil.
+type declVisitor struct {
+	typeDecl func(*typeDeclaration)
+	funcDecl func(*funcDeclaration)
+	valueDecl func(*valueDeclaration)
+}
 
-// Types fetches all types defined in this AST
-func (a *astImpl) Types() []TypeDeclaration {
-	var res []TypeDeclaration
+// index wraps each declaration, splitting up GenDecls and
+// recording the parenthesized groups.
+func (a *astImpl) index() {
+	a.declsOnce.Do(func() {
+		for _, decl := range a.file.Decls {
+			switch typedDecl := decl.(type) {
+			case *ast.FuncDecl:
+				a.decls = append(a.decls, &funcDeclaration{
+					scope: a.scope,
+					decl: typedDecl,
+				})
+			case *ast.GenDecl:
+				if typedDecl.Tok == token.IMPORT {
+					continue
+				}
+				var group *declGroup
+				if typedDecl.Lparen.IsValid() {
+					group = &declGroup{ast: a, decl: typedDecl}
+					a.groups = append(a.groups, group)
+				}
+				add := func(d Declaration) {
+					a.decls = append(a.decls, d)
+					if group != nil {
+						group.decls = append(group.decls, d)
+					}
+				}
 
-	for _, decl := range a.file.Decls {
-		// skip non-type declarations
-		genDecl, isGenDecl := decl.(*ast.GenDecl)
-		if !isGenDecl {
-			continue
+				if typedDecl.Tok == token.TYPE {
+					eachTypeDeclaration(a.scope, typedDecl, func(d *typeDeclaration) { add(d) })
+				} else {
+					eachValueDeclaration(a.scope, typedDecl, func(d *valueDeclaration) { add(d) })
+				}
+			}
 		}
-		if genDecl.Tok != token.TYPE {
+	})
+}
+
+// skipped checks if the given declaration failed checking in FromRawChecked.
+func (a *astImpl) skipped(decl Declaration) bool {
+	switch typedDecl := decl.(type) {
+	case *typeDeclaration:
+		return a.skip[typedDecl.spec.Name]
+	case *valueDeclaration:
+		return a.skip[typedDecl.name]
+	case *funcDeclaration:
+		return a.skip[typedDecl.decl]
+	}
+	return false
+}
+
+// eachDecl calls the matching callback for each declaration,
+// in source order, that wasn't skipped by FromRawChecked.
+func (a *astImpl) eachDecl(v declVisitor) {
+	a.index()
+	for _, decl := range a.decls {
+		if a.skipped(decl) {
 			continue
 		}
-
-		for _, spec := range genDecl.Specs {
-			res = append(res, &typeDeclaration{
-				decl: genDecl,
-				spec: spec.(*ast.TypeSpec),
-			})
+		switch typedDecl := decl.(type) {
+		case *typeDeclaration:
+			if v.typeDecl != nil {
+				v.typeDecl(typedDecl)
+			}
+		case *valueDeclaration:
+			if v.valueDecl != nil {
+				v.valueDecl(typedDecl)
+			}
+		case *funcDeclaration:
+			if v.funcDecl != nil {
+				v.funcDecl(typedDecl)
+			}
 		}
 	}
+}
+
+// Types fetches all types defined in this AST
+func (a *astImpl) Types() []TypeDeclaration {
+	var res []TypeDeclaration
+	a.eachDecl(declVisitor{
+		typeDecl: func(d *typeDeclaration) {
+			res = append(res, d)
+		},
+	})
 	return res
 }
 
 func (a *astImpl) Funcs() []FuncDeclaration {
 	var res []FuncDeclaration
-
-	for _, decl := range a.file.Decls {
-		// skip non-type declarations
-		funcDecl, isFuncDecl := decl.(*ast.FuncDecl)
-		if !isFuncDecl {
-			continue
-		}
-		res = append(res, &funcDeclaration{
-			decl: funcDecl,
-		})
-	}
+	a.eachDecl(declVisitor{
+		funcDecl: func(d *funcDeclaration) {
+			res = append(res, d)
+		},
+	})
 	return res
 }
 
 func (a *astImpl) Values() []ValueDeclaration {
 	var res []ValueDeclaration
+	a.eachDecl(declVisitor{
+		valueDecl: func(d *valueDeclaration) {
+			res = append(res, d)
+		},
+	})
+	return res
+}
 
-	for _, decl := range a.file.Decls {
-		// skip non-type declarations
-		genDecl, isGenDecl := decl.(*ast.GenDecl)
-		if !isGenDecl {
-			continue
-		}
-		if genDecl.Tok != token.VAR && genDecl.Tok != token.CONST {
-			continue
-		}
-
-		for _, specRaw := range genDecl.Specs {
-			spec := specRaw.(*ast.ValueSpec)
-
-			for i, name := range spec.Names {
-				var val ast.Expr
-				if spec.Values != nil {
-					val = spec.Values[i]
-				}
-				res = append(res, &valueDeclaration{
-					decl: genDecl,
-					spec: spec, 
-					name: name,
-					value: val,
-				})
-			}
+// Groups fetches all parenthesized declaration groups in this AST
+func (a *astImpl) Groups() []DeclGroup {
+	a.index()
+	var res []DeclGroup
+	for _, group := range a.groups {
+		if len(group.Declarations()) > 0 {
+			res = append(res, group)
 		}
 	}
 	return res
@@ -114,6 +184,7 @@ func (a *astImpl) Imports() []Import {
 	res := make([]Import, len(a.file.Imports))
 	for i, spec := range a.file.Imports {
 		res[i] = &importSpec{
+			scope: a.scope,
 			spec: spec,
 		}
 	}
@@ -121,6 +192,7 @@ func (a *astImpl) Imports() []Import {
 }
 
 type importSpec struct {
+	scope *fileScope
 	spec *ast.ImportSpec
 }
 
@@ -128,6 +200,10 @@ func (s *importSpec) Doc() []string {
 	return extractCommentGroup(s.spec.Doc)
 }
 
+func (s *importSpec) LineComment() []string {
+	return extractCommentGroup(s.spec.Comment)
+}
+
 func (s *importSpec) Name() Ident {
 	if s.spec.Name == nil {
 		return nil
@@ -138,3 +214,12 @@ func (s *importSpec) Name() Ident {
 func (s *importSpec) Path() string {
 	return s.spec.Path.Value
 }
+
+func (s *importSpec) ImportPath() string {
+	importPath, err := strconv.Unquote(s.spec.Path.Value)
+	if err != nil {
+		// malformed literals are left as-is
+		return s.spec.Path.Value
+	}
+	return importPath
+}
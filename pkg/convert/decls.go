@@ -2,6 +2,7 @@ package convert
 
 import (
 	"go/ast"
+	"go/constant"
 	"go/token"
 )
 
@@ -31,7 +32,116 @@ func extractCommentGroup(cg *ast.CommentGroup) []string {
 	return res
 }
 
+// specDoc returns the docs for a single spec in a declaration.  Docs
+// on a parenthesized group are left to the group, while docs on an
+// ungrouped declaration are merged with those on the spec.
+func specDoc(decl *ast.GenDecl, doc *ast.CommentGroup) []string {
+	if decl.Lparen.IsValid() {
+		return extractCommentGroup(doc)
+	}
+	return append(extractCommentGroup(decl.Doc), extractCommentGroup(doc)...)
+}
+
+// declGroup is a parenthesized GenDecl.
+type declGroup struct {
+	ast *astImpl
+	decl *ast.GenDecl
+	decls []Declaration
+}
+
+func (g *declGroup) Kind() token.Token {
+	return g.decl.Tok
+}
+
+func (g *declGroup) Declarations() []Declaration {
+	if g.ast.skip == nil {
+		return g.decls
+	}
+	var res []Declaration
+	for _, decl := range g.decls {
+		if !g.ast.skipped(decl) {
+			res = append(res, decl)
+		}
+	}
+	return res
+}
+
+func (g *declGroup) Doc() []string {
+	return extractCommentGroup(g.decl.Doc)
+}
+
+func (g *declGroup) ToRawNode() interface{} {
+	return g.decl
+}
+
+// eachTypeDeclaration calls fn with each of the individual
+// types in a type declaration.
+func eachTypeDeclaration(s *fileScope, genDecl *ast.GenDecl, fn func(*typeDeclaration)) {
+	for _, spec := range genDecl.Specs {
+		fn(&typeDeclaration{
+			scope: s,
+			decl: genDecl,
+			spec: spec.(*ast.TypeSpec),
+		})
+	}
+}
+
+// eachValueDeclaration calls fn with each of the individual
+// values in a const or var declaration.
+func eachValueDeclaration(s *fileScope, genDecl *ast.GenDecl, fn func(*valueDeclaration)) {
+	if genDecl.Tok == token.CONST {
+		// consts may implicitly repeat earlier types and values
+		for _, entry := range constGroupEntries(genDecl) {
+			entry.scope = s
+			fn(&valueDeclaration{
+				scope: s,
+				decl: genDecl,
+				spec: entry.spec,
+				name: entry.name,
+				typ: entry.typ,
+				value: entry.expr,
+				constEntry: entry,
+			})
+		}
+		return
+	}
+
+	for _, specRaw := range genDecl.Specs {
+		spec := specRaw.(*ast.ValueSpec)
+
+		// `var a, b = f()` initializes every name from a single call
+		var tuple []ValueDeclaration
+		if len(spec.Names) > 1 && len(spec.Values) == 1 {
+			tuple = make([]ValueDeclaration, len(spec.Names))
+		}
+
+		for i, name := range spec.Names {
+			var val ast.Expr
+			switch {
+			case tuple != nil:
+				val = spec.Values[0]
+			case i < len(spec.Values):
+				val = spec.Values[i]
+			}
+			decl := &valueDeclaration{
+				scope: s,
+				decl: genDecl,
+				spec: spec,
+				name: name,
+				typ: spec.Type,
+				value: val,
+				tuple: tuple,
+			}
+			if tuple != nil {
+				tuple[i] = decl
+			}
+			fn(decl)
+		}
+	}
+}
+
 type typeDeclaration struct {
+	scope *fileScope
 	decl *ast.GenDecl
 	spec *ast.TypeSpec
 }
@@ -40,11 +150,22 @@ func (d *typeDeclaration) IsAlias() bool {
 	return d.spec.Assign != token.NoPos
 }
 
-// Doc returns all the comments associated with this type
+// Doc returns all the comments associated with this type.
+// Docs on a parenthesized group belong to the group instead.
 func (d *typeDeclaration) Doc() []string {
-	// TODO: figure out how to include line comments?
-	// TODO: separate declaration docs from spec docs?
-	return append(extractCommentGroup(d.decl.Doc), extractCommentGroup(d.spec.Doc)...)
+	return specDoc(d.decl, d.spec.Doc)
+}
+
+func (d *typeDeclaration) GroupDoc() []string {
+	return extractCommentGroup(d.decl.Doc)
+}
+
+func (d *typeDeclaration) SpecDoc() []string {
+	return extractCommentGroup(d.spec.Doc)
+}
+
+func (d *typeDeclaration) LineComment() []string {
+	return extractCommentGroup(d.spec.Comment)
 }
 
 // Name returns the name of the type
@@ -54,14 +175,30 @@ func (d *typeDeclaration) Name() Ident {
 
 // Type returns the actual underlying type.
 func (d *typeDeclaration) Type() TypeDefinition {
-	return exprToTypeDefinition(d.spec.Type)
+	return exprToTypeDefinition(d.scope, d.spec.Type)
+}
+
+// TypeParams returns the type parameters of a generic type.
+func (d *typeDeclaration) TypeParams() []Field {
+	return fieldListToFields(d.scope, d.spec.TypeParams)
 }
 
 type valueDeclaration struct {
+	scope *fileScope
 	decl *ast.GenDecl
 	spec *ast.ValueSpec
 	name *ast.Ident
+
+	// typ and value are the effective type and value, which may
+	// come from a preceding spec for implicitly repeated constants.
+	typ ast.Expr
 	value ast.Expr
+
+	// constEntry is the corresponding constant, for const declarations.
+	constEntry *constEntry
+	// tuple holds the variables initialized together with this one
+	// from a multi-valued value, shared between them.
+	tuple []ValueDeclaration
 }
 
 func (d *valueDeclaration) IsConst() bool {
@@ -72,21 +209,56 @@ func (d *valueDeclaration) Name() Ident {
 	return unqualifiedIdent(d.name.Name)
 }
 
+// Type returns the declared type, or nil if no type was declared.
 func (d *valueDeclaration) Type() TypeDefinition {
-	return exprToTypeDefinition(d.spec.Type)
+	if d.typ == nil {
+		return nil
+	}
+	return exprToTypeDefinition(d.scope, d.typ)
 }
 
 func (d *valueDeclaration) Doc() []string {
-	// TODO: figure out how to include line comments?
-	// TODO: separate declaration docs from spec docs?
-	return append(extractCommentGroup(d.decl.Doc), extractCommentGroup(d.spec.Doc)...)
+	return specDoc(d.decl, d.spec.Doc)
+}
+
+func (d *valueDeclaration) GroupDoc() []string {
+	return extractCommentGroup(d.decl.Doc)
+}
+
+func (d *valueDeclaration) SpecDoc() []string {
+	return extractCommentGroup(d.spec.Doc)
+}
+
+func (d *valueDeclaration) LineComment() []string {
+	return extractCommentGroup(d.spec.Comment)
 }
  
-func (d *valueDeclaration) Value() ast.Expr {
-	return d.value
+func (d *valueDeclaration) Value() Expression {
+	return exprToExpression(d.scope, d.value)
+}
+
+func (d *valueDeclaration) Tuple() []ValueDeclaration {
+	return d.tuple
+}
+
+// Iota returns the index of this constant's spec in its declaration.
+func (d *valueDeclaration) Iota() int {
+	if d.constEntry == nil {
+		return -1
+	}
+	return d.constEntry.iota
+}
+
+// ConstValue evaluates this constant against the other constants in its file.
+func (d *valueDeclaration) ConstValue() constant.Value {
+	if d.constEntry == nil {
+		return nil
+	}
+	return d.scope.evalConstEntry(d.constEntry)
 }
 
 type funcDeclaration struct {
+	scope *fileScope
 	decl *ast.FuncDecl
 }
 
@@ -94,9 +266,12 @@ func (d *funcDeclaration) Receiver() (Ident, TypeDefinition) {
 	if d.decl.Recv == nil {
 		return nil, nil
 	}
+	if len(d.decl.Recv.List) == 0 {
+		panic(conversionError(d.decl.Recv, "missing receiver"))
+	}
 
 	names := d.decl.Recv.List[0].Names
-	typeDef := exprToTypeDefinition(d.decl.Recv.List[0].Type)
+	typeDef := exprToTypeDefinition(d.scope, d.decl.Recv.List[0].Type)
 
 	if names == nil {
 		return nil, typeDef
@@ -111,12 +286,20 @@ func (d *funcDeclaration) Name() Ident {
 
 func (d *funcDeclaration) Type() FuncTypeDefinition {
 	return &funcTypeDefinition{
+		scope: d.scope,
 		typ: d.decl.Type,
+		name: d.decl.Name,
 	}
 }
 
-func (d *funcDeclaration) Body() *ast.BlockStmt {
-	return d.decl.Body
+func (d *funcDeclaration) Body() BlockStatement {
+	if d.decl.Body == nil {
+		return nil
+	}
+	return &blockStatement{
+		scope: d.scope,
+		stmt: d.decl.Body,
+	}
 }
 
 func (d *funcDeclaration) Doc() []string {
@@ -0,0 +1,36 @@
+package convert
+
+import (
+	"fmt"
+
+	"go/ast"
+	"go/token"
+)
+
+// ConversionError indicates that part of a Go AST could not be converted,
+// either because it's malformed (e.g. an ast.BadExpr from a file with syntax
+// errors) or because it's not supported.
+type ConversionError struct {
+	// Node is the node that could not be converted.
+	Node ast.Node
+	// Position is the position of the node, if known.
+	Position token.Position
+	Message string
+}
+
+func (e *ConversionError) Error() string {
+	if e.Position.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Position, e.Message)
+	}
+	return e.Message
+}
+
+// conversionError constructs a new ConversionError for the given node.
+// Conversion functions panic with the result, since the convert interfaces
+// don't return errors; FromRawChecked recovers them.
+func conversionError(node ast.Node, format string, args ...interface{}) *ConversionError {
+	return &ConversionError{
+		Node: node,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
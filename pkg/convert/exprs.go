@@ -0,0 +1,387 @@
+package convert
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// exprToExpression converts an expression into one of the
+// expression structs.
+func exprToExpression(s *fileScope, expr ast.Expr) Expression {
+	switch typed := expr.(type) {
+	case nil:
+		return nil
+	case *ast.BasicLit:
+		return &basicLiteral{
+			scope: s,
+			lit: typed,
+		}
+	case *ast.CompositeLit:
+		return &compositeLiteral{
+			scope: s,
+			lit: typed,
+		}
+	case *ast.KeyValueExpr:
+		return &keyValueExpression{
+			scope: s,
+			expr: typed,
+		}
+	case *ast.FuncLit:
+		return &funcLiteral{
+			scope: s,
+			lit: typed,
+		}
+	case *ast.CallExpr:
+		if isTypeExpr(s, typed.Fun) && len(typed.Args) == 1 && typed.Ellipsis == token.NoPos {
+			return &conversionExpression{
+				scope: s,
+				expr: typed,
+			}
+		}
+		return &callExpression{
+			scope: s,
+			expr: typed,
+		}
+	case *ast.SelectorExpr:
+		// references to other packages are just qualified names
+		if pkgName, isIdent := typed.X.(*ast.Ident); isIdent && s.isImportName(pkgName.Name) {
+			return qualifiedIdent{
+				packageName: pkgName.Name,
+				Ident: unqualifiedIdent(typed.Sel.Name),
+				source: sourceNode{scope: s, node: typed},
+			}
+		}
+		return &selectorExpression{
+			scope: s,
+			expr: typed,
+		}
+	case *ast.IndexExpr:
+		return &indexExpression{
+			scope: s,
+			expr: typed,
+			indices: []ast.Expr{typed.Index},
+		}
+	case *ast.IndexListExpr:
+		return &indexExpression{
+			scope: s,
+			expr: typed,
+			indices: typed.Indices,
+		}
+	case *ast.SliceExpr:
+		return &sliceExpression{
+			scope: s,
+			expr: typed,
+		}
+	case *ast.TypeAssertExpr:
+		return &typeAssertExpression{
+			scope: s,
+			expr: typed,
+		}
+	case *ast.StarExpr:
+		// in an expression, this is a dereference
+		return &unaryExpression{
+			scope: s,
+			expr: typed,
+			op: token.MUL,
+			operand: typed.X,
+		}
+	case *ast.UnaryExpr:
+		return &unaryExpression{
+			scope: s,
+			expr: typed,
+			op: typed.Op,
+			operand: typed.X,
+		}
+	case *ast.BinaryExpr:
+		return &binaryExpression{
+			scope: s,
+			expr: typed,
+		}
+	case *ast.ParenExpr:
+		return &parenExpression{
+			scope: s,
+			expr: typed,
+		}
+	case *ast.Ident:
+		return sourceIdent{
+			unqualifiedIdent: unqualifiedIdent(typed.Name),
+			source: sourceNode{scope: s, node: typed},
+		}
+	case *ast.ArrayType, *ast.StructType, *ast.FuncType, *ast.InterfaceType, *ast.MapType, *ast.ChanType:
+		return exprToTypeDefinition(s, typed)
+	case *ast.BadExpr:
+		panic(conversionError(typed, "malformed expression"))
+	}
+
+	panic(conversionError(expr, "unknown/invalid expression %T", expr))
+}
+
+// exprsToExpressions converts a list of expressions.
+func exprsToExpressions(s *fileScope, exprs []ast.Expr) []Expression {
+	res := make([]Expression, len(exprs))
+	for i, expr := range exprs {
+		res[i] = exprToExpression(s, expr)
+	}
+	return res
+}
+
+// predeclaredTypes are the names of the predeclared types
+var predeclaredTypes = map[string]bool{
+	"bool": true, "byte": true, "rune": true, "string": true, "error": true, "any": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+// isTypeExpr checks if the given expression is (syntactically, or by
+// reference to a type in this file) a type, distinguishing conversions
+// from calls.  Types from other packages cannot be detected.
+func isTypeExpr(s *fileScope, expr ast.Expr) bool {
+	switch typed := expr.(type) {
+	case *ast.ArrayType, *ast.StructType, *ast.FuncType, *ast.InterfaceType, *ast.MapType, *ast.ChanType:
+		return true
+	case *ast.ParenExpr:
+		return isTypeExpr(s, typed.X)
+	case *ast.StarExpr:
+		return isTypeExpr(s, typed.X)
+	case *ast.IndexExpr:
+		return isTypeExpr(s, typed.X)
+	case *ast.IndexListExpr:
+		return isTypeExpr(s, typed.X)
+	case *ast.Ident:
+		return s.lookupType(typed.Name) != nil || (predeclaredTypes[typed.Name] && s.lookupConst(typed.Name) == nil)
+	default:
+		return false
+	}
+}
+
+type basicLiteral struct {
+	scope *fileScope
+	lit *ast.BasicLit
+}
+
+func (e *basicLiteral) Kind() token.Token {
+	return e.lit.Kind
+}
+func (e *basicLiteral) Literal() string {
+	return e.lit.Value
+}
+func (e *basicLiteral) ToRawNode() interface{} {
+	return e.lit
+}
+
+type compositeLiteral struct {
+	scope *fileScope
+	lit *ast.CompositeLit
+}
+
+func (e *compositeLiteral) LiteralType() TypeDefinition {
+	if e.lit.Type == nil {
+		return nil
+	}
+	return exprToTypeDefinition(e.scope, e.lit.Type)
+}
+func (e *compositeLiteral) Elements() []Expression {
+	return exprsToExpressions(e.scope, e.lit.Elts)
+}
+func (e *compositeLiteral) ToRawNode() interface{} {
+	return e.lit
+}
+
+type keyValueExpression struct {
+	scope *fileScope
+	expr *ast.KeyValueExpr
+}
+
+func (e *keyValueExpression) Key() Expression {
+	return exprToExpression(e.scope, e.expr.Key)
+}
+func (e *keyValueExpression) Value() Expression {
+	return exprToExpression(e.scope, e.expr.Value)
+}
+func (e *keyValueExpression) ToRawNode() interface{} {
+	return e.expr
+}
+
+type funcLiteral struct {
+	scope *fileScope
+	lit *ast.FuncLit
+}
+
+func (e *funcLiteral) Type() FuncTypeDefinition {
+	return &funcTypeDefinition{
+		scope: e.scope,
+		typ: e.lit.Type,
+	}
+}
+func (e *funcLiteral) Body() BlockStatement {
+	return &blockStatement{
+		scope: e.scope,
+		stmt: e.lit.Body,
+	}
+}
+func (e *funcLiteral) ToRawNode() interface{} {
+	return e.lit
+}
+
+type callExpression struct {
+	scope *fileScope
+	expr *ast.CallExpr
+}
+
+func (e *callExpression) Func() Expression {
+	return exprToExpression(e.scope, e.expr.Fun)
+}
+func (e *callExpression) Args() []Expression {
+	return exprsToExpressions(e.scope, e.expr.Args)
+}
+func (e *callExpression) HasEllipsis() bool {
+	return e.expr.Ellipsis != token.NoPos
+}
+func (e *callExpression) ToRawNode() interface{} {
+	return e.expr
+}
+
+type conversionExpression struct {
+	scope *fileScope
+	expr *ast.CallExpr
+}
+
+func (e *conversionExpression) TargetType() TypeDefinition {
+	return exprToTypeDefinition(e.scope, e.expr.Fun)
+}
+func (e *conversionExpression) Operand() Expression {
+	return exprToExpression(e.scope, e.expr.Args[0])
+}
+func (e *conversionExpression) ToRawNode() interface{} {
+	return e.expr
+}
+
+type selectorExpression struct {
+	scope *fileScope
+	expr *ast.SelectorExpr
+}
+
+func (e *selectorExpression) Operand() Expression {
+	return exprToExpression(e.scope, e.expr.X)
+}
+func (e *selectorExpression) Selector() Ident {
+	return unqualifiedIdent(e.expr.Sel.Name)
+}
+func (e *selectorExpression) ToRawNode() interface{} {
+	return e.expr
+}
+
+// indexExpression covers both single-index (ast.IndexExpr)
+// and multi-index (ast.IndexListExpr) expressions.
+type indexExpression struct {
+	scope *fileScope
+	expr ast.Expr
+	indices []ast.Expr
+}
+
+func (e *indexExpression) Operand() Expression {
+	switch typed := e.expr.(type) {
+	case *ast.IndexExpr:
+		return exprToExpression(e.scope, typed.X)
+	default:
+		return exprToExpression(e.scope, typed.(*ast.IndexListExpr).X)
+	}
+}
+func (e *indexExpression) Indices() []Expression {
+	return exprsToExpressions(e.scope, e.indices)
+}
+func (e *indexExpression) ToRawNode() interface{} {
+	return e.expr
+}
+
+type sliceExpression struct {
+	scope *fileScope
+	expr *ast.SliceExpr
+}
+
+func (e *sliceExpression) Operand() Expression {
+	return exprToExpression(e.scope, e.expr.X)
+}
+func (e *sliceExpression) Low() Expression {
+	return exprToExpression(e.scope, e.expr.Low)
+}
+func (e *sliceExpression) High() Expression {
+	return exprToExpression(e.scope, e.expr.High)
+}
+func (e *sliceExpression) Max() Expression {
+	return exprToExpression(e.scope, e.expr.Max)
+}
+func (e *sliceExpression) IsFullSlice() bool {
+	return e.expr.Slice3
+}
+func (e *sliceExpression) ToRawNode() interface{} {
+	return e.expr
+}
+
+type typeAssertExpression struct {
+	scope *fileScope
+	expr *ast.TypeAssertExpr
+}
+
+func (e *typeAssertExpression) Operand() Expression {
+	return exprToExpression(e.scope, e.expr.X)
+}
+func (e *typeAssertExpression) AssertedType() TypeDefinition {
+	if e.expr.Type == nil {
+		return nil
+	}
+	return exprToTypeDefinition(e.scope, e.expr.Type)
+}
+func (e *typeAssertExpression) ToRawNode() interface{} {
+	return e.expr
+}
+
+// unaryExpression covers both normal unary expressions,
+// and dereferences (which are ast.StarExprs).
+type unaryExpression struct {
+	scope *fileScope
+	expr ast.Expr
+	op token.Token
+	operand ast.Expr
+}
+
+func (e *unaryExpression) Op() token.Token {
+	return e.op
+}
+func (e *unaryExpression) Operand() Expression {
+	return exprToExpression(e.scope, e.operand)
+}
+func (e *unaryExpression) ToRawNode() interface{} {
+	return e.expr
+}
+
+type binaryExpression struct {
+	scope *fileScope
+	expr *ast.BinaryExpr
+}
+
+func (e *binaryExpression) Op() token.Token {
+	return e.expr.Op
+}
+func (e *binaryExpression) Left() Expression {
+	return exprToExpression(e.scope, e.expr.X)
+}
+func (e *binaryExpression) Right() Expression {
+	return exprToExpression(e.scope, e.expr.Y)
+}
+func (e *binaryExpression) ToRawNode() interface{} {
+	return e.expr
+}
+
+type parenExpression struct {
+	scope *fileScope
+	expr *ast.ParenExpr
+}
+
+func (e *parenExpression) Inner() Expression {
+	return exprToExpression(e.scope, e.expr.X)
+}
+func (e *parenExpression) ToRawNode() interface{} {
+	return e.expr
+}
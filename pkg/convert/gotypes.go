@@ -0,0 +1,493 @@
+package convert
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// FromGoType converts a go/types type into a TypeDefinition.  Named types
+// from packages other than pkg are qualified with their package names.
+// The results implement Typed, so the original types are still available.
+func FromGoType(typ types.Type, pkg *types.Package) TypeDefinition {
+	s := &fileScope{
+		evalMu: &sync.Mutex{},
+		typed: &typeInfo{pkg: pkg},
+	}
+	return s.fromGoType(typ)
+}
+
+// fromGoType converts a go/types type, relative to this scope's package.
+// The type definition is backed by synthesized Go AST, whose types are
+// recorded so that the results are still Typed.
+func (s *fileScope) fromGoType(typ types.Type) TypeDefinition {
+	synth := &fileScope{
+		pkg: s.pkg,
+		evalMu: s.evalMu,
+		typed: &typeInfo{
+			pkg: s.typed.pkg,
+			info: &types.Info{
+				Types: make(map[ast.Expr]types.TypeAndValue),
+				Uses: make(map[*ast.Ident]types.Object),
+			},
+		},
+	}
+	conv := goTypeConverter{
+		scope: s,
+		info: synth.typed.info,
+	}
+	expr := conv.expr(typ)
+	if expr == nil {
+		return nil
+	}
+	return exprToTypeDefinition(synth, expr)
+}
+
+// goTypeConverter converts go/types types into Go AST type expressions.
+type goTypeConverter struct {
+	// scope is the scope that the types are being converted for,
+	// which determines how other packages are referenced
+	scope *fileScope
+	// info records the types of the converted expressions
+	info *types.Info
+}
+
+func (c goTypeConverter) record(expr ast.Expr, typ types.Type) ast.Expr {
+	c.info.Types[expr] = types.TypeAndValue{Type: typ}
+	return expr
+}
+
+// packageName returns the name that the given package is
+// imported as in the scope's file (if any).
+func (c goTypeConverter) packageName(pkg *types.Package) string {
+	if c.scope.file != nil {
+		for _, spec := range c.scope.file.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil && path == pkg.Path() {
+				if name := c.scope.importName(spec); name != "" && name != "_" && name != "." {
+					return name
+				}
+			}
+		}
+	}
+	return pkg.Name()
+}
+
+// name converts a reference to a type name, qualifying it if it's
+// declared at the top level of another package.
+func (c goTypeConverter) name(obj *types.TypeName) ast.Expr {
+	id := ast.NewIdent(obj.Name())
+	c.info.Uses[id] = obj
+	if obj.Pkg() == nil || obj.Pkg() == c.scope.typed.pkg || obj.Parent() != obj.Pkg().Scope() {
+		return id
+	}
+	return &ast.SelectorExpr{
+		X: ast.NewIdent(c.packageName(obj.Pkg())),
+		Sel: id,
+	}
+}
+
+func (c goTypeConverter) expr(typ types.Type) ast.Expr {
+	switch typed := typ.(type) {
+	case *types.Basic:
+		if typed.Kind() == types.UnsafePointer {
+			return c.record(&ast.SelectorExpr{X: ast.NewIdent("unsafe"), Sel: ast.NewIdent("Pointer")}, typ)
+		}
+		// untyped constants are represented by their default types
+		typed = types.Default(typed).(*types.Basic)
+		if typed.Kind() == types.UntypedNil || typed.Kind() == types.Invalid {
+			return nil
+		}
+		return c.record(ast.NewIdent(typed.Name()), typed)
+	case *types.TypeParam:
+		return c.record(c.name(typed.Obj()), typ)
+	case *types.Named:
+		name := c.name(typed.Obj())
+		if typed.TypeArgs().Len() == 0 {
+			return c.record(name, typ)
+		}
+		c.record(name, typed.Origin())
+		args := make([]ast.Expr, typed.TypeArgs().Len())
+		for i := range args {
+			args[i] = c.expr(typed.TypeArgs().At(i))
+		}
+		if len(args) == 1 {
+			return c.record(&ast.IndexExpr{X: name, Index: args[0]}, typ)
+		}
+		return c.record(&ast.IndexListExpr{X: name, Indices: args}, typ)
+	case *types.Alias:
+		return c.record(c.name(typed.Obj()), typ)
+	case *types.Pointer:
+		return c.record(&ast.StarExpr{X: c.expr(typed.Elem())}, typ)
+	case *types.Slice:
+		return c.record(&ast.ArrayType{Elt: c.expr(typed.Elem())}, typ)
+	case *types.Array:
+		length := &ast.BasicLit{Kind: token.INT, Value: strconv.FormatInt(typed.Len(), 10)}
+		return c.record(&ast.ArrayType{Len: length, Elt: c.expr(typed.Elem())}, typ)
+	case *types.Map:
+		return c.record(&ast.MapType{Key: c.expr(typed.Key()), Value: c.expr(typed.Elem())}, typ)
+	case *types.Chan:
+		var dir ast.ChanDir
+		switch typed.Dir() {
+		case types.SendRecv:
+			dir = ast.SEND | ast.RECV
+		case types.SendOnly:
+			dir = ast.SEND
+		case types.RecvOnly:
+			dir = ast.RECV
+		}
+		return c.record(&ast.ChanType{Dir: dir, Value: c.expr(typed.Elem())}, typ)
+	case *types.Signature:
+		return c.record(c.funcType(typed), typ)
+	case *types.Struct:
+		fields := &ast.FieldList{}
+		for i := 0; i < typed.NumFields(); i++ {
+			v := typed.Field(i)
+			field := &ast.Field{Type: c.expr(v.Type())}
+			if !v.Embedded() {
+				field.Names = []*ast.Ident{ast.NewIdent(v.Name())}
+			}
+			if tag := typed.Tag(i); tag != "" {
+				field.Tag = &ast.BasicLit{Kind: token.STRING, Value: QuoteStructTag(reflect.StructTag(tag))}
+			}
+			fields.List = append(fields.List, field)
+		}
+		return c.record(&ast.StructType{Fields: fields}, typ)
+	case *types.Interface:
+		// constraints like `[T ~int]` are implicitly interfaces
+		if typed.IsImplicit() && typed.NumEmbeddeds() == 1 {
+			return c.expr(typed.EmbeddedType(0))
+		}
+		methods := &ast.FieldList{}
+		for i := 0; i < typed.NumEmbeddeds(); i++ {
+			methods.List = append(methods.List, &ast.Field{Type: c.expr(typed.EmbeddedType(i))})
+		}
+		for i := 0; i < typed.NumExplicitMethods(); i++ {
+			method := typed.ExplicitMethod(i)
+			methods.List = append(methods.List, &ast.Field{
+				Names: []*ast.Ident{ast.NewIdent(method.Name())},
+				Type: c.record(c.funcType(method.Type().(*types.Signature)), method.Type()),
+			})
+		}
+		return c.record(&ast.InterfaceType{Methods: methods}, typ)
+	case *types.Union:
+		var res ast.Expr
+		for i := 0; i < typed.Len(); i++ {
+			term := c.expr(typed.Term(i).Type())
+			if typed.Term(i).Tilde() {
+				term = &ast.UnaryExpr{Op: token.TILDE, X: term}
+			}
+			if res == nil {
+				res = term
+			} else {
+				res = &ast.BinaryExpr{X: res, Op: token.OR, Y: term}
+			}
+		}
+		return c.record(res, typ)
+	}
+
+	// tuples and invalid types have no type expression
+	return nil
+}
+
+func (c goTypeConverter) funcType(sig *types.Signature) *ast.FuncType {
+	res := &ast.FuncType{
+		Params: c.fields(sig.Params(), sig.Variadic()),
+	}
+	if sig.Results().Len() > 0 {
+		res.Results = c.fields(sig.Results(), false)
+	}
+	if sig.TypeParams().Len() > 0 {
+		res.TypeParams = &ast.FieldList{}
+		for i := 0; i < sig.TypeParams().Len(); i++ {
+			param := sig.TypeParams().At(i)
+			res.TypeParams.List = append(res.TypeParams.List, &ast.Field{
+				Names: []*ast.Ident{ast.NewIdent(param.Obj().Name())},
+				Type: c.expr(param.Constraint()),
+			})
+		}
+	}
+	return res
+}
+
+func (c goTypeConverter) fields(tuple *types.Tuple, variadic bool) *ast.FieldList {
+	res := &ast.FieldList{}
+	for i := 0; i < tuple.Len(); i++ {
+		v := tuple.At(i)
+		field := &ast.Field{}
+		if slice, isSlice := v.Type().(*types.Slice); variadic && i == tuple.Len()-1 && isSlice {
+			field.Type = &ast.Ellipsis{Elt: c.expr(slice.Elem())}
+		} else {
+			field.Type = c.expr(v.Type())
+		}
+		if v.Name() != "" {
+			field.Names = []*ast.Ident{ast.NewIdent(v.Name())}
+		}
+		res.List = append(res.List, field)
+	}
+	return res
+}
+
+// ToGoType converts a TypeDefinition into a go/types type.  Typed
+// definitions convert to their original types.  Otherwise, the type is
+// constructed, looking up unqualified names in pkg (and the universe),
+// and qualified names in pkg's imports.  pkg may be nil if the type
+// only refers to predeclared names.
+func ToGoType(def TypeDefinition, pkg *types.Package) (types.Type, error) {
+	if typed, isTyped := def.(Typed); isTyped {
+		if typ := typed.GoType(); typ != nil {
+			return typ, nil
+		}
+	}
+
+	switch typed := def.(type) {
+	case QualifiedIdent:
+		imported := findImport(pkg, typed.ImportPath(), typed.PackageName())
+		if imported == nil {
+			if typed.ImportPath() != "" {
+				return nil, fmt.Errorf("unknown package %q (%s)", typed.PackageName(), typed.ImportPath())
+			}
+			return nil, fmt.Errorf("unknown package %q", typed.PackageName())
+		}
+		return goTypeOf(imported.Scope().Lookup(typed.Name()), typed.PackageName()+"."+typed.Name())
+	case ImportedIdent:
+		if typed.ImportPath() == "" {
+			return lookupGoType(pkg, typed.Name())
+		}
+		// dot imported
+		imported := findImport(pkg, typed.ImportPath(), "")
+		if imported == nil {
+			return nil, fmt.Errorf("unknown package %q", typed.ImportPath())
+		}
+		return goTypeOf(imported.Scope().Lookup(typed.Name()), typed.Name())
+	case Ident:
+		return lookupGoType(pkg, typed.Name())
+	case PointerTypeDefinition:
+		elem, err := ToGoType(typed.ReferentType(), pkg)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(elem), nil
+	case ArrayTypeDefinition:
+		elem, err := ToGoType(typed.ElemType(), pkg)
+		if err != nil {
+			return nil, err
+		}
+		length := typed.Length()
+		if length == nil {
+			return types.NewSlice(elem), nil
+		}
+		value := length.Value()
+		if value == nil || value.Kind() != constant.Int {
+			return nil, fmt.Errorf("unknown array length")
+		}
+		n, exact := constant.Int64Val(value)
+		if !exact {
+			return nil, fmt.Errorf("invalid array length %s", value)
+		}
+		return types.NewArray(elem, n), nil
+	case SplatTypeDefinition:
+		elem, err := ToGoType(typed.ElemType(), pkg)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewSlice(elem), nil
+	case MapTypeDefinition:
+		key, err := ToGoType(typed.KeyType(), pkg)
+		if err != nil {
+			return nil, err
+		}
+		value, err := ToGoType(typed.ValueType(), pkg)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewMap(key, value), nil
+	case ChanTypeDefinition:
+		elem, err := ToGoType(typed.ValueType(), pkg)
+		if err != nil {
+			return nil, err
+		}
+		dir := types.SendRecv
+		switch recv, send := typed.Directions(); {
+		case recv && !send:
+			dir = types.RecvOnly
+		case send && !recv:
+			dir = types.SendOnly
+		}
+		return types.NewChan(dir, elem), nil
+	case FuncTypeDefinition:
+		return toGoSignature(typed, pkg)
+	case StructTypeDefinition:
+		var fields []*types.Var
+		var tags []string
+		for _, field := range typed.Fields() {
+			typ, err := ToGoType(field.Type(), pkg)
+			if err != nil {
+				return nil, err
+			}
+			var name string
+			if !field.IsEmbedded() {
+				if field.Name() == nil {
+					return nil, fmt.Errorf("struct field with no name isn't embedded")
+				}
+				name = field.Name().Name()
+			} else {
+				// embedded fields are named after their type
+				embedded := typ
+				if ptr, isPtr := embedded.(*types.Pointer); isPtr {
+					embedded = ptr.Elem()
+				}
+				named, isNamed := embedded.(interface{ Obj() *types.TypeName })
+				if !isNamed {
+					return nil, fmt.Errorf("invalid embedded field type %s", typ)
+				}
+				name = named.Obj().Name()
+			}
+			fields = append(fields, types.NewField(token.NoPos, pkg, name, typ, field.IsEmbedded()))
+			tags = append(tags, string(field.Tag()))
+		}
+		return types.NewStruct(fields, tags), nil
+	case InterfaceTypeDefinition:
+		var methods []*types.Func
+		var embeddeds []types.Type
+		for _, elem := range typed.Embeds() {
+			typ, err := ToGoType(elem.Type(), pkg)
+			if err != nil {
+				return nil, err
+			}
+			embeddeds = append(embeddeds, typ)
+		}
+		for _, method := range typed.Methods() {
+			funcType, isFunc := method.Type().(FuncTypeDefinition)
+			if !isFunc {
+				return nil, fmt.Errorf("invalid type for method %s", method.Name().Name())
+			}
+			sig, err := toGoSignature(funcType, pkg)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, types.NewFunc(token.NoPos, pkg, method.Name().Name(), sig))
+		}
+		return types.NewInterfaceType(methods, embeddeds).Complete(), nil
+	case InstantiatedTypeDefinition:
+		generic, err := ToGoType(typed.GenericType(), pkg)
+		if err != nil {
+			return nil, err
+		}
+		var args []types.Type
+		for _, arg := range typed.TypeArgs() {
+			typ, err := ToGoType(arg, pkg)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, typ)
+		}
+		return types.Instantiate(nil, generic, args, true)
+	case UnionTypeDefinition:
+		var terms []*types.Term
+		for _, termDef := range typed.Terms() {
+			term, err := toGoTerm(termDef, pkg)
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, term)
+		}
+		return types.NewUnion(terms), nil
+	case TildeTypeDefinition:
+		term, err := toGoTerm(typed, pkg)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewUnion([]*types.Term{term}), nil
+	}
+
+	return nil, fmt.Errorf("unknown type definition %T", def)
+}
+
+func toGoSignature(def FuncTypeDefinition, pkg *types.Package) (*types.Signature, error) {
+	params, variadic, err := toGoTuple(def.Params(), pkg)
+	if err != nil {
+		return nil, err
+	}
+	results, _, err := toGoTuple(def.Results(), pkg)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewSignatureType(nil, nil, nil, params, results, variadic), nil
+}
+
+func toGoTuple(fields []Field, pkg *types.Package) (tuple *types.Tuple, variadic bool, err error) {
+	vars := make([]*types.Var, len(fields))
+	for i, field := range fields {
+		typ, err := ToGoType(field.Type(), pkg)
+		if err != nil {
+			return nil, false, err
+		}
+		if _, isSplat := field.Type().(SplatTypeDefinition); isSplat {
+			variadic = true
+		}
+		var name string
+		if field.Name() != nil {
+			name = field.Name().Name()
+		}
+		vars[i] = types.NewParam(token.NoPos, pkg, name, typ)
+	}
+	return types.NewTuple(vars...), variadic, nil
+}
+
+func toGoTerm(def TypeDefinition, pkg *types.Package) (*types.Term, error) {
+	tilde, isTilde := def.(TildeTypeDefinition)
+	if isTilde {
+		def = tilde.Approximates()
+	}
+	typ, err := ToGoType(def, pkg)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTerm(isTilde, typ), nil
+}
+
+// findImport finds the package imported by pkg with the given import path,
+// or if the path isn't known, the given name.  Names are only a fallback,
+// since imports may be renamed (like `metav1 "k8s.io/.../meta/v1"`).
+func findImport(pkg *types.Package, path, name string) *types.Package {
+	if path == "unsafe" || (path == "" && name == "unsafe") {
+		return types.Unsafe
+	}
+	if pkg == nil {
+		return nil
+	}
+	for _, imported := range pkg.Imports() {
+		if path != "" && imported.Path() == path {
+			return imported
+		}
+		if path == "" && imported.Name() == name {
+			return imported
+		}
+	}
+	return nil
+}
+
+// lookupGoType finds the type with the given name in pkg or the universe.
+func lookupGoType(pkg *types.Package, name string) (types.Type, error) {
+	scope := types.Universe
+	if pkg != nil {
+		scope = pkg.Scope()
+	}
+	_, obj := scope.LookupParent(name, token.NoPos)
+	return goTypeOf(obj, name)
+}
+
+// goTypeOf returns the type named by the given object, if it's a type name.
+func goTypeOf(obj types.Object, name string) (types.Type, error) {
+	typeName, isTypeName := obj.(*types.TypeName)
+	if !isTypeName {
+		return nil, fmt.Errorf("unknown type %s", name)
+	}
+	return typeName.Type(), nil
+}
@@ -0,0 +1,67 @@
+package convert_test
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+const aliasedSrc = `package x
+
+import (
+	strs "strings"
+	. "bytes"
+)
+
+type T struct {
+	B strs.Builder
+	D Buffer
+}
+`
+
+func TestToGoTypeAliasedImports(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", aliasedSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := config.Check("x", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the untyped wrappers, so the types have to be looked up
+	a := convert.FromRawWithFileSet(fset, file)
+	fields := a.Types()[0].Type().(convert.StructTypeDefinition).Fields()
+	cases := []struct {
+		name     string
+		def      convert.TypeDefinition
+		expected string
+	}{
+		{name: "renamed import", def: fields[0].Type(), expected: "strings.Builder"},
+		{name: "dot import", def: fields[1].Type(), expected: "bytes.Buffer"},
+		{name: "built with path", def: convert.NewImportedIdent("strings", "strings", convert.NewIdent("Builder")), expected: "strings.Builder"},
+		{name: "built with other name", def: convert.NewImportedIdent("strings", "s", convert.NewIdent("Reader")), expected: "strings.Reader"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			typ, err := convert.ToGoType(c.def, pkg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if typ.String() != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, typ)
+			}
+		})
+	}
+
+	if _, err := convert.ToGoType(convert.NewQualifiedIdent("strs", convert.NewIdent("Builder")), pkg); err == nil {
+		t.Errorf("expected an error for a package name without a path that isn't imported under that name")
+	}
+}
@@ -2,6 +2,7 @@ package convert
 
 import (
 	"go/ast"
+	"go/token"
 )
 
 type unqualifiedIdent string
@@ -10,28 +11,99 @@ func (i unqualifiedIdent) Name() string { return string(i) }
 type qualifiedIdent struct {
 	packageName string
 	Ident
+	// source is only set for identifiers from source code
+	source sourceNode
+	// importPath is only set for identifiers from NewImportedIdent
+	importPath string
 }
 
 func (i qualifiedIdent) PackageName() string {
 	return i.packageName
 }
+// ImportPath resolves the package name through the imports
+// of the file containing the identifier.
+func (i qualifiedIdent) ImportPath() string {
+	if i.source.scope == nil {
+		return i.importPath
+	}
+	if obj := i.source.scope.objectOf(i.source.node); obj != nil && obj.Pkg() != nil {
+		return obj.Pkg().Path()
+	}
+	return i.source.scope.importPath(i.packageName)
+}
+func (i qualifiedIdent) Position() token.Position {
+	return i.source.position()
+}
+
+// sourceIdent is an unqualified identifier from source code
+type sourceIdent struct {
+	unqualifiedIdent
+	source sourceNode
+	// isType is set for identifiers used as types
+	isType bool
+}
+func (i sourceIdent) Position() token.Position {
+	return i.source.position()
+}
+// ImportPath resolves identifiers from dot imports.
+func (i sourceIdent) ImportPath() string {
+	id, isIdent := i.source.node.(*ast.Ident)
+	if !isIdent {
+		return ""
+	}
+	return i.source.scope.dotImportPath(id, i.isType)
+}
+
+// sourceNode records where an identifier came from.  Unlike
+// the other wrappers, identifiers are values, so the zero
+// sourceNode is valid, and has an invalid position.
+type sourceNode struct {
+	scope *fileScope
+	node ast.Node
+}
+func (n sourceNode) position() token.Position {
+	if n.node == nil {
+		return token.Position{}
+	}
+	return n.scope.position(n.node)
+}
 
+// typeIdent is an identifier that refers to a declared type.  scope
+// is the scope of the declaring file, which may differ from the file
+// containing the identifier in a package.
 type typeIdent struct {
-	Ident
+	scope *fileScope
+	sourceIdent
 	typDecl ast.Expr
 }
 func (i typeIdent) LocateType() TypeDefinition {
 	// TODO: is this correct for things other than embeds?
-	return exprToTypeDefinition(i.typDecl)
+	return exprToTypeDefinition(i.scope, i.typDecl)
 }
 
 func NewIdent(name string) Ident {
 	return unqualifiedIdent(name)
 }
 
+// IsBlank checks if the given identifier is the blank identifier `_`,
+// which declares nothing that can be referenced.
+func IsBlank(i Ident) bool {
+	return i != nil && i.Name() == "_"
+}
+
 func NewQualifiedIdent(pkgName string, i Ident) QualifiedIdent {
 	return qualifiedIdent{
 		packageName: pkgName,
 		Ident: i,
 	}
 }
+
+// NewImportedIdent creates an identifier qualified by the given
+// package name, which refers to the package with the given import path.
+func NewImportedIdent(importPath, pkgName string, i Ident) QualifiedIdent {
+	return qualifiedIdent{
+		packageName: pkgName,
+		Ident: i,
+		importPath: importPath,
+	}
+}
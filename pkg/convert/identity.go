@@ -0,0 +1,450 @@
+package convert
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// Identical checks if two type definitions denote the same type.  Without
+// type information, named types are compared by (qualified) name, so types
+// from different sources (e.g. parsed and built) can be compared, but names
+// aren't resolved: `pkg.T` and `T` are never identical, even from within pkg.
+// When both definitions are Typed, they're compared with go/types instead.
+//
+// As in Go, parameter and result names don't matter, but field names, tags,
+// and embeddedness do.  Interfaces are compared by their method sets.
+func Identical(a, b TypeDefinition) bool {
+	if typedA, isTyped := a.(Typed); isTyped {
+		if typedB, isTyped := b.(Typed); isTyped {
+			if typA, typB := typedA.GoType(), typedB.GoType(); typA != nil && typB != nil {
+				return types.Identical(typA, typB)
+			}
+		}
+	}
+
+	switch typedA := a.(type) {
+	case StructTypeDefinition:
+		typedB, isStruct := b.(StructTypeDefinition)
+		return isStruct && identicalFields(typedA.Fields(), typedB.Fields())
+	case InterfaceTypeDefinition:
+		typedB, isIface := b.(InterfaceTypeDefinition)
+		return isIface && identicalInterfaces(typedA, typedB)
+	case FuncTypeDefinition:
+		typedB, isFunc := b.(FuncTypeDefinition)
+		return isFunc && identicalSignatures(typedA, typedB)
+	case MapTypeDefinition:
+		typedB, isMap := b.(MapTypeDefinition)
+		return isMap && Identical(typedA.KeyType(), typedB.KeyType()) && Identical(typedA.ValueType(), typedB.ValueType())
+	case ChanTypeDefinition:
+		typedB, isChan := b.(ChanTypeDefinition)
+		if !isChan {
+			return false
+		}
+		recvA, sendA := typedA.Directions()
+		recvB, sendB := typedB.Directions()
+		return recvA == recvB && sendA == sendB && Identical(typedA.ValueType(), typedB.ValueType())
+	case PointerTypeDefinition:
+		typedB, isPtr := b.(PointerTypeDefinition)
+		return isPtr && Identical(typedA.ReferentType(), typedB.ReferentType())
+	case SplatTypeDefinition:
+		typedB, isSplat := b.(SplatTypeDefinition)
+		return isSplat && Identical(typedA.ElemType(), typedB.ElemType())
+	case ArrayTypeDefinition:
+		typedB, isArray := b.(ArrayTypeDefinition)
+		return isArray && identicalLengths(typedA.Length(), typedB.Length()) && Identical(typedA.ElemType(), typedB.ElemType())
+	case InstantiatedTypeDefinition:
+		typedB, isInst := b.(InstantiatedTypeDefinition)
+		return isInst && Identical(typedA.GenericType(), typedB.GenericType()) && identicalLists(typedA.TypeArgs(), typedB.TypeArgs())
+	case UnionTypeDefinition:
+		typedB, isUnion := b.(UnionTypeDefinition)
+		return isUnion && identicalLists(typedA.Terms(), typedB.Terms())
+	case TildeTypeDefinition:
+		typedB, isTilde := b.(TildeTypeDefinition)
+		return isTilde && Identical(typedA.Approximates(), typedB.Approximates())
+	case QualifiedIdent:
+		if samePackage, known := identicalImports(typedA, b); known {
+			return samePackage && typedA.Name() == b.(Ident).Name()
+		}
+		typedB, isQualified := b.(QualifiedIdent)
+		return isQualified && typedA.PackageName() == typedB.PackageName() && typedA.Name() == typedB.Name()
+	case Ident:
+		if samePackage, known := identicalImports(typedA, b); known {
+			return samePackage && typedA.Name() == b.(Ident).Name()
+		}
+		if _, isQualified := b.(QualifiedIdent); isQualified {
+			return false
+		}
+		typedB, isIdent := b.(Ident)
+		return isIdent && typedA.Name() == typedB.Name()
+	}
+	return false
+}
+
+// identicalImports checks if two identifiers refer to the same imported
+// package, which is only known if both resolve to an import path (so that
+// e.g. a dot-imported identifier matches a qualified one).
+func identicalImports(a Ident, b TypeDefinition) (same bool, known bool) {
+	importedA, isImportedA := a.(ImportedIdent)
+	importedB, isImportedB := b.(ImportedIdent)
+	if !isImportedA || !isImportedB {
+		return false, false
+	}
+	pathA, pathB := importedA.ImportPath(), importedB.ImportPath()
+	if pathA == "" || pathB == "" {
+		return false, false
+	}
+	return pathA == pathB, true
+}
+
+func identicalLists(a, b []TypeDefinition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Identical(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// identicalLengths compares array lengths by value, falling back
+// to the expressions as written if they can't be evaluated.
+func identicalLengths(a, b ArrayLength) bool {
+	if a == nil || b == nil || a == AutoLength || b == AutoLength {
+		return a == b
+	}
+	valA, valB := a.Value(), b.Value()
+	if valA != nil && valB != nil {
+		return constant.Compare(valA, token.EQL, valB)
+	}
+	return types.ExprString(exprOf(a.Expr())) == types.ExprString(exprOf(b.Expr()))
+}
+
+// exprOf returns the underlying Go AST expression of an Expression,
+// for display purposes, or a placeholder if there isn't one.
+func exprOf(expr Expression) ast.Expr {
+	if raw, isRaw := expr.(interface{ ToRawNode() interface{} }); isRaw {
+		if rawExpr, isExpr := raw.ToRawNode().(ast.Expr); isExpr {
+			return rawExpr
+		}
+	}
+	return &ast.BadExpr{}
+}
+
+func identicalFields(a, b []Field) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].IsEmbedded() != b[i].IsEmbedded() {
+			return false
+		}
+		nameA, nameB := a[i].Name(), b[i].Name()
+		if (nameA == nil) != (nameB == nil) || (nameA != nil && nameA.Name() != nameB.Name()) {
+			return false
+		}
+		if a[i].Tag() != b[i].Tag() {
+			return false
+		}
+		if !Identical(a[i].Type(), b[i].Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+// identicalSignatures compares function signatures, ignoring parameter names.
+func identicalSignatures(a, b FuncTypeDefinition) bool {
+	return identicalFieldTypes(a.TypeParams(), b.TypeParams()) &&
+		identicalFieldTypes(a.Params(), b.Params()) &&
+		identicalFieldTypes(a.Results(), b.Results())
+}
+
+func identicalFieldTypes(a, b []Field) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Identical(a[i].Type(), b[i].Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+// identicalInterfaces compares interfaces by their method sets.  Embedded
+// elements that can't be expanded into methods (unresolvable interfaces and
+// constraint terms) must match in order.
+func identicalInterfaces(a, b InterfaceTypeDefinition) bool {
+	_, methodsA, elemsA := interfaceElements(a)
+	_, methodsB, elemsB := interfaceElements(b)
+	if len(methodsA) != len(methodsB) || !identicalLists(elemsA, elemsB) {
+		return false
+	}
+	for name, sigA := range methodsA {
+		sigB, exists := methodsB[name]
+		if !exists || !identicalSignatures(sigA, sigB) {
+			return false
+		}
+	}
+	return true
+}
+
+// interfaceElements returns the methods of an interface (including those
+// of embedded interfaces that can be found) by name, along with their names
+// in declaration order, and the embedded elements that couldn't be expanded
+// into methods.
+func interfaceElements(def InterfaceTypeDefinition) ([]string, map[string]FuncTypeDefinition, []TypeDefinition) {
+	var names []string
+	methods := make(map[string]FuncTypeDefinition)
+	var elems []TypeDefinition
+	seen := make(map[*ast.TypeSpec]bool)
+
+	var collect func(def InterfaceTypeDefinition)
+	collect = func(def InterfaceTypeDefinition) {
+		for _, method := range def.Methods() {
+			sig, isFunc := method.Type().(FuncTypeDefinition)
+			if _, exists := methods[method.Name().Name()]; !isFunc || exists {
+				continue
+			}
+			names = append(names, method.Name().Name())
+			methods[method.Name().Name()] = sig
+		}
+		for _, elem := range def.Embeds() {
+			if embedded, isIface := elem.Type().(InterfaceTypeDefinition); isIface {
+				collect(embedded)
+				continue
+			}
+			entry, embedded := embeddedMemberSource(elem.Type(), 0)
+			iface, isIface := embedded.def.(InterfaceTypeDefinition)
+			if !isIface {
+				elems = append(elems, elem.Type())
+				continue
+			}
+			if entry != nil {
+				if seen[entry.spec] {
+					continue
+				}
+				seen[entry.spec] = true
+			}
+			collect(iface)
+		}
+	}
+	collect(def)
+
+	return names, methods, elems
+}
+
+// MethodMismatch describes an interface method that
+// a type doesn't (correctly) implement.
+type MethodMismatch struct {
+	// Name is the name of the method.
+	Name string
+	// Want is the signature required by the interface.
+	Want FuncTypeDefinition
+	// Have is the signature of the type's method with the same name,
+	// or nil if the type has no such method.
+	Have FuncTypeDefinition
+	// PointerReceiver indicates that the method exists with the right
+	// signature, but only in the method set of a pointer to the type.
+	PointerReceiver bool
+}
+
+func (m MethodMismatch) String() string {
+	switch {
+	case m.Have == nil:
+		return fmt.Sprintf("missing method %s", m.Name)
+	case m.PointerReceiver:
+		return fmt.Sprintf("method %s has pointer receiver", m.Name)
+	default:
+		return fmt.Sprintf("wrong type for method %s", m.Name)
+	}
+}
+
+// Implements checks if typ implements the interface iface, returning the
+// methods that are missing or mismatched if it doesn't.  typ may be a
+// TypeDeclaration or a type definition referring to a declared type
+// (like `T` or `*T`) from FromRaw, FromPackage, or FromTypedPackage, or
+// any interface definition.  The method sets of other types (like those
+// declared with the builder package) aren't known, so they only implement
+// interfaces without methods.
+func Implements(typ TypeDefinition, iface TypeDefinition) (bool, []MethodMismatch) {
+	ifaceDef, isIface := underlyingInterface(iface)
+	if !isIface {
+		return false, nil
+	}
+	names, want, _ := interfaceElements(ifaceDef)
+	have := methodSetOf(typ)
+
+	var mismatches []MethodMismatch
+	for _, name := range names {
+		mismatch := MethodMismatch{Name: name, Want: want[name]}
+		method, exists := have[name]
+		if exists {
+			mismatch.Have = method.sig
+			if !identicalSignatures(method.sig, mismatch.Want) {
+				mismatches = append(mismatches, mismatch)
+			} else if method.pointerOnly {
+				mismatch.PointerReceiver = true
+				mismatches = append(mismatches, mismatch)
+			}
+			continue
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	return len(mismatches) == 0, mismatches
+}
+
+// setMethod is a method in a method set.
+type setMethod struct {
+	sig FuncTypeDefinition
+	// pointerOnly indicates the method is only in the
+	// method set of a pointer to the type
+	pointerOnly bool
+}
+
+// methodSetOf finds the method set of a type, for Implements.
+func methodSetOf(typ TypeDefinition) map[string]setMethod {
+	res := make(map[string]setMethod)
+	pointer := false
+	if ptr, isPtr := typ.(PointerTypeDefinition); isPtr {
+		pointer = true
+		typ = ptr.ReferentType()
+	}
+
+	var decl TypeMembers
+	switch typed := typ.(type) {
+	case TypeMembers:
+		decl = typed
+	case TypeDeclaration:
+		// declarations without known methods (e.g. from the builder package)
+		typ = typed.Type()
+	default:
+		if entry, _ := embeddedMemberSource(typ, 0); entry != nil {
+			if typeDecl, isDecl := entry.declaration().(TypeMembers); isDecl {
+				decl = typeDecl
+			}
+		}
+	}
+
+	if decl == nil {
+		if iface, isIface := underlyingInterface(typ); isIface && !pointer {
+			_, methods, _ := interfaceElements(iface)
+			for name, sig := range methods {
+				res[name] = setMethod{sig: sig}
+			}
+		}
+		return res
+	}
+
+	for _, member := range decl.Members().Members {
+		switch {
+		case member.Method != nil:
+			res[member.Name()] = setMethod{
+				sig: member.Method.Type(),
+				pointerOnly: !pointer && member.PointerReceiver && !member.Indirect,
+			}
+		case member.InterfaceMethod != nil && !(pointer && len(member.Embedding) == 0):
+			// pointers to interfaces have no methods, but pointers
+			// to structs embedding interfaces do
+			if sig, isFunc := member.InterfaceMethod.Type().(FuncTypeDefinition); isFunc {
+				res[member.Name()] = setMethod{sig: sig}
+			}
+		}
+	}
+	return res
+}
+
+// underlyingInterface finds the interface that the given type is or refers to.
+func underlyingInterface(typ TypeDefinition) (InterfaceTypeDefinition, bool) {
+	if iface, isIface := typ.(InterfaceTypeDefinition); isIface {
+		return iface, true
+	}
+	if decl, isDecl := typ.(TypeDeclaration); isDecl {
+		return underlyingInterface(decl.Type())
+	}
+	_, src := embeddedMemberSource(typ, 0)
+	iface, isIface := src.def.(InterfaceTypeDefinition)
+	return iface, isIface
+}
+
+// Assignable checks if a value of type v is assignable to type t.  Beyond
+// identical types, a value may be assigned if its type has an identical
+// underlying type and at least one of the types isn't named, if t is an
+// interface that v implements, or if v is a bidirectional channel with an
+// identical element type and one of the types isn't named.  Underlying
+// types can only be found for types declared in parsed source, or when
+// type information is available.
+func Assignable(v, t TypeDefinition) bool {
+	if typedV, isTyped := v.(Typed); isTyped {
+		if typedT, isTyped := t.(Typed); isTyped {
+			if typV, typT := typedV.GoType(), typedT.GoType(); typV != nil && typT != nil {
+				return types.AssignableTo(typV, typT)
+			}
+		}
+	}
+
+	if Identical(v, t) {
+		return true
+	}
+
+	if _, isIface := underlyingInterface(t); isIface {
+		implements, _ := Implements(v, t)
+		return implements
+	}
+
+	if isNamed(v) && isNamed(t) {
+		return false
+	}
+	underV, underT := underlyingOf(v), underlyingOf(t)
+	if underV == nil || underT == nil {
+		return false
+	}
+	if Identical(underV, underT) {
+		return true
+	}
+
+	chanV, isChanV := underV.(ChanTypeDefinition)
+	chanT, isChanT := underT.(ChanTypeDefinition)
+	if !isChanV || !isChanT {
+		return false
+	}
+	recv, send := chanV.Directions()
+	return recv && send && Identical(chanV.ValueType(), chanT.ValueType())
+}
+
+// isNamed checks if the given type is a named (or predeclared) type.
+func isNamed(typ TypeDefinition) bool {
+	switch typ.(type) {
+	case Ident, InstantiatedTypeDefinition:
+		return true
+	}
+	return false
+}
+
+// underlyingOf finds the underlying type of the given type, or
+// nil if it's a named type that can't be found.
+func underlyingOf(typ TypeDefinition) TypeDefinition {
+	if typed, isTyped := typ.(Typed); isTyped {
+		if underlying := typed.Underlying(); underlying != nil {
+			return underlying
+		}
+	}
+	if !isNamed(typ) {
+		return typ
+	}
+	if ident, isIdent := typ.(Ident); isIdent && types.Universe.Lookup(ident.Name()) != nil {
+		if _, isQualified := typ.(QualifiedIdent); !isQualified {
+			// predeclared types are their own underlying types
+			return typ
+		}
+	}
+	if _, src := embeddedMemberSource(typ, 0); src.def != nil {
+		return src.def
+	}
+	return nil
+}
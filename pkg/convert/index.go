@@ -0,0 +1,204 @@
+package convert
+
+import (
+	"sync"
+)
+
+// Index is an AST with lookups by name.  Its declarations are
+// collected once, when it's created, so it's best used for packages
+// with lots of declarations that are looked up repeatedly.  It
+// implements AST itself, so it may be used in place of the original,
+// but the slices it returns are shared, and must not be modified.
+//
+// An Index is a snapshot: declarations added to the original AST
+// afterwards (e.g. to a builder) aren't reflected in it.
+type Index struct {
+	ast AST
+
+	types []TypeDeclaration
+	funcs []FuncDeclaration
+	values []ValueDeclaration
+	imports []Import
+	groups []DeclGroup
+
+	typesByName map[string]TypeDeclaration
+	funcsByName map[string]FuncDeclaration
+	methodsByType map[string][]FuncDeclaration
+	valuesByName map[string]ValueDeclaration
+
+	// referrers is computed on first use, since it requires
+	// walking every type definition
+	referrersOnce sync.Once
+	referrers map[string][]TypeDeclaration
+}
+
+// NewIndex indexes the declarations of the given AST (parsed or built).
+// When several declarations have the same name (like multiple `init`
+// funcs), lookups return the first one.  Blank (`_`) types and values
+// can't be looked up.
+func NewIndex(a AST) *Index {
+	idx := &Index{
+		ast: a,
+		types: a.Types(),
+		funcs: a.Funcs(),
+		values: a.Values(),
+		imports: a.Imports(),
+		groups: a.Groups(),
+	}
+
+	idx.typesByName = make(map[string]TypeDeclaration, len(idx.types))
+	for _, decl := range idx.types {
+		name := decl.Name().Name()
+		if name == "_" {
+			continue
+		}
+		if _, exists := idx.typesByName[name]; !exists {
+			idx.typesByName[name] = decl
+		}
+	}
+
+	idx.funcsByName = make(map[string]FuncDeclaration, len(idx.funcs))
+	idx.methodsByType = make(map[string][]FuncDeclaration)
+	for _, decl := range idx.funcs {
+		if _, recvType := decl.Receiver(); recvType != nil {
+			if typeName := receiverName(recvType); typeName != "" {
+				idx.methodsByType[typeName] = append(idx.methodsByType[typeName], decl)
+			}
+			continue
+		}
+		name := decl.Name().Name()
+		if _, exists := idx.funcsByName[name]; !exists {
+			idx.funcsByName[name] = decl
+		}
+	}
+
+	idx.valuesByName = make(map[string]ValueDeclaration, len(idx.values))
+	for _, decl := range idx.values {
+		nameIdent := decl.Name()
+		if nameIdent == nil || IsBlank(nameIdent) {
+			continue
+		}
+		if _, exists := idx.valuesByName[nameIdent.Name()]; !exists {
+			idx.valuesByName[nameIdent.Name()] = decl
+		}
+	}
+
+	return idx
+}
+
+// receiverName returns the name of the type of a receiver
+// (e.g. `List` for `*List[T]`), or "" if it's not a named type.
+func receiverName(recvType TypeDefinition) string {
+	if ptr, isPtr := recvType.(PointerTypeDefinition); isPtr {
+		recvType = ptr.ReferentType()
+	}
+	if inst, isInst := recvType.(InstantiatedTypeDefinition); isInst {
+		recvType = inst.GenericType()
+	}
+	if _, isQualified := recvType.(QualifiedIdent); isQualified {
+		return ""
+	}
+	if ident, isIdent := recvType.(Ident); isIdent {
+		return ident.Name()
+	}
+	return ""
+}
+
+// AST returns the original AST.
+func (idx *Index) AST() AST { return idx.ast }
+
+func (idx *Index) PackageName() Ident { return idx.ast.PackageName() }
+func (idx *Index) Types() []TypeDeclaration { return idx.types }
+func (idx *Index) Funcs() []FuncDeclaration { return idx.funcs }
+func (idx *Index) Values() []ValueDeclaration { return idx.values }
+func (idx *Index) Imports() []Import { return idx.imports }
+func (idx *Index) Groups() []DeclGroup { return idx.groups }
+
+// Doc returns the package docs of the original AST, if it has any.
+func (idx *Index) Doc() []string {
+	if doced, isDoced := idx.ast.(Doced); isDoced {
+		return doced.Doc()
+	}
+	return nil
+}
+
+// TypeByName returns the type declared with the given name, or nil.
+func (idx *Index) TypeByName(name string) TypeDeclaration {
+	return idx.typesByName[name]
+}
+
+// FuncByName returns the function (not method) declared with
+// the given name, or nil.
+func (idx *Index) FuncByName(name string) FuncDeclaration {
+	return idx.funcsByName[name]
+}
+
+// MethodsOf returns the methods declared with the given type as their
+// receiver (with either a pointer or value receiver), in source order.
+// Unlike TypeMembers.MethodSet, promoted methods aren't included.
+func (idx *Index) MethodsOf(typeName string) []FuncDeclaration {
+	return idx.methodsByType[typeName]
+}
+
+// ValueByName returns the constant or variable declared
+// with the given name, or nil.
+func (idx *Index) ValueByName(name string) ValueDeclaration {
+	return idx.valuesByName[name]
+}
+
+// ReferencesTo returns the type declarations whose definitions (including
+// type parameter constraints) refer to the type with the given name,
+// which must be declared in this package, in source order.  Types
+// referring to themselves are included.  This only considers type
+// definitions, not the expressions in values or function bodies.
+func (idx *Index) ReferencesTo(typeName string) []TypeDeclaration {
+	idx.referrersOnce.Do(idx.indexReferrers)
+	return idx.referrers[typeName]
+}
+
+func (idx *Index) indexReferrers() {
+	idx.referrers = make(map[string][]TypeDeclaration)
+	for _, decl := range idx.types {
+		refs := &referenceCollector{
+			names: make(map[string]bool),
+			shadowed: make(map[string]bool),
+		}
+		for _, param := range decl.TypeParams() {
+			if name := param.Name(); name != nil {
+				refs.shadowed[name.Name()] = true
+			}
+		}
+		walkTypeDeclaration(decl, refs)
+
+		// types are visited in source order, so each list stays in order
+		for name := range refs.names {
+			if _, isLocal := idx.typesByName[name]; isLocal {
+				idx.referrers[name] = append(idx.referrers[name], decl)
+			}
+		}
+	}
+}
+
+// referenceCollector collects the names of the unqualified
+// identifiers in a type declaration.
+type referenceCollector struct {
+	BaseVisitor
+	names map[string]bool
+	// shadowed are the names of type parameters
+	shadowed map[string]bool
+}
+
+func (c *referenceCollector) VisitTypeDefinition(def TypeDefinition) bool {
+	switch typed := def.(type) {
+	case QualifiedIdent:
+	case ImportedIdent:
+		if typed.ImportPath() == "" && !c.shadowed[typed.Name()] {
+			c.names[typed.Name()] = true
+		}
+	case Ident:
+		if !c.shadowed[typed.Name()] {
+			c.names[typed.Name()] = true
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package convert_test
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// benchDecls is the number of types (and values) in the benchmark package.
+const benchDecls = 5000
+
+// benchPackage parses a generated package with benchDecls types and
+// values, returning it along with the names to look up.
+func benchPackage(b *testing.B) (convert.AST, []string) {
+	var src strings.Builder
+	src.WriteString("package bench\n\n")
+	names := make([]string, benchDecls)
+	for i := range names {
+		names[i] = fmt.Sprintf("T%d", i)
+		fmt.Fprintf(&src, "type T%d struct{ Field int }\n", i)
+		fmt.Fprintf(&src, "var V%d T%d\n", i, i)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "bench.go", src.String(), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return convert.FromRawWithFileSet(fset, file), names
+}
+
+// BenchmarkScanLookup looks up each type and value by scanning
+// Types and Values, as callers did before Index.
+func BenchmarkScanLookup(b *testing.B) {
+	a, names := benchPackage(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		name := names[i%len(names)]
+		var typeFound, valueFound bool
+		for _, decl := range a.Types() {
+			if decl.Name().Name() == name {
+				typeFound = true
+				break
+			}
+		}
+		valueName := "V" + name[1:]
+		for _, decl := range a.Values() {
+			if decl.Name().Name() == valueName {
+				valueFound = true
+				break
+			}
+		}
+		if !typeFound || !valueFound {
+			b.Fatalf("%s not found", name)
+		}
+	}
+}
+
+// BenchmarkIndexLookup looks up each type and value with an Index,
+// including the cost of building it.
+func BenchmarkIndexLookup(b *testing.B) {
+	a, names := benchPackage(b)
+	b.ResetTimer()
+	idx := convert.NewIndex(a)
+	for i := 0; i < b.N; i++ {
+		name := names[i%len(names)]
+		if idx.TypeByName(name) == nil || idx.ValueByName("V"+name[1:]) == nil {
+			b.Fatalf("%s not found", name)
+		}
+	}
+}
+
+// BenchmarkNewIndex measures building an Index.
+func BenchmarkNewIndex(b *testing.B) {
+	a, _ := benchPackage(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		convert.NewIndex(a)
+	}
+}
@@ -0,0 +1,379 @@
+package convert
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// InferredType returns the declared type, or the type inferred from the
+// value.  With type information, the type comes from go/types.  Otherwise
+// it's derived from the value's syntax (see InferType), resolving
+// references to other constants, variables, and functions of this package.
+func (d *valueDeclaration) InferredType() (TypeDefinition, bool) {
+	if d.typ != nil {
+		return d.Type(), false
+	}
+
+	if obj := d.scope.objectOf(d.name); obj != nil {
+		basic, isBasic := obj.Type().(*types.Basic)
+		untyped := isBasic && basic.Info()&types.IsUntyped != 0
+		return d.scope.fromGoType(obj.Type()), untyped
+	}
+
+	inf := &declInferrer{seen: map[*ast.Ident]bool{d.name: true}}
+	if d.tuple != nil {
+		return InferTupleMemberType(d.Value(), d.tupleIndex(), len(d.tuple), inf.resolve), false
+	}
+	typ, untyped := InferType(d.Value(), inf.resolve)
+	// variables are never untyped, even when assigned untyped constants
+	return typ, untyped && d.IsConst()
+}
+
+// tupleIndex returns the position of this declaration in its tuple.
+func (d *valueDeclaration) tupleIndex() int {
+	for i, member := range d.tuple {
+		if member == ValueDeclaration(d) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ReferenceResolver infers the type of an identifier that refers to a
+// declaration (a constant, variable, or function), and whether it's an
+// untyped constant.  It returns false for declared if the identifier
+// doesn't refer to a declaration, in which case it's predeclared.
+type ReferenceResolver func(id Ident) (typ TypeDefinition, untyped bool, declared bool)
+
+// InferType infers the type of an expression from its syntax, and whether
+// it's an untyped constant (whose type is then the default type, like int
+// for `1`).  Literals, composite literals, conversions, type assertions,
+// operators, indexing, and calls to builtins are handled here; references
+// to declarations are passed to resolve, which may be nil for
+// self-contained expressions.  It returns nil if the type can't be
+// inferred.
+func InferType(expr Expression, resolve ReferenceResolver) (TypeDefinition, bool) {
+	return typeInferrer{resolve: resolve}.infer(expr)
+}
+
+// InferTupleMemberType infers the type of the given result of a
+// multi-valued expression: a call, like `var a, b = f()`, or a comma-ok
+// expression, like `var v, ok = m[k]`.  Results are never untyped.
+func InferTupleMemberType(expr Expression, index, count int, resolve ReferenceResolver) TypeDefinition {
+	return typeInferrer{resolve: resolve}.inferTupleMember(expr, index, count)
+}
+
+// typeInferrer holds the rules of InferType.
+type typeInferrer struct {
+	resolve ReferenceResolver
+}
+
+// untypedRanks orders the default types of untyped constants, so that
+// mixing them (as in `1 + 2.5`) results in the later kind.
+var untypedRanks = map[string]int{"int": 1, "rune": 2, "float64": 3, "complex128": 4}
+
+func (inf typeInferrer) infer(expr Expression) (TypeDefinition, bool) {
+	switch typed := expr.(type) {
+	case BasicLiteral:
+		switch typed.Kind() {
+		case token.INT:
+			return NewIdent("int"), true
+		case token.FLOAT:
+			return NewIdent("float64"), true
+		case token.IMAG:
+			return NewIdent("complex128"), true
+		case token.CHAR:
+			return NewIdent("rune"), true
+		case token.STRING:
+			return NewIdent("string"), true
+		}
+	case ParenExpression:
+		return inf.infer(typed.Inner())
+	case CompositeLiteral:
+		return compositeType(typed), false
+	case FuncLiteral:
+		return typed.Type(), false
+	case ConversionExpression:
+		return typed.TargetType(), false
+	case TypeAssertExpression:
+		if asserted := typed.AssertedType(); asserted != nil {
+			return asserted, false
+		}
+	case UnaryExpression:
+		operand, untyped := inf.infer(typed.Operand())
+		switch typed.Op() {
+		case token.AND:
+			if operand == nil {
+				return nil, false
+			}
+			return inferredPointer{referent: operand}, false
+		case token.MUL:
+			if ptr, isPtr := operand.(PointerTypeDefinition); isPtr {
+				return ptr.ReferentType(), false
+			}
+			return nil, false
+		case token.ARROW:
+			if ch, isChan := operand.(ChanTypeDefinition); isChan {
+				return ch.ValueType(), false
+			}
+			return nil, false
+		default:
+			return operand, untyped
+		}
+	case BinaryExpression:
+		switch typed.Op() {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return NewIdent("bool"), true
+		case token.SHL, token.SHR:
+			return inf.infer(typed.Left())
+		}
+		left, leftUntyped := inf.infer(typed.Left())
+		right, rightUntyped := inf.infer(typed.Right())
+		switch {
+		case left == nil || right == nil:
+			return nil, false
+		case !leftUntyped:
+			return left, false
+		case !rightUntyped:
+			return right, false
+		}
+		leftName, _ := left.(Ident)
+		rightName, _ := right.(Ident)
+		if leftName != nil && rightName != nil && untypedRanks[rightName.Name()] > untypedRanks[leftName.Name()] {
+			return right, true
+		}
+		return left, true
+	case CallExpression:
+		return inf.inferCall(typed), false
+	case IndexExpression:
+		if len(typed.Indices()) != 1 {
+			// an instantiation of a generic function
+			return nil, false
+		}
+		operand, _ := inf.infer(typed.Operand())
+		switch container := operand.(type) {
+		case MapTypeDefinition:
+			return container.ValueType(), false
+		case ArrayTypeDefinition:
+			return container.ElemType(), false
+		case QualifiedIdent:
+			return nil, false
+		case Ident:
+			if container.Name() == "string" {
+				return NewIdent("byte"), false
+			}
+		}
+	case SliceExpression:
+		operand, _ := inf.infer(typed.Operand())
+		if arr, isArr := operand.(ArrayTypeDefinition); isArr && arr.Length() != nil {
+			return inferredArray{elem: arr.ElemType()}, false
+		}
+		return operand, false
+	case QualifiedIdent:
+		return nil, false
+	case Ident:
+		if inf.resolve != nil {
+			if typ, untyped, declared := inf.resolve(typed); declared {
+				return typ, untyped
+			}
+		}
+		switch typed.Name() {
+		case "true", "false":
+			return NewIdent("bool"), true
+		case "iota":
+			return NewIdent("int"), true
+		}
+	}
+	return nil, false
+}
+
+// compositeType returns the type of a composite literal, with the
+// length of a `[...]T{...}` literal filled in if it can be counted.
+func compositeType(lit CompositeLiteral) TypeDefinition {
+	typ := lit.LiteralType()
+	arr, isArr := typ.(ArrayTypeDefinition)
+	if !isArr || arr.Length() != AutoLength {
+		return typ
+	}
+	for _, elem := range lit.Elements() {
+		if _, isKeyed := elem.(KeyValueExpression); isKeyed {
+			// the length depends on the (constant) keys
+			return typ
+		}
+	}
+	return inferredArray{elem: arr.ElemType(), length: countedLength(len(lit.Elements()))}
+}
+
+// inferCall infers the type of a call to a builtin, or
+// to a single-result function.
+func (inf typeInferrer) inferCall(call CallExpression) TypeDefinition {
+	fn, isIdent := call.Func().(Ident)
+	if _, isQualified := fn.(QualifiedIdent); !isIdent || isQualified || inf.isDeclared(fn) {
+		return inf.callResult(call, 0)
+	}
+
+	args := call.Args()
+	switch fn.Name() {
+	case "len", "cap", "copy":
+		return NewIdent("int")
+	case "new":
+		if len(args) == 1 {
+			return inferredPointer{referent: asTypeDefinition(args[0])}
+		}
+	case "make":
+		if len(args) > 0 {
+			return asTypeDefinition(args[0])
+		}
+	case "append":
+		if len(args) > 0 {
+			typ, _ := inf.infer(args[0])
+			return typ
+		}
+	}
+	return nil
+}
+
+// isDeclared checks if the given identifier refers to a declaration,
+// rather than a builtin.
+func (inf typeInferrer) isDeclared(id Ident) bool {
+	if inf.resolve == nil {
+		return false
+	}
+	_, _, declared := inf.resolve(id)
+	return declared
+}
+
+// callResult returns the type of the given result of a call to a
+// (non-generic) function whose type can be inferred.
+func (inf typeInferrer) callResult(call CallExpression, index int) TypeDefinition {
+	fnType, _ := inf.infer(call.Func())
+	fn, isFunc := fnType.(FuncTypeDefinition)
+	if !isFunc || len(fn.TypeParams()) > 0 {
+		// results of generic functions depend on their type arguments
+		return nil
+	}
+	results := fn.Results()
+	if index >= len(results) {
+		return nil
+	}
+	return results[index].Type()
+}
+
+func (inf typeInferrer) inferTupleMember(expr Expression, index, count int) TypeDefinition {
+	switch typed := expr.(type) {
+	case ParenExpression:
+		return inf.inferTupleMember(typed.Inner(), index, count)
+	case CallExpression:
+		return inf.callResult(typed, index)
+	case IndexExpression, TypeAssertExpression, UnaryExpression:
+		if unary, isUnary := typed.(UnaryExpression); (isUnary && unary.Op() != token.ARROW) || count != 2 {
+			return nil
+		}
+		if index == 1 {
+			return NewIdent("bool")
+		}
+		typ, _ := inf.infer(expr)
+		return typ
+	}
+	return nil
+}
+
+// asTypeDefinition converts an expression naming a type (like the
+// argument to `new`) to a type definition.  Only parsed identifiers
+// differ between the two.
+func asTypeDefinition(expr Expression) TypeDefinition {
+	if id, isSource := expr.(sourceIdent); isSource {
+		if node, isExpr := id.source.node.(ast.Expr); isExpr {
+			return exprToTypeDefinition(id.source.scope, node)
+		}
+	}
+	return expr
+}
+
+// inferredPointer, inferredArray, and countedLength are types
+// derived from inferred types, like the `*T` of `&T{}`.
+type inferredPointer struct {
+	referent TypeDefinition
+}
+func (p inferredPointer) ReferentType() TypeDefinition { return p.referent }
+
+type inferredArray struct {
+	elem TypeDefinition
+	length ArrayLength
+}
+func (a inferredArray) ElemType() TypeDefinition { return a.elem }
+func (a inferredArray) Length() ArrayLength { return a.length }
+
+// countedLength is both the length of an array and the literal giving it.
+type countedLength int
+func (l countedLength) Expr() Expression { return l }
+func (l countedLength) Value() constant.Value { return constant.MakeInt64(int64(l)) }
+func (l countedLength) Kind() token.Token { return token.INT }
+func (l countedLength) Literal() string { return strconv.Itoa(int(l)) }
+
+// declInferrer resolves references to the constants, variables,
+// and functions of a package while inferring types from syntax.
+type declInferrer struct {
+	// seen holds the names of the declarations currently being
+	// inferred, to avoid getting stuck on (invalid) cycles
+	seen map[*ast.Ident]bool
+}
+
+// resolve is a ReferenceResolver for identifiers from source code.
+func (inf *declInferrer) resolve(id Ident) (TypeDefinition, bool, bool) {
+	src, isSource := id.(sourceIdent)
+	if !isSource {
+		return nil, false, false
+	}
+	s := src.source.scope
+
+	if entry := s.lookupConst(id.Name()); entry != nil {
+		if inf.seen[entry.name] {
+			return nil, false, true
+		}
+		if entry.typ != nil {
+			return exprToTypeDefinition(entry.scope, entry.typ), false, true
+		}
+		// only constants still being resolved form a cycle,
+		// so `A + A` can refer to A twice
+		inf.seen[entry.name] = true
+		defer delete(inf.seen, entry.name)
+		typ, untyped := InferType(exprToExpression(entry.scope, entry.expr), inf.resolve)
+		return typ, untyped, true
+	}
+	if entry := s.lookupVar(id.Name()); entry != nil {
+		return inf.inferVar(entry), false, true
+	}
+	if decl := s.lookupFunc(id.Name()); decl != nil {
+		if decl.decl.Type.TypeParams != nil {
+			// generic functions must be instantiated to be used
+			return nil, false, true
+		}
+		return exprToTypeDefinition(decl.scope, decl.decl.Type), false, true
+	}
+	return nil, false, false
+}
+
+// inferVar infers the type of a top-level variable.
+func (inf *declInferrer) inferVar(entry *varEntry) TypeDefinition {
+	spec, name := entry.spec, entry.spec.Names[entry.index]
+	if inf.seen[name] {
+		return nil
+	}
+	inf.seen[name] = true
+	defer delete(inf.seen, name)
+
+	switch {
+	case spec.Type != nil:
+		return exprToTypeDefinition(entry.scope, spec.Type)
+	case len(spec.Values) == len(spec.Names):
+		typ, _ := InferType(exprToExpression(entry.scope, spec.Values[entry.index]), inf.resolve)
+		return typ
+	case len(spec.Values) == 1:
+		return InferTupleMemberType(exprToExpression(entry.scope, spec.Values[0]), entry.index, len(spec.Names), inf.resolve)
+	}
+	return nil
+}
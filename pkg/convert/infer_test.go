@@ -0,0 +1,179 @@
+package convert_test
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/directxman12/envmap/pkg/convert"
+	"github.com/directxman12/envmap/pkg/generate/builder"
+)
+
+const inferSrc = `package x
+
+const A = 1
+const B = A + A
+const C float64 = 2
+const D = C * A * C
+const E = B << A
+
+var y = 3
+var x = y * y
+var w = "w"
+var v = w + w
+
+const Cycle = Cycle + 1
+`
+
+func TestInferredTypeRepeatedOperands(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", inferSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := convert.NewIndex(convert.FromRawWithFileSet(fset, file))
+
+	cases := []struct {
+		name    string
+		typ     string
+		untyped bool
+	}{
+		{name: "B", typ: "int", untyped: true},
+		{name: "D", typ: "float64"},
+		{name: "E", typ: "int", untyped: true},
+		{name: "x", typ: "int"},
+		{name: "v", typ: "string"},
+		{name: "Cycle", typ: ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			decl := idx.ValueByName(c.name)
+			if decl == nil {
+				t.Fatalf("no declaration named %s", c.name)
+			}
+			typ, untyped := decl.InferredType()
+			typStr := ""
+			if typ != nil {
+				typStr = convert.TypeString(typ, nil)
+			}
+			if typStr != c.typ || untyped != c.untyped {
+				t.Errorf("expected (%q, %v), got (%q, %v)", c.typ, c.untyped, typStr, untyped)
+			}
+		})
+	}
+}
+
+const inferRulesSrc = `package x
+
+type T struct{}
+
+var a = [...]int{1, 2, 3}
+var b = &T{}
+var c = new(T)
+var d = make(map[string]int)
+var e = map[string]int{}["k"]
+var f = "str"[0]
+var g = [2]int{}[:]
+var h = append([]string(nil), "x")
+var i = len("x")
+var j = func() int { return 1 }
+var k, ok = interface{}(nil).(T)
+var ch = make(chan T)
+var recv = <-ch
+
+const l = 'a' + 1.5
+const m = 1 < 2
+const n = -2
+`
+
+func TestInferredTypeRules(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", inferRulesSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := convert.FromRawWithFileSet(fset, file)
+
+	cases := []struct {
+		name    string
+		typ     string
+		untyped bool
+		// resolved is set for types that need references resolved,
+		// so are only inferred for parsed declarations
+		resolved bool
+	}{
+		{name: "a", typ: "[3]int"},
+		{name: "b", typ: "*T"},
+		{name: "c", typ: "*T"},
+		{name: "d", typ: "map[string]int"},
+		{name: "e", typ: "int"},
+		{name: "f", typ: "byte"},
+		{name: "g", typ: "[]int"},
+		{name: "h", typ: "[]string"},
+		{name: "i", typ: "int"},
+		{name: "j", typ: "func() int"},
+		{name: "k", typ: "T"},
+		{name: "ok", typ: "bool"},
+		{name: "recv", typ: "T", resolved: true},
+		{name: "l", typ: "float64", untyped: true},
+		{name: "m", typ: "bool", untyped: true},
+		{name: "n", typ: "int", untyped: true},
+	}
+	asts := map[string]convert.AST{
+		"parsed": parsed,
+		"built":  builder.FromConvert(parsed).(convert.AST),
+	}
+	for astName, a := range asts {
+		idx := convert.NewIndex(a)
+		for _, c := range cases {
+			t.Run(astName+"/"+c.name, func(t *testing.T) {
+				decl := idx.ValueByName(c.name)
+				if decl == nil {
+					t.Fatalf("no declaration named %s", c.name)
+				}
+				expected := c.typ
+				if c.resolved && astName == "built" {
+					expected = ""
+				}
+				typ, untyped := decl.InferredType()
+				typStr := ""
+				if typ != nil {
+					typStr = convert.TypeString(typ, nil)
+				}
+				if typStr != expected || untyped != c.untyped {
+					t.Errorf("expected (%q, %v), got (%q, %v)", expected, c.untyped, typStr, untyped)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkInferredType infers the types of benchDecls variables
+// initialized by calls to functions declared later in the package.
+func BenchmarkInferredType(b *testing.B) {
+	var src strings.Builder
+	src.WriteString("package bench\n\n")
+	for i := 0; i < benchDecls; i++ {
+		fmt.Fprintf(&src, "var V%d = f%d()\n", i, i)
+	}
+	for i := 0; i < benchDecls; i++ {
+		fmt.Fprintf(&src, "func f%d() int { return %d }\n", i, i)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "bench.go", src.String(), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	values := convert.FromRawWithFileSet(fset, file).Values()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, decl := range values {
+			if typ, _ := decl.InferredType(); typ == nil {
+				b.Fatalf("couldn't infer the type of %s", decl.Name().Name())
+			}
+		}
+	}
+}
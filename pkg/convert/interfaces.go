@@ -2,15 +2,17 @@ package convert
 
 import (
 	"reflect"
-	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
 )
 
 //go:generate go run $GOPATH/src/github.com/directxman12/envmap/cmd/basicimpl/main.go -p=Node -o=../generate/basic/types.go $GOFILE
 
 var (
-	// it's illegal for array lengths to be negative,
-	// so this is fine, since Go doesn't have tagged unions.
-	AutoLength = -1
+	// AutoLength is the length of arrays whose length is determined
+	// by their composite literal (`[...]T`).
+	AutoLength ArrayLength = autoLength{}
 )
 
 // NB: any method which returns something from the "go/ast" package
@@ -25,6 +27,17 @@ var (
 // - FuncDeclaration
 type Declaration interface{}
 
+// DeclGroup represents a parenthesized group of declarations, like
+// `const ( ... )`.  Docs on the group are separate from the docs
+// on the individual declarations.
+type DeclGroup interface {
+	// Kind returns token.TYPE, token.CONST, or token.VAR
+	Kind() token.Token
+	// Declarations returns the declarations in the group, in order.
+	// They are the same values returned by AST.Types or AST.Values.
+	Declarations() []Declaration
+}
+
 // TypeDeclaration represents a declaration of a type in the AST.
 type TypeDeclaration interface {
 	Name() Ident
@@ -34,16 +47,47 @@ type TypeDeclaration interface {
 	IsAlias() bool
 	// Type returns the actual underlying type.
 	Type() TypeDefinition
+	// TypeParams returns the type parameters of this declaration (i.e.
+	// `K comparable, V any` in `type name[K comparable, V any] spec`).
+	// Each parameter's type is its constraint.  Non-generic types have no
+	// type parameters.
+	TypeParams() []Field
 }
 
 // We skip import declaratations because we get those elsewhere
 
-// ValueDeclaration represents a const or var declaration
+// ValueDeclaration represents a const or var declaration.
+// Constants in a group which implicitly repeat the preceding
+// type and expression (e.g. in iota-based enums) report
+// the repeated type and expression.  Blank declarations, like the
+// `var _ Iface = (*T)(nil)` assertions, are included, but can't be
+// referenced (see IsBlank).
 type ValueDeclaration interface {
 	IsConst() bool
 	Name() Ident
+	// Type returns the declared type, or nil if it was omitted.
 	Type() TypeDefinition
-	Value() ast.Expr // TODO: deal with this
+	// InferredType returns the declared type, or if it was omitted, the
+	// type inferred from the value (or nil if it can't be inferred).
+	// Untyped constants (e.g. `const x = 5`) report their default type
+	// (int), with untyped set.
+	InferredType() (typ TypeDefinition, untyped bool)
+	// Value returns the (effective) initial value, or nil if there is none.
+	// Variables initialized together from a multi-valued expression all
+	// return that expression (see Tuple).
+	Value() Expression
+	// Tuple returns the variables initialized together from a single
+	// multi-valued expression, like `a` and `b` in `var a, b = f()`, in
+	// order (including this one), or nil if this declaration has its own
+	// value (or none).
+	Tuple() []ValueDeclaration
+	// Iota returns the value of iota for a constant (the index of its
+	// spec within its declaration), or -1 for variables.
+	Iota() int
+	// ConstValue returns the evaluated value of a constant, or nil for
+	// variables and for constants which cannot be evaluated (for instance,
+	// because they reference constants from other packages).
+	ConstValue() constant.Value
 }
 
 type FuncDeclaration interface {
@@ -52,7 +96,9 @@ type FuncDeclaration interface {
 	Receiver() (Ident, TypeDefinition)
 	Name() Ident
 	Type() FuncTypeDefinition
-	Body() *ast.BlockStmt // TODO: deal with this
+	// Body returns the body of the function, or nil
+	// for functions implemented outside Go.
+	Body() BlockStatement
 }
 
 // Type Definitions
@@ -67,18 +113,33 @@ type FuncDeclaration interface {
 // - ChanTypeDefinition
 // - PointerTypeDefinition
 // - SplatTypeDefinition
+// - InstantiatedTypeDefinition
+// - UnionTypeDefinition (constraints only)
+// - TildeTypeDefinition (constraints only)
 // - Ident
 // - QualifiedIdent
 // +basicimpl:skip
 type TypeDefinition interface{}
 
 type StructTypeDefinition interface {
+	// Fields returns the fields of the struct, including
+	// embedded fields (for which IsEmbedded is true).
 	Fields() []Field
 }
 type InterfaceTypeDefinition interface {
+	// Methods returns the methods declared in the interface.
 	Methods() []Field
+	// Embeds returns the embedded interfaces and constraint elements
+	// (like `~int | ~string`), as unnamed fields with IsEmbedded set.
+	Embeds() []Field
+	// Elements returns both the methods and the embeds, in the
+	// order they were declared.
+	Elements() []Field
 }
 type FuncTypeDefinition interface {
+	// TypeParams returns the type parameters of a generic function.
+	// Only function declarations may have type parameters.
+	TypeParams() []Field
 	Params() []Field
 	Results() []Field
 }
@@ -92,8 +153,17 @@ type ArrayTypeDefinition interface {
 	// Length is the length of the array.
 	// A nil length length represents a slice,
 	// and a length of AutoLength represents `[...]T`.
-	// TODO: fix this so that we can represent constant expressions here
-	Length() *int
+	Length() ArrayLength
+}
+
+// ArrayLength is the constant expression giving the length of an array.
+type ArrayLength interface {
+	// Expr returns the length expression as written (e.g. `4` or `sha256.Size`).
+	// AutoLength has no expression.
+	Expr() Expression
+	// Value returns the evaluated length, or nil if it cannot be evaluated
+	// (for instance, if it references a constant from another package).
+	Value() constant.Value
 }
 type SplatTypeDefinition interface {
 	ElemType() TypeDefinition
@@ -109,11 +179,292 @@ type PointerTypeDefinition interface {
 	ReferentType() TypeDefinition
 }
 
+// InstantiatedTypeDefinition represents a generic type
+// instantiated with type arguments (e.g. `List[T]` or `Map[K, V]`).
+type InstantiatedTypeDefinition interface {
+	// GenericType is the generic type being instantiated
+	// (normally an Ident or QualifiedIdent).
+	GenericType() TypeDefinition
+	TypeArgs() []TypeDefinition
+}
+
+// UnionTypeDefinition represents a union of type terms
+// (e.g. `~int | ~string`), as found in constraints.
+type UnionTypeDefinition interface {
+	Terms() []TypeDefinition
+}
+
+// TildeTypeDefinition represents a `~T` term in a constraint, which
+// matches all types whose underlying type is T.
+type TildeTypeDefinition interface {
+	// Approximates returns T in `~T`.
+	Approximates() TypeDefinition
+}
+
+// Expressions
+
+// Expression represents a Go expression.
+// It may be a:
+// - BasicLiteral
+// - CompositeLiteral
+// - KeyValueExpression (only as an element of a CompositeLiteral)
+// - FuncLiteral
+// - CallExpression
+// - ConversionExpression
+// - SelectorExpression
+// - IndexExpression
+// - SliceExpression
+// - TypeAssertExpression
+// - UnaryExpression
+// - BinaryExpression
+// - ParenExpression
+// - Ident or QualifiedIdent (references to named values)
+// - TypeDefinition (for types used as expressions, e.g. in `make([]int, 3)`)
+// +basicimpl:skip
+type Expression interface{}
+
+// BasicLiteral is a literal of a basic type (e.g. `1`, `2.5`, `'x'`, or `"str"`).
+type BasicLiteral interface {
+	// Kind is one of token.INT, token.FLOAT, token.IMAG, token.CHAR, or token.STRING
+	Kind() token.Token
+	// Literal is the literal as written in source (including any quotes).
+	Literal() string
+}
+// CompositeLiteral is a literal of a struct, array, slice, or map type.
+type CompositeLiteral interface {
+	// LiteralType is the type of the literal, or nil if it was elided
+	// (as in `[]T{{...}, {...}}`).
+	LiteralType() TypeDefinition
+	Elements() []Expression
+}
+// KeyValueExpression is a keyed element of a composite literal.
+type KeyValueExpression interface {
+	Key() Expression
+	Value() Expression
+}
+type FuncLiteral interface {
+	Type() FuncTypeDefinition
+	Body() BlockStatement
+}
+// CallExpression is a call to a function or method.
+type CallExpression interface {
+	Func() Expression
+	Args() []Expression
+	// HasEllipsis indicates that the last argument is
+	// passed as variadic arguments (`f(xs...)`).
+	HasEllipsis() bool
+}
+// ConversionExpression is a conversion of a value to a type (`T(x)`).
+type ConversionExpression interface {
+	TargetType() TypeDefinition
+	Operand() Expression
+}
+// SelectorExpression selects a field or method of a value (`x.f`).
+// References to the declarations of other packages are QualifiedIdents.
+type SelectorExpression interface {
+	Operand() Expression
+	Selector() Ident
+}
+// IndexExpression indexes a value (`x[i]`), or instantiates
+// a generic function (`f[int]` or `f[int, string]`).
+type IndexExpression interface {
+	Operand() Expression
+	Indices() []Expression
+}
+// SliceExpression slices a value (`x[low:high]` or `x[low:high:max]`).
+type SliceExpression interface {
+	Operand() Expression
+	// Low, High, and Max return nil if omitted.
+	Low() Expression
+	High() Expression
+	Max() Expression
+	// IsFullSlice indicates that this is a three-index slice
+	IsFullSlice() bool
+}
+// TypeAssertExpression asserts the type of an interface value (`x.(T)`).
+type TypeAssertExpression interface {
+	Operand() Expression
+	// AssertedType returns the asserted type, or nil
+	// for type switch guards (`x.(type)`).
+	AssertedType() TypeDefinition
+}
+// UnaryExpression is an expression with a unary operator.
+type UnaryExpression interface {
+	// Op is the operator.  Pointer dereferences (`*x`) use token.MUL,
+	// and channel receives (`<-ch`) use token.ARROW.
+	Op() token.Token
+	Operand() Expression
+}
+// BinaryExpression is an expression with a binary operator.
+type BinaryExpression interface {
+	Op() token.Token
+	Left() Expression
+	Right() Expression
+}
+// ParenExpression is a parenthesized expression.
+type ParenExpression interface {
+	Inner() Expression
+}
+
+// Statements
+
+// Statement represents a Go statement.
+// It may be a:
+// - BlockStatement
+// - ExpressionStatement
+// - AssignStatement (including short variable declarations)
+// - IncDecStatement
+// - SendStatement
+// - DeclStatement
+// - IfStatement
+// - ForStatement
+// - RangeStatement
+// - SwitchStatement
+// - TypeSwitchStatement
+// - SelectStatement
+// - ReturnStatement
+// - DeferStatement
+// - GoStatement
+// - BranchStatement
+// - LabeledStatement
+// +basicimpl:skip
+type Statement interface{}
+
+type BlockStatement interface {
+	Statements() []Statement
+}
+// ExpressionStatement is an expression (normally a call
+// or receive) used as a statement.
+type ExpressionStatement interface {
+	Expression() Expression
+}
+// AssignStatement is an assignment (`a, b = x, y`), a short variable
+// declaration (`a := x`), or an assignment operation (`a += x`).
+type AssignStatement interface {
+	Left() []Expression
+	// Op is token.ASSIGN, token.DEFINE, or an assignment operator
+	// (e.g. token.ADD_ASSIGN).
+	Op() token.Token
+	Right() []Expression
+}
+type IncDecStatement interface {
+	Target() Expression
+	// IsIncrement indicates `x++`, as opposed to `x--`.
+	IsIncrement() bool
+}
+type SendStatement interface {
+	Channel() Expression
+	SentValue() Expression
+}
+// DeclStatement is a declaration inside a function.  Its declarations are
+// ValueDeclarations or TypeDeclarations.
+type DeclStatement interface {
+	Declarations() []Declaration
+}
+type IfStatement interface {
+	// Init is the optional statement before the condition.
+	Init() Statement
+	Cond() Expression
+	Body() BlockStatement
+	// ElseBranch is nil, an IfStatement (for `else if`),
+	// or a BlockStatement.
+	ElseBranch() Statement
+}
+type ForStatement interface {
+	// Init, Cond, and Post are nil when omitted.
+	Init() Statement
+	Cond() Expression
+	Post() Statement
+	Body() BlockStatement
+}
+type RangeStatement interface {
+	// Key and Value are nil when omitted.
+	Key() Expression
+	Value() Expression
+	// IsDefine indicates that the key and value are declared
+	// (`:=`) instead of assigned (`=`).
+	IsDefine() bool
+	Range() Expression
+	Body() BlockStatement
+}
+type SwitchStatement interface {
+	Init() Statement
+	// Tag is the switched-on expression, or nil for `switch {`.
+	Tag() Expression
+	Clauses() []CaseClause
+}
+// CaseClause is a case (or default) in a switch statement.
+type CaseClause interface {
+	// IsDefault indicates a `default:` clause.
+	IsDefault() bool
+	Values() []Expression
+	Statements() []Statement
+}
+type TypeSwitchStatement interface {
+	Init() Statement
+	// Binding is the variable declared by the guard
+	// (v in `switch v := x.(type)`), or nil.
+	Binding() Ident
+	// Subject is the expression whose type is switched on
+	// (x in `switch x.(type)`).
+	Subject() Expression
+	Clauses() []TypeCaseClause
+}
+// TypeCaseClause is a case (or default) in a type switch statement.
+type TypeCaseClause interface {
+	// IsDefault indicates a `default:` clause.
+	IsDefault() bool
+	// Types are the types matched by this clause (`nil` is an Ident).
+	Types() []TypeDefinition
+	Statements() []Statement
+}
+type SelectStatement interface {
+	Clauses() []CommClause
+}
+// CommClause is a case (or default) in a select statement.
+type CommClause interface {
+	// Comm is the send or receive for this clause (a SendStatement,
+	// ExpressionStatement, or AssignStatement), or nil for `default:`.
+	Comm() Statement
+	Statements() []Statement
+}
+type ReturnStatement interface {
+	Results() []Expression
+}
+type DeferStatement interface {
+	Call() CallExpression
+	// IsDefer distinguishes this from GoStatement.
+	IsDefer() struct{}
+}
+type GoStatement interface {
+	Call() CallExpression
+	// IsGo distinguishes this from DeferStatement.
+	IsGo() struct{}
+}
+// BranchStatement is a break, continue, goto, or fallthrough.
+type BranchStatement interface {
+	// Keyword is token.BREAK, token.CONTINUE, token.GOTO, or token.FALLTHROUGH.
+	Keyword() token.Token
+	// Label is the target label, or nil.
+	Label() Ident
+}
+type LabeledStatement interface {
+	Label() Ident
+	// Statement is the labeled statement, or nil for
+	// a label at the end of a block.
+	Statement() Statement
+}
+
 // Other types
 
 type Import interface {
+	// Name returns the explicit name of the import
+	// (including `.` and `_`), or nil if it has none.
 	Name() Ident
+	// Path returns the import path as a quoted Go string.
 	Path() string
+	// ImportPath returns the unquoted import path.
+	ImportPath() string
 }
 
 type AST interface {
@@ -122,6 +473,22 @@ type AST interface {
 	Funcs() []FuncDeclaration
 	Values() []ValueDeclaration
 	Imports() []Import
+	// Groups returns the parenthesized declaration groups.  Declarations
+	// in groups are still returned individually by Types and Values.
+	Groups() []DeclGroup
+}
+
+// Package is an AST covering all the files of a package.  Declarations
+// from each file are merged together, in the order the files were given.
+// +basicimpl:skip
+type Package interface {
+	AST
+	// Files returns the ASTs of the individual files.  Each file
+	// still resolves references against the rest of the package.
+	Files() []AST
+	// FileOf returns the file that the given declaration (or
+	// DeclGroup) came from, or nil if it's not from this package.
+	FileOf(decl Declaration) AST
 }
 
 // +basicimpl:skip
@@ -130,16 +497,64 @@ type TypeIdent interface {
 	Ident
 }
 
+// TypeMembers is implemented by the TypeDeclarations from FromRaw,
+// FromPackage, and FromTypedPackage.
+// +basicimpl:skip
+type TypeMembers interface {
+	// DeclaredMethods returns the methods with this type as their receiver.
+	DeclaredMethods() []FuncDeclaration
+	// MethodSet returns the method set of the type, or of a pointer to
+	// the type, including methods promoted from embedded fields.
+	MethodSet(pointer bool) MemberSet
+	// Members returns all the fields and methods that can be selected
+	// on the type, and the names of any ambiguous selectors.
+	Members() MemberSet
+}
+
+// Typed is implemented by the type definitions and identifiers of
+// packages from FromTypedPackage, and by the results of FromGoType.
+// +basicimpl:skip
+type Typed interface {
+	// GoType returns the go/types type, or nil if it's unknown.
+	GoType() types.Type
+	// Underlying returns the underlying type, or nil if it's unknown.
+	Underlying() TypeDefinition
+}
+
+// ResolvableIdent is implemented by identifiers that may refer to
+// types, which can be resolved when type information is available.
+// +basicimpl:skip
+type ResolvableIdent interface {
+	Ident
+	// Resolve returns the declaration of the named type, even if it's
+	// from an imported package, or nil if it can't be found.
+	Resolve() TypeDeclaration
+}
+
 // Ident is a bare identifier
 // +basicimpl:skip
 type Ident interface {
 	Name() string
 }
 
+// ImportedIdent is an identifier which may refer to a declaration
+// in an imported package.  Qualified identifiers, and identifiers
+// from source code (which may come from dot imports), are ImportedIdents.
+// +basicimpl:skip
+type ImportedIdent interface {
+	Ident
+	// ImportPath returns the path of the imported package that the
+	// identifier refers to, or the empty string if it's declared in
+	// this package, predeclared, or can't be resolved.
+	ImportPath() string
+}
+
 // QualifiedIdent is an identifier qualified by a package name
 // +basicimpl:skip
 type QualifiedIdent interface {
-	Ident
+	ImportedIdent
+	// PackageName is the name that the package is referenced
+	// by in this file (i.e. `v1` in `v1.Pod`).
 	PackageName() string
 }
 // TODO: capture underlying object as well for convinience?
@@ -149,8 +564,56 @@ type Doced interface {
 	Doc() []string
 }
 
+// Positioned is anything which knows where it came from.
+// All the wrappers returned by FromRaw and friends are Positioned.
+// +basicimpl:skip
+type Positioned interface {
+	// Position returns the source position of the node, which
+	// is invalid (IsValid returns false) if it's unknown.
+	Position() token.Position
+}
+
+// DocPositioned is anything whose docs come from source code, and so
+// knows where each of its doc comments is.  All the wrappers returned
+// by FromRaw and friends that are Doced are DocPositioned.
+// +basicimpl:skip
+type DocPositioned interface {
+	// DocPositions returns the position of each comment returned by
+	// Doc, in the same order.
+	DocPositions() []token.Position
+}
+
+// LineCommented is anything which can have a trailing
+// comment on the same line, like `Foo int // the foo`.
+// +basicimpl:skip
+type LineCommented interface {
+	LineComment() []string
+}
+
+// SpecDoced is anything which is declared as part of a (possibly
+// parenthesized) declaration, and so may have docs both on the
+// overall declaration and on itself.  Doc returns the docs relevant
+// to the individual declaration.
+// +basicimpl:skip
+type SpecDoced interface {
+	// GroupDoc returns the docs on the overall declaration
+	// (e.g. above `const (`, or above `type Foo int` when
+	// not in a group).
+	GroupDoc() []string
+	// SpecDoc returns the docs on the individual spec within
+	// a group, which are empty when not in a group.
+	SpecDoc() []string
+}
+
 type Field interface {
+	// Name returns the name of the field, or nil for embedded
+	// fields and unnamed params and results.
 	Name() Ident
 	Type() TypeDefinition
+	// IsEmbedded checks if this is an embedded struct field,
+	// or an embedded element of an interface.
+	IsEmbedded() bool
+	// Tag is the struct tag, without the surrounding quotes.
+	// Use ParseStructTag to access its individual keys.
 	Tag()  reflect.StructTag
 }
@@ -0,0 +1,308 @@
+package convert
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// Member is a field or method that can be selected on a type (as in
+// `x.Name`), either declared on the type itself, or promoted from one
+// of its embedded fields.
+type Member struct {
+	// Field is set for struct fields.
+	Field Field
+	// Method is set for methods declared on named types.
+	Method FuncDeclaration
+	// InterfaceMethod is set for the methods of interfaces,
+	// including those from embedded interfaces.
+	InterfaceMethod Field
+
+	// Embedding is the chain of embedded fields that the member was
+	// promoted through, and is empty for members of the type itself.
+	Embedding []Field
+	// PointerReceiver indicates that a method has a pointer receiver.
+	PointerReceiver bool
+	// Indirect indicates that Embedding includes an embedded pointer.
+	Indirect bool
+}
+
+// Name returns the name used to select the member.
+func (m Member) Name() string {
+	switch {
+	case m.Method != nil:
+		return m.Method.Name().Name()
+	case m.InterfaceMethod != nil:
+		return m.InterfaceMethod.Name().Name()
+	default:
+		return FieldName(m.Field)
+	}
+}
+
+// IsMethod checks if the member is a method (declared, or from an interface).
+func (m Member) IsMethod() bool {
+	return m.Field == nil
+}
+
+// MemberSet is a set of members of a type.
+type MemberSet struct {
+	// Members are the selectable members, ordered by depth (members
+	// of the type itself first), and then by declaration order.
+	Members []Member
+	// Ambiguous lists the names that are declared more than once at the
+	// shallowest depth they appear at, and so can't be selected.
+	Ambiguous []string
+}
+
+// Lookup finds the member with the given name.
+func (s MemberSet) Lookup(name string) (Member, bool) {
+	for _, member := range s.Members {
+		if member.Name() == name {
+			return member, true
+		}
+	}
+	return Member{}, false
+}
+
+// FieldName returns the name of a field, which for embedded fields is
+// the name of their type (see BaseTypeName), or the empty string for
+// embedded interface elements that aren't named types.
+func FieldName(field Field) string {
+	if name := field.Name(); name != nil {
+		return name.Name()
+	}
+	return BaseTypeName(field.Type())
+}
+
+// BaseTypeName returns the name of a named type, or of the named type
+// behind a pointer or instantiation (`Foo` for `*pkg.Foo[int]`), like
+// the type of an embedded field or a method receiver.  It returns the
+// empty string for other types.
+func BaseTypeName(typ TypeDefinition) string {
+	if ptr, isPtr := typ.(PointerTypeDefinition); isPtr {
+		typ = ptr.ReferentType()
+	}
+	if inst, isInst := typ.(InstantiatedTypeDefinition); isInst {
+		typ = inst.GenericType()
+	}
+	if ident, isIdent := typ.(Ident); isIdent {
+		return ident.Name()
+	}
+	return ""
+}
+
+// DeclaredMethods returns the methods declared with this type as their
+// receiver, from anywhere in its file (or package, for FromPackage).
+func (d *typeDeclaration) DeclaredMethods() []FuncDeclaration {
+	var res []FuncDeclaration
+	for _, method := range d.scope.lookupMethods(d.spec.Name.Name) {
+		res = append(res, method)
+	}
+	return res
+}
+
+// MethodSet returns the method set of the type (or of a pointer to it),
+// including methods promoted from embedded fields.
+func (d *typeDeclaration) MethodSet(pointer bool) MemberSet {
+	members := d.Members()
+	res := MemberSet{Ambiguous: members.Ambiguous}
+	for _, member := range members.Members {
+		if !member.IsMethod() {
+			continue
+		}
+		if member.PointerReceiver && !pointer && !member.Indirect {
+			continue
+		}
+		res.Members = append(res.Members, member)
+	}
+	return res
+}
+
+// Members returns all the fields and methods that can be selected on an
+// (addressable) value of this type, following embedded fields and embedded
+// interfaces.  Embedded types that can't be found (e.g. from other packages,
+// without FromTypedPackage) don't contribute any members.
+func (d *typeDeclaration) Members() MemberSet {
+	var res MemberSet
+
+	// members are found breadth-first, since shallower
+	// members shadow deeper ones with the same name
+	current := []memberSource{typeMemberSource(&typeEntry{scope: d.scope, decl: d.decl, spec: d.spec}, 0)}
+	seen := map[*ast.TypeSpec]bool{d.spec: true}
+	found := make(map[string]bool)
+	for len(current) > 0 {
+		var next []memberSource
+		// types embedded at the same depth more than once make their
+		// members ambiguous, so only skip types seen at shallower depths
+		var nextSeen []*ast.TypeSpec
+		var names []string
+		byName := make(map[string][]Member)
+		add := func(member Member) {
+			name := member.Name()
+			if name == "" || name == "_" || found[name] {
+				return
+			}
+			if _, exists := byName[name]; !exists {
+				names = append(names, name)
+			}
+			byName[name] = append(byName[name], member)
+		}
+
+		for _, src := range current {
+			for _, method := range src.methods {
+				add(Member{
+					Method: method,
+					Embedding: src.embedding,
+					PointerReceiver: isPointerReceiver(method),
+					Indirect: src.indirect,
+				})
+			}
+
+			switch def := src.def.(type) {
+			case StructTypeDefinition:
+				for _, field := range def.Fields() {
+					add(Member{
+						Field: field,
+						Embedding: src.embedding,
+						Indirect: src.indirect,
+					})
+					if !field.IsEmbedded() {
+						continue
+					}
+
+					typ := field.Type()
+					ptr, isPtr := typ.(PointerTypeDefinition)
+					if isPtr {
+						typ = ptr.ReferentType()
+					}
+					entry, embedded := embeddedMemberSource(typ, 0)
+					if embedded.def == nil || (entry != nil && seen[entry.spec]) {
+						continue
+					}
+					if entry != nil {
+						nextSeen = append(nextSeen, entry.spec)
+					}
+					embedded.embedding = append(append([]Field(nil), src.embedding...), field)
+					embedded.indirect = src.indirect || isPtr
+					next = append(next, embedded)
+				}
+			case InterfaceTypeDefinition:
+				for _, method := range interfaceMethods(def, make(map[*ast.TypeSpec]bool)) {
+					add(Member{
+						InterfaceMethod: method,
+						Embedding: src.embedding,
+						Indirect: src.indirect,
+					})
+				}
+			}
+		}
+
+		for _, name := range names {
+			found[name] = true
+			if members := byName[name]; len(members) > 1 {
+				res.Ambiguous = append(res.Ambiguous, name)
+			} else {
+				res.Members = append(res.Members, members[0])
+			}
+		}
+		for _, spec := range nextSeen {
+			seen[spec] = true
+		}
+		current = next
+	}
+
+	return res
+}
+
+// memberSource is a type whose members are being collected,
+// along with the embedded fields that lead to it.
+type memberSource struct {
+	methods []FuncDeclaration
+	// def is the underlying type definition
+	def TypeDefinition
+
+	embedding []Field
+	indirect bool
+}
+
+// maxTypeDepth bounds the number of declarations followed to find the
+// underlying type of a declared type, in case of (invalid) cycles.
+const maxTypeDepth = 16
+
+// typeMemberSource finds the declared methods and underlying type of
+// a declared type.  Like in Go, aliases share the methods of the aliased
+// type, but defined types don't inherit methods from their underlying type.
+func typeMemberSource(entry *typeEntry, depth int) memberSource {
+	var res memberSource
+	if entry.decl != nil {
+		for _, method := range entry.scope.lookupMethods(entry.spec.Name.Name) {
+			res.methods = append(res.methods, method)
+		}
+	}
+
+	res.def = exprToTypeDefinition(entry.scope, entry.spec.Type)
+	if depth >= maxTypeDepth {
+		return res
+	}
+	if _, next := embeddedMemberSource(res.def, depth+1); next.def != nil {
+		if entry.spec.Assign.IsValid() {
+			res.methods = next.methods
+		}
+		res.def = next.def
+	}
+	return res
+}
+
+// embeddedMemberSource finds the type referenced by an embedded field (or
+// the underlying type of a declaration), returning an empty memberSource if
+// it's not a named type that can be found.  The type's declaration (if any)
+// is returned as well, to detect cycles.
+func embeddedMemberSource(typ TypeDefinition, depth int) (*typeEntry, memberSource) {
+	if inst, isInst := typ.(InstantiatedTypeDefinition); isInst {
+		typ = inst.GenericType()
+	}
+
+	switch typed := typ.(type) {
+	case typeIdent:
+		if ident, isIdent := typed.source.node.(*ast.Ident); isIdent {
+			if entry := typed.source.scope.resolveType(ident); entry != nil {
+				return entry, typeMemberSource(entry, depth)
+			}
+		}
+	case ResolvableIdent:
+		if decl, isDecl := typed.Resolve().(*typeDeclaration); isDecl {
+			entry := &typeEntry{scope: decl.scope, decl: decl.decl, spec: decl.spec}
+			return entry, typeMemberSource(entry, depth)
+		}
+		if _, isQualified := typed.(QualifiedIdent); !isQualified && typed.Name() == "error" {
+			// the only predeclared type with members
+			return nil, memberSource{def: FromGoType(types.Universe.Lookup("error").Type().Underlying(), nil)}
+		}
+	}
+	return nil, memberSource{}
+}
+
+// interfaceMethods returns the methods of an interface, including
+// those from embedded interfaces.
+func interfaceMethods(def InterfaceTypeDefinition, seen map[*ast.TypeSpec]bool) []Field {
+	res := append([]Field(nil), def.Methods()...)
+	for _, elem := range def.Embeds() {
+		// embedded interfaces (or constraint terms, which have no methods)
+		entry, embedded := embeddedMemberSource(elem.Type(), 0)
+		if entry != nil {
+			if seen[entry.spec] {
+				continue
+			}
+			seen[entry.spec] = true
+		}
+		if iface, isIface := embedded.def.(InterfaceTypeDefinition); isIface {
+			res = append(res, interfaceMethods(iface, seen)...)
+		}
+	}
+	return res
+}
+
+func isPointerReceiver(method FuncDeclaration) bool {
+	_, recvType := method.Receiver()
+	_, isPtr := recvType.(PointerTypeDefinition)
+	return isPtr
+}
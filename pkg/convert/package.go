@@ -0,0 +1,147 @@
+package convert
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// packageImpl merges the ASTs of all the files in a package.
+type packageImpl struct {
+	files []*astImpl
+	scope *packageScope
+}
+
+// FromPackage wraps the given files, which should all be from the same
+// package.  Unlike FromRaw, identifiers are resolved across files, so
+// types and constants may be referenced from any file in the package.
+func FromPackage(files ...*ast.File) Package {
+	return FromPackageWithFileSet(nil, files...)
+}
+
+// FromPackageWithFileSet is like FromPackage, but resolves the Positions
+// of the resulting nodes against the given FileSet.
+func FromPackageWithFileSet(fset *token.FileSet, files ...*ast.File) Package {
+	pkg := &packageImpl{
+		scope: newPackageScope(fset, files),
+	}
+	for _, scope := range pkg.scope.files {
+		pkg.files = append(pkg.files, &astImpl{
+			file: scope.file,
+			scope: scope,
+		})
+	}
+	return pkg
+}
+
+func (p *packageImpl) Files() []AST {
+	res := make([]AST, len(p.files))
+	for i, file := range p.files {
+		res[i] = file
+	}
+	return res
+}
+
+func (p *packageImpl) FileOf(decl Declaration) AST {
+	var scope *fileScope
+	switch typedDecl := decl.(type) {
+	case *typeDeclaration:
+		scope = typedDecl.scope
+	case *valueDeclaration:
+		scope = typedDecl.scope
+	case *funcDeclaration:
+		scope = typedDecl.scope
+	case *declGroup:
+		scope = typedDecl.ast.scope
+	default:
+		return nil
+	}
+	for _, file := range p.files {
+		if file.scope == scope {
+			return file
+		}
+	}
+	return nil
+}
+
+// PackageName returns the name of the package, as declared by
+// the first file, or nil if there are no files.
+func (p *packageImpl) PackageName() Ident {
+	if len(p.files) == 0 {
+		return nil
+	}
+	return p.files[0].PackageName()
+}
+
+// Doc returns the package docs from all files.  Normally, only
+// one file (e.g. doc.go) should have package docs.
+func (p *packageImpl) Doc() []string {
+	var res []string
+	for _, file := range p.files {
+		res = append(res, file.Doc()...)
+	}
+	return res
+}
+
+// Imports returns the imports of all files, with duplicates removed.
+// Each file still only resolves the package names that it imports.
+func (p *packageImpl) Imports() []Import {
+	type importKey struct {
+		name, path string
+	}
+	seen := make(map[importKey]bool)
+
+	var res []Import
+	for _, file := range p.files {
+		for _, imp := range file.Imports() {
+			key := importKey{path: imp.Path()}
+			if name := imp.Name(); name != nil {
+				key.name = name.Name()
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			res = append(res, imp)
+		}
+	}
+	return res
+}
+
+func (p *packageImpl) Types() []TypeDeclaration {
+	var res []TypeDeclaration
+	for _, file := range p.files {
+		res = append(res, file.Types()...)
+	}
+	return res
+}
+
+func (p *packageImpl) Funcs() []FuncDeclaration {
+	var res []FuncDeclaration
+	for _, file := range p.files {
+		res = append(res, file.Funcs()...)
+	}
+	return res
+}
+
+func (p *packageImpl) Values() []ValueDeclaration {
+	var res []ValueDeclaration
+	for _, file := range p.files {
+		res = append(res, file.Values()...)
+	}
+	return res
+}
+
+func (p *packageImpl) Groups() []DeclGroup {
+	var res []DeclGroup
+	for _, file := range p.files {
+		res = append(res, file.Groups()...)
+	}
+	return res
+}
+
+// walk walks each file in turn, directly from the underlying Go AST.
+func (p *packageImpl) walk(v Visitor) {
+	for _, file := range p.files {
+		file.walk(v)
+	}
+}
@@ -0,0 +1,126 @@
+package convert
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Position implementations for each of the wrappers.  Named declarations
+// and fields are positioned at their names, everything else at its start.
+
+// declarations
+
+func (a *astImpl) Position() token.Position { return a.scope.position(a.file) }
+func (s *importSpec) Position() token.Position { return s.scope.position(s.spec) }
+func (g *declGroup) Position() token.Position { return g.ast.scope.position(g.decl) }
+func (d *typeDeclaration) Position() token.Position { return d.scope.position(d.spec) }
+func (d *valueDeclaration) Position() token.Position { return d.scope.position(d.name) }
+func (d *funcDeclaration) Position() token.Position { return d.scope.position(d.decl.Name) }
+
+// type definitions
+
+func (d *structTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (d *interfaceTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (d *funcTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (d *mapTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (d *arrayTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (l *arrayLength) Position() token.Position { return l.scope.position(l.expr) }
+func (d *chanTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (d *pointerTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (d *splatTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (d *instantiatedTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (d *unionTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (d *tildeTypeDefinition) Position() token.Position { return d.scope.position(d.typ) }
+func (f *field) Position() token.Position {
+	if f.name != nil {
+		return f.scope.position(f.name)
+	}
+	return f.scope.position(f.field)
+}
+
+// doc comments
+
+func (a *astImpl) DocPositions() []token.Position { return a.scope.commentPositions(a.file.Doc) }
+func (s *importSpec) DocPositions() []token.Position { return s.scope.commentPositions(s.spec.Doc) }
+func (g *declGroup) DocPositions() []token.Position { return g.ast.scope.commentPositions(g.decl.Doc) }
+func (d *typeDeclaration) DocPositions() []token.Position { return d.scope.specDocPositions(d.decl, d.spec.Doc) }
+func (d *valueDeclaration) DocPositions() []token.Position { return d.scope.specDocPositions(d.decl, d.spec.Doc) }
+func (d *funcDeclaration) DocPositions() []token.Position { return d.scope.commentPositions(d.decl.Doc) }
+func (f *field) DocPositions() []token.Position { return f.scope.commentPositions(f.field.Doc) }
+
+// DocPositions returns the positions of the package docs from all files.
+func (p *packageImpl) DocPositions() []token.Position {
+	var res []token.Position
+	for _, file := range p.files {
+		res = append(res, file.DocPositions()...)
+	}
+	return res
+}
+
+// DocPositions returns the positions of the package docs of the
+// original AST, if it knows them.
+func (idx *Index) DocPositions() []token.Position {
+	if positioned, isPositioned := idx.ast.(DocPositioned); isPositioned {
+		return positioned.DocPositions()
+	}
+	return nil
+}
+
+// commentPositions returns the position of each comment in the group,
+// matching extractCommentGroup.
+func (s *fileScope) commentPositions(cg *ast.CommentGroup) []token.Position {
+	if cg == nil {
+		return nil
+	}
+	res := make([]token.Position, len(cg.List))
+	for i, comment := range cg.List {
+		res[i] = s.position(comment)
+	}
+	return res
+}
+
+// specDocPositions returns the positions of the comments in specDoc.
+func (s *fileScope) specDocPositions(decl *ast.GenDecl, doc *ast.CommentGroup) []token.Position {
+	if decl.Lparen.IsValid() {
+		return s.commentPositions(doc)
+	}
+	return append(s.commentPositions(decl.Doc), s.commentPositions(doc)...)
+}
+
+// expressions
+
+func (e *basicLiteral) Position() token.Position { return e.scope.position(e.lit) }
+func (e *compositeLiteral) Position() token.Position { return e.scope.position(e.lit) }
+func (e *keyValueExpression) Position() token.Position { return e.scope.position(e.expr) }
+func (e *funcLiteral) Position() token.Position { return e.scope.position(e.lit) }
+func (e *callExpression) Position() token.Position { return e.scope.position(e.expr) }
+func (e *conversionExpression) Position() token.Position { return e.scope.position(e.expr) }
+func (e *selectorExpression) Position() token.Position { return e.scope.position(e.expr) }
+func (e *indexExpression) Position() token.Position { return e.scope.position(e.expr) }
+func (e *sliceExpression) Position() token.Position { return e.scope.position(e.expr) }
+func (e *typeAssertExpression) Position() token.Position { return e.scope.position(e.expr) }
+func (e *unaryExpression) Position() token.Position { return e.scope.position(e.expr) }
+func (e *binaryExpression) Position() token.Position { return e.scope.position(e.expr) }
+func (e *parenExpression) Position() token.Position { return e.scope.position(e.expr) }
+
+// statements
+
+func (s *blockStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *expressionStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *assignStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *incDecStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *sendStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *declStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *ifStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *forStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *rangeStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *switchStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (c *caseClause) Position() token.Position { return c.scope.position(c.clause) }
+func (s *typeSwitchStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *selectStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (c *commClause) Position() token.Position { return c.scope.position(c.clause) }
+func (s *returnStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *deferStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *goStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *branchStatement) Position() token.Position { return s.scope.position(s.stmt) }
+func (s *labeledStatement) Position() token.Position { return s.scope.position(s.stmt) }
@@ -0,0 +1,603 @@
+package convert
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// fileScope holds the information shared between all the wrappers
+// converted from a single file, so that references between declarations
+// (e.g. to named constants) can be resolved.
+type fileScope struct {
+	file *ast.File
+	// fset is used to resolve positions, and may be nil
+	fset *token.FileSet
+	// pkg links this file to the other files in its package,
+	// and is nil for files converted on their own
+	pkg *packageScope
+	// typed holds the go/types information for this file,
+	// and is nil unless the package was type checked
+	typed *typeInfo
+
+	indexOnce sync.Once
+	consts map[string]*constEntry
+	types map[string]*typeEntry
+	vars map[string]*varEntry
+	funcs map[string]*funcDeclaration
+	methods map[string][]*funcDeclaration
+	// imports maps the names that imported packages are
+	// referenced by to their paths
+	imports map[string]string
+	dotImports []string
+	locals []*localScope
+
+	// evalMu guards evaluation state in consts, and is
+	// shared between all the files in a package
+	evalMu *sync.Mutex
+}
+
+func newFileScope(fset *token.FileSet, file *ast.File) *fileScope {
+	return &fileScope{
+		file: file,
+		fset: fset,
+		evalMu: &sync.Mutex{},
+	}
+}
+
+// typeEntry is a single named type, along with the
+// scope of the file that declares it.
+type typeEntry struct {
+	scope *fileScope
+	decl *ast.GenDecl
+	spec *ast.TypeSpec
+}
+
+// declaration wraps the type declaration.  Local types
+// aren't part of a top-level declaration, so have no wrapper.
+func (e *typeEntry) declaration() TypeDeclaration {
+	if e.decl == nil {
+		return nil
+	}
+	return &typeDeclaration{
+		scope: e.scope,
+		decl: e.decl,
+		spec: e.spec,
+	}
+}
+
+// varEntry is a single top-level variable, along with
+// the scope of the file that declares it.
+type varEntry struct {
+	scope *fileScope
+	spec *ast.ValueSpec
+	// index is the position of the variable's name in its spec
+	index int
+}
+
+// localScope is a part of a file (a function, or a generic type
+// declaration) that declares type names which shadow the top-level ones.
+// Type parameters are recorded with a nil spec, since they don't have
+// a backing type declaration.
+type localScope struct {
+	pos, end token.Pos
+	types map[string]*ast.TypeSpec
+}
+
+func (l *localScope) contains(pos token.Pos) bool {
+	return l.pos <= pos && pos < l.end
+}
+
+// packageScope links together the scopes of all the files in a
+// package, so that references can be resolved across files.
+type packageScope struct {
+	files []*fileScope
+	fset *token.FileSet
+
+	// importFiles loads imported packages for Resolve, and may be nil
+	importFiles func(*types.Package) []*ast.File
+	importsMu sync.Mutex
+	imports map[*types.Package]Package
+
+	indexOnce sync.Once
+	consts map[string]*constEntry
+	types map[string]*typeEntry
+	vars map[string]*varEntry
+	funcs map[string]*funcDeclaration
+	methods map[string][]*funcDeclaration
+
+	evalMu sync.Mutex
+}
+
+func newPackageScope(fset *token.FileSet, files []*ast.File) *packageScope {
+	pkg := &packageScope{fset: fset}
+	for _, file := range files {
+		pkg.files = append(pkg.files, &fileScope{
+			file: file,
+			fset: fset,
+			pkg: pkg,
+			evalMu: &pkg.evalMu,
+		})
+	}
+	return pkg
+}
+
+// index merges the top-level declarations from each file.
+func (p *packageScope) index() {
+	p.consts = make(map[string]*constEntry)
+	p.types = make(map[string]*typeEntry)
+	p.vars = make(map[string]*varEntry)
+	p.funcs = make(map[string]*funcDeclaration)
+	p.methods = make(map[string][]*funcDeclaration)
+	for _, file := range p.files {
+		file.indexOnce.Do(file.index)
+		for name, entry := range file.consts {
+			if _, exists := p.consts[name]; !exists {
+				p.consts[name] = entry
+			}
+		}
+		for name, entry := range file.types {
+			if _, exists := p.types[name]; !exists {
+				p.types[name] = entry
+			}
+		}
+		for name, entry := range file.vars {
+			if _, exists := p.vars[name]; !exists {
+				p.vars[name] = entry
+			}
+		}
+		for name, decl := range file.funcs {
+			if _, exists := p.funcs[name]; !exists {
+				p.funcs[name] = decl
+			}
+		}
+		for name, methods := range file.methods {
+			p.methods[name] = append(p.methods[name], methods...)
+		}
+	}
+}
+
+// position resolves the position of a node from this file, returning
+// the zero (invalid) Position if the FileSet is unknown.  It's safe to
+// call on a nil scope.
+func (s *fileScope) position(node ast.Node) token.Position {
+	if s == nil || s.fset == nil {
+		return token.Position{}
+	}
+	return s.fset.Position(node.Pos())
+}
+
+// index collects the top-level declarations in the file,
+// as well as any local scopes that shadow them.
+func (s *fileScope) index() {
+	s.consts = make(map[string]*constEntry)
+	s.types = make(map[string]*typeEntry)
+	s.vars = make(map[string]*varEntry)
+	s.funcs = make(map[string]*funcDeclaration)
+	s.methods = make(map[string][]*funcDeclaration)
+	s.imports = make(map[string]string)
+
+	// scopes for types converted from go/types have no file
+	if s.file == nil {
+		return
+	}
+
+	s.indexImports()
+
+	for _, decl := range s.file.Decls {
+		switch typedDecl := decl.(type) {
+		case *ast.FuncDecl:
+			s.indexFunc(typedDecl)
+			if name := receiverTypeName(typedDecl); name != "" {
+				s.methods[name] = append(s.methods[name], &funcDeclaration{scope: s, decl: typedDecl})
+			} else if typedDecl.Recv == nil && typedDecl.Name.Name != "_" && typedDecl.Name.Name != "init" {
+				s.funcs[typedDecl.Name.Name] = &funcDeclaration{scope: s, decl: typedDecl}
+			}
+		case *ast.GenDecl:
+			switch typedDecl.Tok {
+			case token.TYPE:
+				for _, spec := range typedDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					if typeSpec.Name.Name != "_" {
+						s.types[typeSpec.Name.Name] = &typeEntry{scope: s, decl: typedDecl, spec: typeSpec}
+					}
+					s.indexTypeParams(typeSpec, typeSpec.TypeParams)
+				}
+			case token.VAR:
+				for _, spec := range typedDecl.Specs {
+					valueSpec := spec.(*ast.ValueSpec)
+					for i, name := range valueSpec.Names {
+						if name.Name != "_" {
+							s.vars[name.Name] = &varEntry{scope: s, spec: valueSpec, index: i}
+						}
+					}
+				}
+			case token.CONST:
+				for _, entry := range constGroupEntries(typedDecl) {
+					entry.scope = s
+					if entry.name.Name == "_" {
+						// blank constants can't be referenced
+						continue
+					}
+					s.consts[entry.name.Name] = entry
+				}
+			}
+		}
+	}
+}
+
+// indexTypeParams records the type parameters in the given list as
+// shadowing top-level types within node.
+func (s *fileScope) indexTypeParams(node ast.Node, params *ast.FieldList) {
+	if params == nil || len(params.List) == 0 {
+		return
+	}
+	local := &localScope{pos: node.Pos(), end: node.End(), types: make(map[string]*ast.TypeSpec)}
+	for _, param := range params.List {
+		for _, name := range param.Names {
+			local.types[name.Name] = nil
+		}
+	}
+	s.locals = append(s.locals, local)
+}
+
+// indexFunc records the type parameters of a function (including those
+// declared by a method's receiver), and the types declared in its body.
+func (s *fileScope) indexFunc(decl *ast.FuncDecl) {
+	s.indexTypeParams(decl, decl.Type.TypeParams)
+
+	if decl.Recv != nil && len(decl.Recv.List) > 0 {
+		recvType := decl.Recv.List[0].Type
+		if star, isStar := recvType.(*ast.StarExpr); isStar {
+			recvType = star.X
+		}
+		var params []ast.Expr
+		switch typed := recvType.(type) {
+		case *ast.IndexExpr:
+			params = []ast.Expr{typed.Index}
+		case *ast.IndexListExpr:
+			params = typed.Indices
+		}
+		if len(params) > 0 {
+			local := &localScope{pos: decl.Pos(), end: decl.End(), types: make(map[string]*ast.TypeSpec)}
+			for _, param := range params {
+				if name, isIdent := param.(*ast.Ident); isIdent {
+					local.types[name.Name] = nil
+				}
+			}
+			s.locals = append(s.locals, local)
+		}
+	}
+
+	if decl.Body == nil {
+		return
+	}
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		// local types are in scope from their name to the end
+		// of the innermost block (or case clause) containing them
+		var stmts []ast.Stmt
+		switch typed := node.(type) {
+		case *ast.BlockStmt:
+			stmts = typed.List
+		case *ast.CaseClause:
+			stmts = typed.Body
+		case *ast.CommClause:
+			stmts = typed.Body
+		}
+		for _, stmt := range stmts {
+			declStmt, isDecl := stmt.(*ast.DeclStmt)
+			if !isDecl {
+				continue
+			}
+			genDecl, isGenDecl := declStmt.Decl.(*ast.GenDecl)
+			if !isGenDecl || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				s.locals = append(s.locals, &localScope{
+					pos: typeSpec.Name.Pos(),
+					end: node.End(),
+					types: map[string]*ast.TypeSpec{typeSpec.Name.Name: typeSpec},
+				})
+				s.indexTypeParams(typeSpec, typeSpec.TypeParams)
+			}
+		}
+		return true
+	})
+}
+
+// receiverTypeName returns the name of the type that the given method is
+// declared on, or the empty string if it's not a method.
+func receiverTypeName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return ""
+	}
+	expr := decl.Recv.List[0].Type
+	for {
+		switch typed := expr.(type) {
+		case *ast.StarExpr:
+			expr = typed.X
+		case *ast.ParenExpr:
+			expr = typed.X
+		case *ast.IndexExpr:
+			expr = typed.X
+		case *ast.IndexListExpr:
+			expr = typed.X
+		case *ast.Ident:
+			return typed.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// lookupMethods finds the methods declared on the top-level type with
+// the given name in this file, or its whole package.
+func (s *fileScope) lookupMethods(name string) []*funcDeclaration {
+	if s == nil {
+		return nil
+	}
+	s.indexOnce.Do(s.index)
+	if s.pkg == nil {
+		return s.methods[name]
+	}
+	s.pkg.indexOnce.Do(s.pkg.index)
+	return s.pkg.methods[name]
+}
+
+// lookupConst finds the top-level constant with the given name in this
+// file or its package, returning nil if no such constant exists.
+func (s *fileScope) lookupConst(name string) *constEntry {
+	if s == nil {
+		return nil
+	}
+	s.indexOnce.Do(s.index)
+	if entry, exists := s.consts[name]; exists || s.pkg == nil {
+		return entry
+	}
+	s.pkg.indexOnce.Do(s.pkg.index)
+	return s.pkg.consts[name]
+}
+
+// lookupVar finds the top-level variable with the given name in this
+// file or its package, returning nil if no such variable exists.
+func (s *fileScope) lookupVar(name string) *varEntry {
+	if s == nil {
+		return nil
+	}
+	s.indexOnce.Do(s.index)
+	if entry, exists := s.vars[name]; exists || s.pkg == nil {
+		return entry
+	}
+	s.pkg.indexOnce.Do(s.pkg.index)
+	return s.pkg.vars[name]
+}
+
+// lookupFunc finds the top-level function (not method) with the given
+// name in this file or its package, returning nil if no such function
+// exists.
+func (s *fileScope) lookupFunc(name string) *funcDeclaration {
+	if s == nil {
+		return nil
+	}
+	s.indexOnce.Do(s.index)
+	if decl, exists := s.funcs[name]; exists || s.pkg == nil {
+		return decl
+	}
+	s.pkg.indexOnce.Do(s.pkg.index)
+	return s.pkg.funcs[name]
+}
+
+// lookupType finds the top-level type declaration with the given name in
+// this file or its package, returning nil if no such type exists.
+func (s *fileScope) lookupType(name string) *typeEntry {
+	if s == nil {
+		return nil
+	}
+	s.indexOnce.Do(s.index)
+	if entry, exists := s.types[name]; exists || s.pkg == nil {
+		return entry
+	}
+	s.pkg.indexOnce.Do(s.pkg.index)
+	return s.pkg.types[name]
+}
+
+// resolveType finds the type declaration that the given identifier refers
+// to, taking into account local types and type parameters that shadow
+// top-level types.  It returns nil if the identifier doesn't refer to a
+// declared type (e.g. it's predeclared, or a type parameter).
+func (s *fileScope) resolveType(id *ast.Ident) *typeEntry {
+	if s == nil {
+		return nil
+	}
+	s.indexOnce.Do(s.index)
+
+	// type checking tells us exactly what the identifier refers to
+	if obj := s.objectOf(id); obj != nil {
+		typeName, isTypeName := obj.(*types.TypeName)
+		if !isTypeName || typeName.Pkg() == nil || typeName.Pkg() != s.typed.pkg {
+			return nil
+		}
+		if typeName.Parent() == typeName.Pkg().Scope() {
+			return s.lookupType(id.Name)
+		}
+		if !id.Pos().IsValid() {
+			// a local type converted from go/types
+			return nil
+		}
+	}
+
+	if innermost := s.innermostLocal(id); innermost != nil {
+		spec := innermost.types[id.Name]
+		if spec == nil {
+			return nil
+		}
+		return &typeEntry{scope: s, spec: spec}
+	}
+	return s.lookupType(id.Name)
+}
+
+// indexImports records the names that imported packages are referenced by.
+func (s *fileScope) indexImports() {
+	// explicit names take precedence over guessed ones
+	var unnamed []*ast.ImportSpec
+	for _, spec := range s.file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := s.importName(spec)
+		switch name {
+		case "":
+			unnamed = append(unnamed, spec)
+		case "_":
+			// blank imports can't be referenced
+		case ".":
+			s.dotImports = append(s.dotImports, importPath)
+		default:
+			s.imports[name] = importPath
+		}
+	}
+
+	for _, spec := range unnamed {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		for _, name := range assumedPackageNames(importPath) {
+			if _, exists := s.imports[name]; !exists {
+				s.imports[name] = importPath
+			}
+		}
+	}
+}
+
+// innermostLocal finds the innermost local scope that declares
+// the given identifier, or nil if it's not declared locally.
+func (s *fileScope) innermostLocal(id *ast.Ident) *localScope {
+	if !id.Pos().IsValid() {
+		return nil
+	}
+	// local scopes are nested, so the innermost
+	// one is the one that starts last
+	var innermost *localScope
+	for _, local := range s.locals {
+		if _, declares := local.types[id.Name]; !declares || !local.contains(id.Pos()) {
+			continue
+		}
+		if innermost == nil || local.pos > innermost.pos {
+			innermost = local
+		}
+	}
+	return innermost
+}
+
+// isImportName checks if the given name refers to an imported package.
+func (s *fileScope) isImportName(name string) bool {
+	return s.importPath(name) != ""
+}
+
+// importPath returns the path of the package imported with the given
+// name, or the empty string if there isn't one.
+func (s *fileScope) importPath(name string) string {
+	if s == nil {
+		return ""
+	}
+	s.indexOnce.Do(s.index)
+	return s.imports[name]
+}
+
+// importName returns the name that an import is referenced by in the
+// importing file ("." for dot imports, and "_" for blank imports).  The
+// name of an unnamed import comes from the package clause of the imported
+// package, which is only known if the file was type checked, so the empty
+// string is returned otherwise.
+func (s *fileScope) importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	if s.typed == nil {
+		return ""
+	}
+	if s.typed.info != nil {
+		if pkgName, isPkgName := s.typed.info.Implicits[spec].(*types.PkgName); isPkgName {
+			return pkgName.Imported().Name()
+		}
+	}
+	if s.typed.pkg != nil {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return ""
+		}
+		for _, imported := range s.typed.pkg.Imports() {
+			if imported.Path() == importPath {
+				return imported.Name()
+			}
+		}
+	}
+	return ""
+}
+
+// assumedPackageNames returns the names that a package might declare in
+// its package clause, judging by its import path: the last element of the
+// path, and that element without any version (`gopkg.in/yaml.v2`) or `go-`
+// prefix, or the element before a major version (`example.com/foo/v2`).
+func assumedPackageNames(importPath string) []string {
+	base := path.Base(importPath)
+	res := []string{base}
+	guess := base
+	if len(base) > 1 && base[0] == 'v' && isDigits(base[1:]) {
+		if dir := path.Dir(importPath); dir != "." {
+			guess = path.Base(dir)
+		}
+	}
+	guess = strings.TrimPrefix(guess, "go-")
+	if i := strings.IndexFunc(guess, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i > 0 {
+		guess = guess[:i]
+	}
+	if guess != base {
+		res = append(res, guess)
+	}
+	return res
+}
+
+func isDigits(str string) bool {
+	for _, r := range str {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return str != ""
+}
+
+// dotImportPath returns the path of the dot-imported package that the
+// given identifier refers to, or the empty string if it's not from a
+// dot import.  Without type information, only identifiers naming types
+// can be resolved (other identifiers might be local variables), and
+// only if the file has a single dot import.
+func (s *fileScope) dotImportPath(id *ast.Ident, isType bool) string {
+	if s == nil {
+		return ""
+	}
+	if obj := s.objectOf(id); obj != nil {
+		if obj.Pkg() == nil || obj.Pkg() == s.typed.pkg || obj.Parent() != obj.Pkg().Scope() {
+			return ""
+		}
+		return obj.Pkg().Path()
+	}
+
+	s.indexOnce.Do(s.index)
+	if !isType || len(s.dotImports) != 1 || types.Universe.Lookup(id.Name) != nil {
+		return ""
+	}
+	if s.innermostLocal(id) != nil || s.lookupType(id.Name) != nil {
+		return ""
+	}
+	return s.dotImports[0]
+}
@@ -0,0 +1,511 @@
+package convert
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// stmtToStatement converts a statement into one of the
+// statement structs.  Empty statements are converted to nil.
+func stmtToStatement(s *fileScope, stmt ast.Stmt) Statement {
+	switch typed := stmt.(type) {
+	case nil, *ast.EmptyStmt:
+		return nil
+	case *ast.BlockStmt:
+		return &blockStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.ExprStmt:
+		return &expressionStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.AssignStmt:
+		return &assignStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.IncDecStmt:
+		return &incDecStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.SendStmt:
+		return &sendStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.DeclStmt:
+		return &declStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.IfStmt:
+		return &ifStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.ForStmt:
+		return &forStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.RangeStmt:
+		return &rangeStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.SwitchStmt:
+		return &switchStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.TypeSwitchStmt:
+		return &typeSwitchStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.SelectStmt:
+		return &selectStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.ReturnStmt:
+		return &returnStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.DeferStmt:
+		return &deferStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.GoStmt:
+		return &goStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.BranchStmt:
+		return &branchStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.LabeledStmt:
+		return &labeledStatement{
+			scope: s,
+			stmt: typed,
+		}
+	case *ast.BadStmt:
+		panic(conversionError(typed, "malformed statement"))
+	}
+
+	panic(conversionError(stmt, "unknown/invalid statement %T", stmt))
+}
+
+// stmtsToStatements converts a list of statements, skipping empty statements.
+func stmtsToStatements(s *fileScope, stmts []ast.Stmt) []Statement {
+	res := make([]Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		if converted := stmtToStatement(s, stmt); converted != nil {
+			res = append(res, converted)
+		}
+	}
+	return res
+}
+
+// blockToBlockStatement converts a possibly-nil block.
+func blockToBlockStatement(s *fileScope, block *ast.BlockStmt) BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return &blockStatement{
+		scope: s,
+		stmt: block,
+	}
+}
+
+// identOrNil converts a possibly-nil identifier.
+func identOrNil(ident *ast.Ident) Ident {
+	if ident == nil {
+		return nil
+	}
+	return unqualifiedIdent(ident.Name)
+}
+
+type blockStatement struct {
+	scope *fileScope
+	stmt *ast.BlockStmt
+}
+
+func (s *blockStatement) Statements() []Statement {
+	return stmtsToStatements(s.scope, s.stmt.List)
+}
+func (s *blockStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type expressionStatement struct {
+	scope *fileScope
+	stmt *ast.ExprStmt
+}
+
+func (s *expressionStatement) Expression() Expression {
+	return exprToExpression(s.scope, s.stmt.X)
+}
+func (s *expressionStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type assignStatement struct {
+	scope *fileScope
+	stmt *ast.AssignStmt
+}
+
+func (s *assignStatement) Left() []Expression {
+	return exprsToExpressions(s.scope, s.stmt.Lhs)
+}
+func (s *assignStatement) Op() token.Token {
+	return s.stmt.Tok
+}
+func (s *assignStatement) Right() []Expression {
+	return exprsToExpressions(s.scope, s.stmt.Rhs)
+}
+func (s *assignStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type incDecStatement struct {
+	scope *fileScope
+	stmt *ast.IncDecStmt
+}
+
+func (s *incDecStatement) Target() Expression {
+	return exprToExpression(s.scope, s.stmt.X)
+}
+func (s *incDecStatement) IsIncrement() bool {
+	return s.stmt.Tok == token.INC
+}
+func (s *incDecStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type sendStatement struct {
+	scope *fileScope
+	stmt *ast.SendStmt
+}
+
+func (s *sendStatement) Channel() Expression {
+	return exprToExpression(s.scope, s.stmt.Chan)
+}
+func (s *sendStatement) SentValue() Expression {
+	return exprToExpression(s.scope, s.stmt.Value)
+}
+func (s *sendStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type declStatement struct {
+	scope *fileScope
+	stmt *ast.DeclStmt
+}
+
+func (s *declStatement) Declarations() []Declaration {
+	genDecl, isGenDecl := s.stmt.Decl.(*ast.GenDecl)
+	if !isGenDecl {
+		panic(conversionError(s.stmt.Decl, "malformed declaration"))
+	}
+	var res []Declaration
+	if genDecl.Tok == token.TYPE {
+		eachTypeDeclaration(s.scope, genDecl, func(decl *typeDeclaration) {
+			res = append(res, decl)
+		})
+		return res
+	}
+	eachValueDeclaration(s.scope, genDecl, func(decl *valueDeclaration) {
+		res = append(res, decl)
+	})
+	return res
+}
+func (s *declStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type ifStatement struct {
+	scope *fileScope
+	stmt *ast.IfStmt
+}
+
+func (s *ifStatement) Init() Statement {
+	return stmtToStatement(s.scope, s.stmt.Init)
+}
+func (s *ifStatement) Cond() Expression {
+	return exprToExpression(s.scope, s.stmt.Cond)
+}
+func (s *ifStatement) Body() BlockStatement {
+	return blockToBlockStatement(s.scope, s.stmt.Body)
+}
+func (s *ifStatement) ElseBranch() Statement {
+	return stmtToStatement(s.scope, s.stmt.Else)
+}
+func (s *ifStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type forStatement struct {
+	scope *fileScope
+	stmt *ast.ForStmt
+}
+
+func (s *forStatement) Init() Statement {
+	return stmtToStatement(s.scope, s.stmt.Init)
+}
+func (s *forStatement) Cond() Expression {
+	return exprToExpression(s.scope, s.stmt.Cond)
+}
+func (s *forStatement) Post() Statement {
+	return stmtToStatement(s.scope, s.stmt.Post)
+}
+func (s *forStatement) Body() BlockStatement {
+	return blockToBlockStatement(s.scope, s.stmt.Body)
+}
+func (s *forStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type rangeStatement struct {
+	scope *fileScope
+	stmt *ast.RangeStmt
+}
+
+func (s *rangeStatement) Key() Expression {
+	return exprToExpression(s.scope, s.stmt.Key)
+}
+func (s *rangeStatement) Value() Expression {
+	return exprToExpression(s.scope, s.stmt.Value)
+}
+func (s *rangeStatement) IsDefine() bool {
+	return s.stmt.Tok == token.DEFINE
+}
+func (s *rangeStatement) Range() Expression {
+	return exprToExpression(s.scope, s.stmt.X)
+}
+func (s *rangeStatement) Body() BlockStatement {
+	return blockToBlockStatement(s.scope, s.stmt.Body)
+}
+func (s *rangeStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type switchStatement struct {
+	scope *fileScope
+	stmt *ast.SwitchStmt
+}
+
+func (s *switchStatement) Init() Statement {
+	return stmtToStatement(s.scope, s.stmt.Init)
+}
+func (s *switchStatement) Tag() Expression {
+	return exprToExpression(s.scope, s.stmt.Tag)
+}
+func (s *switchStatement) Clauses() []CaseClause {
+	res := make([]CaseClause, len(s.stmt.Body.List))
+	for i, clause := range s.stmt.Body.List {
+		res[i] = &caseClause{
+			scope: s.scope,
+			clause: clause.(*ast.CaseClause),
+		}
+	}
+	return res
+}
+func (s *switchStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+// caseClause is used for both expression and type switches
+type caseClause struct {
+	scope *fileScope
+	clause *ast.CaseClause
+}
+
+func (c *caseClause) IsDefault() bool {
+	return c.clause.List == nil
+}
+func (c *caseClause) Values() []Expression {
+	return exprsToExpressions(c.scope, c.clause.List)
+}
+func (c *caseClause) Types() []TypeDefinition {
+	res := make([]TypeDefinition, len(c.clause.List))
+	for i, typ := range c.clause.List {
+		res[i] = exprToTypeDefinition(c.scope, typ)
+	}
+	return res
+}
+func (c *caseClause) Statements() []Statement {
+	return stmtsToStatements(c.scope, c.clause.Body)
+}
+func (c *caseClause) ToRawNode() interface{} {
+	return c.clause
+}
+
+type typeSwitchStatement struct {
+	scope *fileScope
+	stmt *ast.TypeSwitchStmt
+}
+
+func (s *typeSwitchStatement) Init() Statement {
+	return stmtToStatement(s.scope, s.stmt.Init)
+}
+
+// guard returns the `x.(type)` expression from the switch's
+// guard statement, as well as the bound variable name, if any.
+func (s *typeSwitchStatement) guard() (*ast.Ident, *ast.TypeAssertExpr) {
+	switch typed := s.stmt.Assign.(type) {
+	case *ast.AssignStmt:
+		// `switch v := x.(type)`
+		binding, isIdent := typed.Lhs[0].(*ast.Ident)
+		assert, isAssert := typed.Rhs[0].(*ast.TypeAssertExpr)
+		if isIdent && isAssert {
+			return binding, assert
+		}
+	case *ast.ExprStmt:
+		// `switch x.(type)`
+		if assert, isAssert := typed.X.(*ast.TypeAssertExpr); isAssert {
+			return nil, assert
+		}
+	}
+	panic(conversionError(s.stmt.Assign, "invalid type switch guard"))
+}
+func (s *typeSwitchStatement) Binding() Ident {
+	binding, _ := s.guard()
+	return identOrNil(binding)
+}
+func (s *typeSwitchStatement) Subject() Expression {
+	_, assert := s.guard()
+	return exprToExpression(s.scope, assert.X)
+}
+func (s *typeSwitchStatement) Clauses() []TypeCaseClause {
+	res := make([]TypeCaseClause, len(s.stmt.Body.List))
+	for i, clause := range s.stmt.Body.List {
+		res[i] = &caseClause{
+			scope: s.scope,
+			clause: clause.(*ast.CaseClause),
+		}
+	}
+	return res
+}
+func (s *typeSwitchStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type selectStatement struct {
+	scope *fileScope
+	stmt *ast.SelectStmt
+}
+
+func (s *selectStatement) Clauses() []CommClause {
+	res := make([]CommClause, len(s.stmt.Body.List))
+	for i, clause := range s.stmt.Body.List {
+		res[i] = &commClause{
+			scope: s.scope,
+			clause: clause.(*ast.CommClause),
+		}
+	}
+	return res
+}
+func (s *selectStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type commClause struct {
+	scope *fileScope
+	clause *ast.CommClause
+}
+
+func (c *commClause) Comm() Statement {
+	return stmtToStatement(c.scope, c.clause.Comm)
+}
+func (c *commClause) Statements() []Statement {
+	return stmtsToStatements(c.scope, c.clause.Body)
+}
+func (c *commClause) ToRawNode() interface{} {
+	return c.clause
+}
+
+type returnStatement struct {
+	scope *fileScope
+	stmt *ast.ReturnStmt
+}
+
+func (s *returnStatement) Results() []Expression {
+	return exprsToExpressions(s.scope, s.stmt.Results)
+}
+func (s *returnStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type deferStatement struct {
+	scope *fileScope
+	stmt *ast.DeferStmt
+}
+
+func (s *deferStatement) Call() CallExpression {
+	return &callExpression{
+		scope: s.scope,
+		expr: s.stmt.Call,
+	}
+}
+func (s *deferStatement) IsDefer() struct{} {
+	return struct{}{}
+}
+func (s *deferStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type goStatement struct {
+	scope *fileScope
+	stmt *ast.GoStmt
+}
+
+func (s *goStatement) Call() CallExpression {
+	return &callExpression{
+		scope: s.scope,
+		expr: s.stmt.Call,
+	}
+}
+func (s *goStatement) IsGo() struct{} {
+	return struct{}{}
+}
+func (s *goStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type branchStatement struct {
+	scope *fileScope
+	stmt *ast.BranchStmt
+}
+
+func (s *branchStatement) Keyword() token.Token {
+	return s.stmt.Tok
+}
+func (s *branchStatement) Label() Ident {
+	return identOrNil(s.stmt.Label)
+}
+func (s *branchStatement) ToRawNode() interface{} {
+	return s.stmt
+}
+
+type labeledStatement struct {
+	scope *fileScope
+	stmt *ast.LabeledStmt
+}
+
+func (s *labeledStatement) Label() Ident {
+	return unqualifiedIdent(s.stmt.Label.Name)
+}
+func (s *labeledStatement) Statement() Statement {
+	return stmtToStatement(s.scope, s.stmt.Stmt)
+}
+func (s *labeledStatement) ToRawNode() interface{} {
+	return s.stmt
+}
@@ -0,0 +1,200 @@
+package convert
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// StructTag is a parsed struct tag, in the conventional format of
+// space-separated `key:"value"` pairs, like `json:"name,omitempty"`.
+// The order of the keys is preserved.  StructTags are immutable: the
+// editing methods return modified copies.  The zero value is an empty tag.
+type StructTag struct {
+	entries []tagEntry
+}
+
+type tagEntry struct {
+	key, value string
+}
+
+// ParseStructTag parses a struct tag, as returned by Field.Tag (i.e.
+// without the surrounding quotes).  It returns an error if the tag
+// isn't in the conventional format.
+func ParseStructTag(tag reflect.StructTag) (StructTag, error) {
+	var res StructTag
+	rest := string(tag)
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		if rest == "" {
+			return res, nil
+		}
+
+		// keys are any non-control characters other than space, quote, and colon
+		i := 0
+		for i < len(rest) && rest[i] > ' ' && rest[i] != ':' && rest[i] != '"' && rest[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(rest) || rest[i] != ':' || rest[i+1] != '"' {
+			return StructTag{}, fmt.Errorf("invalid struct tag %q: expected key:\"value\" at %q", string(tag), rest)
+		}
+		key := rest[:i]
+		rest = rest[i+1:]
+
+		// find the end of the quoted value
+		i = 1
+		for i < len(rest) && rest[i] != '"' {
+			if rest[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(rest) {
+			return StructTag{}, fmt.Errorf("invalid struct tag %q: unterminated value for key %q", string(tag), key)
+		}
+		value, err := strconv.Unquote(rest[:i+1])
+		if err != nil {
+			return StructTag{}, fmt.Errorf("invalid struct tag %q: bad value for key %q: %v", string(tag), key, err)
+		}
+		rest = rest[i+1:]
+
+		res.entries = append(res.entries, tagEntry{key: key, value: value})
+	}
+}
+
+// Keys returns the keys of the tag, in order.
+func (t StructTag) Keys() []string {
+	res := make([]string, len(t.entries))
+	for i, entry := range t.entries {
+		res[i] = entry.key
+	}
+	return res
+}
+
+// Lookup returns the value for the given key, and whether the key is present.
+func (t StructTag) Lookup(key string) (string, bool) {
+	for _, entry := range t.entries {
+		if entry.key == key {
+			return entry.value, true
+		}
+	}
+	return "", false
+}
+
+// Get returns the value for the given key, or the empty string.
+func (t StructTag) Get(key string) string {
+	value, _ := t.Lookup(key)
+	return value
+}
+
+// Name returns the part of the value for the given key before the first
+// comma, which is conventionally a name (e.g. `name` in `json:"name,omitempty"`).
+func (t StructTag) Name(key string) string {
+	name, _, _ := strings.Cut(t.Get(key), ",")
+	return name
+}
+
+// Options returns the comma-separated options following the name in
+// the value for the given key (e.g. `omitempty` in `json:"name,omitempty"`).
+func (t StructTag) Options(key string) []string {
+	_, options, hasOptions := strings.Cut(t.Get(key), ",")
+	if !hasOptions {
+		return nil
+	}
+	return strings.Split(options, ",")
+}
+
+// HasOption checks if the value for the given key has the given option.
+func (t StructTag) HasOption(key, option string) bool {
+	for _, opt := range t.Options(key) {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Set sets the value for the given key, replacing any existing value
+// in place, or adding the key to the end of the tag.
+func (t StructTag) Set(key, value string) StructTag {
+	res := StructTag{entries: make([]tagEntry, 0, len(t.entries)+1)}
+	found := false
+	for _, entry := range t.entries {
+		if entry.key == key {
+			entry.value = value
+			found = true
+		}
+		res.entries = append(res.entries, entry)
+	}
+	if !found {
+		res.entries = append(res.entries, tagEntry{key: key, value: value})
+	}
+	return res
+}
+
+// SetName sets the name for the given key, keeping its options.
+func (t StructTag) SetName(key, name string) StructTag {
+	return t.Set(key, joinTagValue(name, t.Options(key)))
+}
+
+// AddOption adds an option to the value for the given key,
+// if it's not already present.
+func (t StructTag) AddOption(key, option string) StructTag {
+	if t.HasOption(key, option) {
+		return t
+	}
+	return t.Set(key, joinTagValue(t.Name(key), append(t.Options(key), option)))
+}
+
+// RemoveOption removes an option from the value for the given key.
+func (t StructTag) RemoveOption(key, option string) StructTag {
+	if !t.HasOption(key, option) {
+		return t
+	}
+	var options []string
+	for _, opt := range t.Options(key) {
+		if opt != option {
+			options = append(options, opt)
+		}
+	}
+	return t.Set(key, joinTagValue(t.Name(key), options))
+}
+
+// Delete removes the given key from the tag.
+func (t StructTag) Delete(key string) StructTag {
+	var res StructTag
+	for _, entry := range t.entries {
+		if entry.key != key {
+			res.entries = append(res.entries, entry)
+		}
+	}
+	return res
+}
+
+// String formats the tag, quoting and escaping the values.
+func (t StructTag) String() string {
+	parts := make([]string, len(t.entries))
+	for i, entry := range t.entries {
+		parts[i] = entry.key + ":" + strconv.Quote(entry.value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// StructTag formats the tag for use as the result of Field.Tag.
+func (t StructTag) StructTag() reflect.StructTag {
+	return reflect.StructTag(t.String())
+}
+
+func joinTagValue(name string, options []string) string {
+	return strings.Join(append([]string{name}, options...), ",")
+}
+
+// QuoteStructTag quotes a struct tag (as returned by Field.Tag) as a Go
+// string literal, preferring a raw (backquoted) string when possible.
+func QuoteStructTag(tag reflect.StructTag) string {
+	if strconv.CanBackquote(string(tag)) {
+		return "`" + string(tag) + "`"
+	}
+	return strconv.Quote(string(tag))
+}
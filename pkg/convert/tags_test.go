@@ -0,0 +1,295 @@
+package convert_test
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// describeTag formats a parsed tag's entries as `key=value` pairs, so
+// that tests see exactly what was parsed rather than the re-quoted form.
+func describeTag(tag convert.StructTag) []string {
+	var res []string
+	for _, key := range tag.Keys() {
+		res = append(res, key+"="+tag.Get(key))
+	}
+	return res
+}
+
+func TestParseStructTag(t *testing.T) {
+	cases := []struct {
+		tag      string
+		expected []string
+	}{
+		{tag: "", expected: nil},
+		{tag: "   ", expected: nil},
+		{tag: `json:"name"`, expected: []string{"json=name"}},
+		{tag: `json:"name,omitempty" yaml:"name"`, expected: []string{"json=name,omitempty", "yaml=name"}},
+		{tag: `  json:"a"   yaml:"b"  `, expected: []string{"json=a", "yaml=b"}},
+		{tag: `json:""`, expected: []string{"json="}},
+		{tag: `a.b-c/d:"x"`, expected: []string{"a.b-c/d=x"}},
+		// escapes in values are unquoted
+		{tag: `doc:"say \"hi\""`, expected: []string{`doc=say "hi"`}},
+		{tag: `doc:"a\\b" json:"c"`, expected: []string{`doc=a\b`, "json=c"}},
+		{tag: `doc:"tab\there"`, expected: []string{"doc=tab\there"}},
+		// like reflect.StructTag, spaces between pairs are optional
+		{tag: `json:"a"yaml:"b"`, expected: []string{"json=a", "yaml=b"}},
+		// duplicate keys are kept, in order
+		{tag: `json:"a" yaml:"b" json:"c"`, expected: []string{"json=a", "yaml=b", "json=a"}},
+	}
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			tag, err := convert.ParseStructTag(reflect.StructTag(c.tag))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := describeTag(tag); !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseStructTagErrors(t *testing.T) {
+	cases := []struct {
+		tag string
+		err string
+	}{
+		{tag: `json:"name`, err: `unterminated value for key "json"`},
+		{tag: `json:"name\"`, err: `unterminated value for key "json"`},
+		{tag: `json:"a" yaml:"b`, err: `unterminated value for key "yaml"`},
+		{tag: `json`, err: `expected key:"value" at "json"`},
+		{tag: `json:name`, err: `expected key:"value" at "json:name"`},
+		{tag: `json "name"`, err: `expected key:"value" at "json \"name\""`},
+		{tag: `:"name"`, err: `expected key:"value"`},
+		{tag: `json:"a" yaml`, err: `expected key:"value" at "yaml"`},
+		{tag: `json:"\q"`, err: `bad value for key "json"`},
+	}
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			_, err := convert.ParseStructTag(reflect.StructTag(c.tag))
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("expected an error containing %q, got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestStructTagAccessors(t *testing.T) {
+	tag, err := convert.ParseStructTag(`json:"name,omitempty,string" yaml:"-" xml:""`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, present := tag.Lookup("xml"); !present || value != "" {
+		t.Errorf("expected xml to be present and empty, got %q (present: %v)", value, present)
+	}
+	if _, present := tag.Lookup("toml"); present {
+		t.Errorf("expected toml to be missing")
+	}
+	if name := tag.Name("json"); name != "name" {
+		t.Errorf("expected json name %q, got %q", "name", name)
+	}
+	if options := tag.Options("json"); !reflect.DeepEqual(options, []string{"omitempty", "string"}) {
+		t.Errorf("expected json options [omitempty string], got %q", options)
+	}
+	if options := tag.Options("yaml"); options != nil {
+		t.Errorf("expected no yaml options, got %q", options)
+	}
+	if !tag.HasOption("json", "omitempty") || tag.HasOption("json", "name") || tag.HasOption("yaml", "omitempty") {
+		t.Errorf("unexpected results from HasOption on %s", tag)
+	}
+}
+
+func TestStructTagEdits(t *testing.T) {
+	cases := []struct {
+		name     string
+		tag      string
+		edit     func(convert.StructTag) convert.StructTag
+		expected string
+	}{
+		{
+			name:     "set replaces in place",
+			tag:      `json:"a" yaml:"b" xml:"c"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.Set("yaml", "z") },
+			expected: `json:"a" yaml:"z" xml:"c"`,
+		},
+		{
+			name:     "set appends new keys",
+			tag:      `json:"a" yaml:"b"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.Set("xml", "c") },
+			expected: `json:"a" yaml:"b" xml:"c"`,
+		},
+		{
+			name:     "set on an empty tag",
+			tag:      ``,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.Set("json", "a") },
+			expected: `json:"a"`,
+		},
+		{
+			name:     "set replaces every duplicate",
+			tag:      `json:"a" yaml:"b" json:"c"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.Set("json", "z") },
+			expected: `json:"z" yaml:"b" json:"z"`,
+		},
+		{
+			name:     "delete keeps the order of the rest",
+			tag:      `json:"a" yaml:"b" xml:"c" toml:"d"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.Delete("yaml") },
+			expected: `json:"a" xml:"c" toml:"d"`,
+		},
+		{
+			name:     "delete removes every duplicate",
+			tag:      `json:"a" yaml:"b" json:"c"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.Delete("json") },
+			expected: `yaml:"b"`,
+		},
+		{
+			name:     "delete a missing key",
+			tag:      `json:"a"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.Delete("yaml") },
+			expected: `json:"a"`,
+		},
+		{
+			name:     "delete then set moves the key to the end",
+			tag:      `json:"a" yaml:"b" xml:"c"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.Delete("json").Set("json", "a") },
+			expected: `yaml:"b" xml:"c" json:"a"`,
+		},
+		{
+			name:     "set name keeps options",
+			tag:      `json:"name,omitempty"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.SetName("json", "other") },
+			expected: `json:"other,omitempty"`,
+		},
+		{
+			name:     "add option",
+			tag:      `json:"name"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.AddOption("json", "omitempty") },
+			expected: `json:"name,omitempty"`,
+		},
+		{
+			name:     "add an existing option",
+			tag:      `json:"name,omitempty"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.AddOption("json", "omitempty") },
+			expected: `json:"name,omitempty"`,
+		},
+		{
+			name:     "add a second option",
+			tag:      `json:"name,omitempty" yaml:"name"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.AddOption("json", "string") },
+			expected: `json:"name,omitempty,string" yaml:"name"`,
+		},
+		{
+			name:     "add option to a missing key",
+			tag:      `yaml:"name"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.AddOption("json", "inline") },
+			expected: `yaml:"name" json:",inline"`,
+		},
+		{
+			name:     "remove option",
+			tag:      `json:"name,omitempty"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.RemoveOption("json", "omitempty") },
+			expected: `json:"name"`,
+		},
+		{
+			name:     "remove a middle option",
+			tag:      `json:"name,omitempty,string"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.RemoveOption("json", "omitempty") },
+			expected: `json:"name,string"`,
+		},
+		{
+			name:     "remove a missing option",
+			tag:      `json:"name,omitempty"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.RemoveOption("json", "string") },
+			expected: `json:"name,omitempty"`,
+		},
+		{
+			name:     "the name is not an option",
+			tag:      `json:"omitempty"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.RemoveOption("json", "omitempty") },
+			expected: `json:"omitempty"`,
+		},
+		{
+			name:     "values are re-escaped",
+			tag:      `json:"a"`,
+			edit:     func(t convert.StructTag) convert.StructTag { return t.Set("doc", `say "hi"`) },
+			expected: `json:"a" doc:"say \"hi\""`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tag, err := convert.ParseStructTag(reflect.StructTag(c.tag))
+			if err != nil {
+				t.Fatal(err)
+			}
+			original := tag.String()
+			edited := c.edit(tag)
+			if actual := edited.String(); actual != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, actual)
+			}
+			if edited.StructTag() != reflect.StructTag(c.expected) {
+				t.Errorf("expected StructTag to match String, got %s", edited.StructTag())
+			}
+			// edits return copies
+			if actual := tag.String(); actual != original {
+				t.Errorf("expected the original tag to stay %s, got %s", original, actual)
+			}
+		})
+	}
+}
+
+func TestQuoteStructTag(t *testing.T) {
+	cases := []struct {
+		tag      string
+		expected string
+	}{
+		{tag: ``, expected: "``"},
+		{tag: `json:"name,omitempty"`, expected: "`json:\"name,omitempty\"`"},
+		{tag: `doc:"say \"hi\""`, expected: "`doc:\"say \\\"hi\\\"\"`"},
+		// raw strings can't hold backquotes or control characters
+		{tag: "doc:\"a `b`\"", expected: "\"doc:\\\"a `b`\\\"\""},
+		{tag: "doc:\"a\nb\"", expected: `"doc:\"a\nb\""`},
+	}
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			actual := convert.QuoteStructTag(reflect.StructTag(c.tag))
+			if actual != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, actual)
+			}
+			// the result is a Go string literal for the same tag
+			unquoted, err := strconv.Unquote(actual)
+			if err != nil || unquoted != c.tag {
+				t.Errorf("expected %s to be a literal for %q, got %q (%v)", actual, c.tag, unquoted, err)
+			}
+		})
+	}
+}
+
+func TestFieldTagUnquoted(t *testing.T) {
+	const src = "package x\n\ntype T struct {\n" +
+		"\tA int `json:\"a,omitempty\"`\n" +
+		"\tB int \"json:\\\"b\\\"\"\n" +
+		"\tC int\n" +
+		"}\n"
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := convert.FromRawWithFileSet(fset, file).Types()[0].Type().(convert.StructTypeDefinition).Fields()
+
+	expected := []reflect.StructTag{`json:"a,omitempty"`, `json:"b"`, ``}
+	for i, field := range fields {
+		if field.Tag() != expected[i] {
+			t.Errorf("expected field %s to have tag %s, got %s", field.Name().Name(), expected[i], field.Tag())
+		}
+	}
+	if name := fields[0].Tag().Get("json"); name != "a,omitempty" {
+		t.Errorf("expected the tag to work with reflect.StructTag.Get, got %q", name)
+	}
+}
@@ -0,0 +1,195 @@
+package convert
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// typeInfo holds the go/types information for the files of a package.
+type typeInfo struct {
+	pkg *types.Package
+	info *types.Info
+}
+
+// TypeInfo is the result of type checking a package with go/types, such
+// as that produced by loader.TypeCheck.  Info must have (at least) its
+// Types, Defs, and Uses maps populated.
+type TypeInfo struct {
+	Package *types.Package
+	Info *types.Info
+
+	// ImportFiles loads the source files of an imported package, so
+	// that Resolve can find declarations in other packages.  It may
+	// be nil, in which case only this package's declarations are found.
+	ImportFiles func(pkg *types.Package) []*ast.File
+}
+
+// FromTypedPackage is like FromPackageWithFileSet, but for a package that
+// has been type checked.  Type definitions and identifiers from the package
+// implement Typed, and identifiers implement ResolvableIdent.
+func FromTypedPackage(fset *token.FileSet, info TypeInfo, files ...*ast.File) Package {
+	pkg := FromPackageWithFileSet(fset, files...).(*packageImpl)
+	typed := &typeInfo{
+		pkg: info.Package,
+		info: info.Info,
+	}
+	pkg.scope.importFiles = info.ImportFiles
+	for _, scope := range pkg.scope.files {
+		scope.typed = typed
+	}
+	return pkg
+}
+
+// objectOf returns the object that the given identifier (or qualified
+// identifier) refers to, or nil if it's unknown.
+func (s *fileScope) objectOf(node ast.Node) types.Object {
+	if s == nil || s.typed == nil || s.typed.info == nil {
+		return nil
+	}
+	info := s.typed.info
+	switch typed := node.(type) {
+	case *ast.Ident:
+		if obj := info.Uses[typed]; obj != nil {
+			return obj
+		}
+		return info.Defs[typed]
+	case *ast.SelectorExpr:
+		return info.Uses[typed.Sel]
+	}
+	return nil
+}
+
+// goType returns the type of the given expression, or nil if it's unknown.
+func (s *fileScope) goType(node ast.Node) types.Type {
+	if s == nil || s.typed == nil || s.typed.info == nil {
+		return nil
+	}
+	expr, isExpr := node.(ast.Expr)
+	if !isExpr {
+		return nil
+	}
+	if tv, known := s.typed.info.Types[expr]; known && tv.Type != nil {
+		return tv.Type
+	}
+	// names of types may only be recorded as uses
+	if obj := s.objectOf(expr); obj != nil {
+		return obj.Type()
+	}
+	return nil
+}
+
+// underlying converts the underlying type of the given expression, returning
+// self if the expression's type is already its own underlying type.
+func (s *fileScope) underlying(node ast.Node, self TypeDefinition) TypeDefinition {
+	typ := s.goType(node)
+	if typ == nil {
+		return nil
+	}
+	if typ.Underlying() == typ {
+		return self
+	}
+	return s.fromGoType(typ.Underlying())
+}
+
+// resolve finds the declaration of the type named by the given identifier,
+// in either this package or an imported one.
+func (s *fileScope) resolve(node ast.Node) TypeDeclaration {
+	typeName, isTypeName := s.objectOf(node).(*types.TypeName)
+	if !isTypeName || typeName.Pkg() == nil {
+		// not a type, or a predeclared one
+		return nil
+	}
+	if typeName.Parent() != typeName.Pkg().Scope() {
+		// local types can't be referenced from elsewhere
+		return nil
+	}
+
+	if typeName.Pkg() == s.typed.pkg {
+		entry := s.lookupType(typeName.Name())
+		if entry == nil {
+			return nil
+		}
+		return entry.declaration()
+	}
+
+	imported := s.pkg.importPackage(typeName.Pkg())
+	if imported == nil {
+		return nil
+	}
+	for _, decl := range imported.Types() {
+		if decl.Name().Name() == typeName.Name() {
+			return decl
+		}
+	}
+	return nil
+}
+
+// importPackage converts an imported package, caching the result.
+// Imported packages aren't type checked themselves.
+func (p *packageScope) importPackage(pkg *types.Package) Package {
+	if p == nil || p.importFiles == nil {
+		return nil
+	}
+	p.importsMu.Lock()
+	defer p.importsMu.Unlock()
+
+	if imported, cached := p.imports[pkg]; cached {
+		return imported
+	}
+	if p.imports == nil {
+		p.imports = make(map[*types.Package]Package)
+	}
+
+	var imported Package
+	if files := p.importFiles(pkg); len(files) > 0 {
+		imported = FromPackageWithFileSet(p.fset, files...)
+	}
+	p.imports[pkg] = imported
+	return imported
+}
+
+// Typed implementations for each of the type definition wrappers.
+
+func (d *structTypeDefinition) GoType() types.Type { return d.scope.goType(d.typ) }
+func (d *interfaceTypeDefinition) GoType() types.Type { return d.scope.goType(d.typ) }
+func (d *mapTypeDefinition) GoType() types.Type { return d.scope.goType(d.typ) }
+func (d *arrayTypeDefinition) GoType() types.Type { return d.scope.goType(d.typ) }
+func (d *chanTypeDefinition) GoType() types.Type { return d.scope.goType(d.typ) }
+func (d *pointerTypeDefinition) GoType() types.Type { return d.scope.goType(d.typ) }
+func (d *splatTypeDefinition) GoType() types.Type { return d.scope.goType(d.typ) }
+func (d *instantiatedTypeDefinition) GoType() types.Type { return d.scope.goType(d.typ) }
+func (d *unionTypeDefinition) GoType() types.Type { return d.scope.goType(d.typ) }
+func (d *tildeTypeDefinition) GoType() types.Type { return d.scope.goType(d.typ) }
+func (i sourceIdent) GoType() types.Type { return i.source.scope.goType(i.source.node) }
+func (i qualifiedIdent) GoType() types.Type { return i.source.scope.goType(i.source.node) }
+
+func (d *funcTypeDefinition) GoType() types.Type {
+	if d.name != nil {
+		return d.scope.goType(d.name)
+	}
+	return d.scope.goType(d.typ)
+}
+
+func (d *structTypeDefinition) Underlying() TypeDefinition { return d.scope.underlying(d.typ, d) }
+func (d *interfaceTypeDefinition) Underlying() TypeDefinition { return d.scope.underlying(d.typ, d) }
+func (d *funcTypeDefinition) Underlying() TypeDefinition {
+	if typ := d.GoType(); typ != nil {
+		return d
+	}
+	return nil
+}
+func (d *mapTypeDefinition) Underlying() TypeDefinition { return d.scope.underlying(d.typ, d) }
+func (d *arrayTypeDefinition) Underlying() TypeDefinition { return d.scope.underlying(d.typ, d) }
+func (d *chanTypeDefinition) Underlying() TypeDefinition { return d.scope.underlying(d.typ, d) }
+func (d *pointerTypeDefinition) Underlying() TypeDefinition { return d.scope.underlying(d.typ, d) }
+func (d *splatTypeDefinition) Underlying() TypeDefinition { return d.scope.underlying(d.typ, d) }
+func (d *instantiatedTypeDefinition) Underlying() TypeDefinition { return d.scope.underlying(d.typ, d) }
+func (d *unionTypeDefinition) Underlying() TypeDefinition { return d.scope.underlying(d.typ, d) }
+func (d *tildeTypeDefinition) Underlying() TypeDefinition { return d.scope.underlying(d.typ, d) }
+func (i sourceIdent) Underlying() TypeDefinition { return i.source.scope.underlying(i.source.node, i) }
+func (i qualifiedIdent) Underlying() TypeDefinition { return i.source.scope.underlying(i.source.node, i) }
+func (i typeIdent) Underlying() TypeDefinition { return i.source.scope.underlying(i.source.node, i) }
+
+func (i sourceIdent) Resolve() TypeDeclaration { return i.source.scope.resolve(i.source.node) }
+func (i qualifiedIdent) Resolve() TypeDeclaration { return i.source.scope.resolve(i.source.node) }
@@ -1,166 +1,258 @@
 package convert
 
 import (
-	"fmt"
 	"reflect"
+	"strconv"
 
 	"go/ast"
+	"go/constant"
+	"go/token"
 )
 
 // fieldListToFields converts an ast.FieldList into a list Fields
-func fieldListToFields(l *ast.FieldList) []Field {
+func fieldListToFields(s *fileScope, l *ast.FieldList) []Field {
+	var res []Field
+	eachField(s, l, false, func(f *field) {
+		res = append(res, f)
+	})
+	return res
+}
+
+// memberListToFields is like fieldListToFields, but for the fields of a
+// struct or elements of an interface, which are embedded if unnamed.
+func memberListToFields(s *fileScope, l *ast.FieldList) []Field {
+	var res []Field
+	eachField(s, l, true, func(f *field) {
+		res = append(res, f)
+	})
+	return res
+}
+
+// eachField calls fn with each individual field in an ast.FieldList,
+// splitting up fields that share a type (e.g. `a, b int`).
+func eachField(s *fileScope, l *ast.FieldList, member bool, fn func(*field)) {
 	if l == nil {
-		return nil
+		return
 	}
-	var res []Field
 	for _, rawField := range l.List {
 		if rawField.Names == nil {
-			res = append(res, &field{
+			fn(&field{
+				scope: s,
 				name: nil,
 				field: rawField,
+				member: member,
 			})
 		}
 
 		for _, name := range rawField.Names {
-			res = append(res, &field{
+			fn(&field{
+				scope: s,
 				name: name,
 				field: rawField,
+				member: member,
 			})
 		}
 	}
-	return res
-}
-
-func checkBackingTypeDecl(obj *ast.Object) ast.Expr {
-	if obj == nil {
-		return nil
-	}
-
-	typeSpec, isTypeSpec := obj.Decl.(*ast.TypeSpec)
-	if !isTypeSpec {
-		return nil
-	}
-	return typeSpec.Type
 }
 
 // exprToTypeDefinition converts an expression into one of the
 // type definition structs.
-func exprToTypeDefinition(expr ast.Expr) TypeDefinition {
+func exprToTypeDefinition(s *fileScope, expr ast.Expr) TypeDefinition {
 	switch typed := expr.(type) {
 	case *ast.StructType:
 		return &structTypeDefinition{
+			scope: s,
 			typ: typed,
 		}
 	case *ast.InterfaceType:
 		return &interfaceTypeDefinition{
+			scope: s,
 			typ: typed,
 		}
 	case *ast.FuncType:
 		return &funcTypeDefinition{
+			scope: s,
 			typ: typed,
 		}
 	case *ast.MapType:
 		return &mapTypeDefinition{
+			scope: s,
 			typ: typed,
 		}
 	case *ast.ArrayType:
 		return &arrayTypeDefinition{
+			scope: s,
 			typ: typed,
 		}
 	case *ast.ChanType:
 		return &chanTypeDefinition{
+			scope: s,
 			typ: typed,
 		}
 	case *ast.Ident:
-		id := unqualifiedIdent(typed.Name)
-		typDecl := checkBackingTypeDecl(typed.Obj)
-		if typDecl != nil {
+		id := sourceIdent{
+			unqualifiedIdent: unqualifiedIdent(typed.Name),
+			source: sourceNode{scope: s, node: typed},
+			isType: true,
+		}
+		if entry := s.resolveType(typed); entry != nil {
 			return typeIdent{
-				Ident: id,
-				typDecl: typDecl,
+				scope: entry.scope,
+				sourceIdent: id,
+				typDecl: entry.spec.Type,
 			}
 		}
 		return id
 	case *ast.ParenExpr:
 		// ParenExpr is just parens around a normal type
-		return exprToTypeDefinition(typed.X)
+		return exprToTypeDefinition(s, typed.X)
 	case *ast.SelectorExpr:
 		// SelectorExpr is just a qualified name
 		// TODO: allow qualified locatable idents?
+		pkgName, isIdent := typed.X.(*ast.Ident)
+		if !isIdent {
+			panic(conversionError(typed, "invalid qualified type name"))
+		}
 		return qualifiedIdent{
-			packageName: typed.X.(*ast.Ident).Name,
+			packageName: pkgName.Name,
 			Ident: unqualifiedIdent(typed.Sel.Name),
+			source: sourceNode{scope: s, node: typed},
 		}
 	case *ast.StarExpr:
 		// StarExpr is just a pointer to another type
 		return &pointerTypeDefinition{
+			scope: s,
 			typ: typed,
 		}
 	case *ast.Ellipsis:
 		return &splatTypeDefinition{
+			scope: s,
+			typ: typed,
+		}
+	case *ast.IndexExpr:
+		// IndexExpr is a generic type instantiated with a single argument
+		return &instantiatedTypeDefinition{
+			scope: s,
+			typ: typed,
+			generic: typed.X,
+			args: []ast.Expr{typed.Index},
+		}
+	case *ast.IndexListExpr:
+		// IndexListExpr is a generic type instantiated with several arguments
+		return &instantiatedTypeDefinition{
+			scope: s,
 			typ: typed,
+			generic: typed.X,
+			args: typed.Indices,
 		}
-	default:
-		// TODO: return error instead of panic
-		panic(fmt.Sprintf("unknown/invalid expression type %T -- %#v", expr))
+	case *ast.BinaryExpr:
+		// the only binary expressions valid as types are unions in constraints
+		if typed.Op == token.OR {
+			return &unionTypeDefinition{
+				scope: s,
+				typ: typed,
+			}
+		}
+	case *ast.UnaryExpr:
+		// the only unary expressions valid as types are `~T` in constraints
+		if typed.Op == token.TILDE {
+			return &tildeTypeDefinition{
+				scope: s,
+				typ: typed,
+			}
+		}
+	case *ast.BadExpr:
+		panic(conversionError(typed, "malformed type expression"))
 	}
+
+	panic(conversionError(expr, "unknown/invalid type expression %T", expr))
 }
 
 // structTypeDefinition represents the type definition for a struct (fields, etc)
 type structTypeDefinition struct {
+	scope *fileScope
 	typ *ast.StructType
 }
 // TODO: what does incomplete mean in ast.StructType
 
 func (d *structTypeDefinition) Fields() []Field {
-	return fieldListToFields(d.typ.Fields)
+	return memberListToFields(d.scope, d.typ.Fields)
 }
 func (d *structTypeDefinition) ToRawNode() interface{} {
 	return d.typ
 }
 
 type interfaceTypeDefinition struct {
+	scope *fileScope
 	typ *ast.InterfaceType
 }
 
 func (d *interfaceTypeDefinition) Methods() []Field {
-	return fieldListToFields(d.typ.Methods)
+	var res []Field
+	eachField(d.scope, d.typ.Methods, true, func(f *field) {
+		if !f.IsEmbedded() {
+			res = append(res, f)
+		}
+	})
+	return res
+}
+func (d *interfaceTypeDefinition) Embeds() []Field {
+	var res []Field
+	eachField(d.scope, d.typ.Methods, true, func(f *field) {
+		if f.IsEmbedded() {
+			res = append(res, f)
+		}
+	})
+	return res
+}
+func (d *interfaceTypeDefinition) Elements() []Field {
+	return memberListToFields(d.scope, d.typ.Methods)
 }
 func (d *interfaceTypeDefinition) ToRawNode() interface{} {
 	return d.typ
 }
 
 type funcTypeDefinition struct {
+	scope *fileScope
 	typ *ast.FuncType
+	// name is the name of the declared func, if any, since
+	// go/types records the types of declared funcs by name
+	name *ast.Ident
+}
+
+func (d *funcTypeDefinition) TypeParams() []Field {
+	return fieldListToFields(d.scope, d.typ.TypeParams)
 }
 
 func (d *funcTypeDefinition) Params() []Field {
-	return fieldListToFields(d.typ.Params)
+	return fieldListToFields(d.scope, d.typ.Params)
 }
 
 func (d *funcTypeDefinition) Results() []Field {
-	return fieldListToFields(d.typ.Results)
+	return fieldListToFields(d.scope, d.typ.Results)
 }
 func (d *funcTypeDefinition) ToRawNode() interface{} {
 	return d.typ
 }
 
 type mapTypeDefinition struct {
+	scope *fileScope
 	typ *ast.MapType
 }
 
 func (d *mapTypeDefinition) KeyType() TypeDefinition {
-	return exprToTypeDefinition(d.typ.Key)
+	return exprToTypeDefinition(d.scope, d.typ.Key)
 }
 
 func (d *mapTypeDefinition) ValueType() TypeDefinition {
-	return exprToTypeDefinition(d.typ.Value)
+	return exprToTypeDefinition(d.scope, d.typ.Value)
 }
 func (d *mapTypeDefinition) ToRawNode() interface{} {
 	return d.typ
 }
 
 type arrayTypeDefinition struct {
+	scope *fileScope
 	typ *ast.ArrayType
 }
 
@@ -173,44 +265,75 @@ func (d *arrayTypeDefinition) AutoLength() bool {
 	return isEllipsis
 }
 
-func (d *arrayTypeDefinition) Length() *int {
+func (d *arrayTypeDefinition) Length() ArrayLength {
 	if d.typ.Len == nil {
 		return nil
 	}
 	if _, isEllipsis := d.typ.Len.(*ast.Ellipsis); isEllipsis {
-		return &AutoLength
+		return AutoLength
+	}
+	return &arrayLength{
+		scope: d.scope,
+		expr: d.typ.Len,
 	}
-	panic("not implemented")
 }
 
 func (d *arrayTypeDefinition) ElemType() TypeDefinition {
-	return exprToTypeDefinition(d.typ.Elt)
+	return exprToTypeDefinition(d.scope, d.typ.Elt)
 }
 func (d *arrayTypeDefinition) ToRawNode() interface{} {
 	return d.typ
 }
 
+// arrayLength is a constant array length expression,
+// evaluated against the constants in its file.
+type arrayLength struct {
+	scope *fileScope
+	expr ast.Expr
+}
+
+func (l *arrayLength) Expr() Expression {
+	return exprToExpression(l.scope, l.expr)
+}
+
+func (l *arrayLength) Value() constant.Value {
+	return l.scope.evalConstExpr(l.Expr())
+}
+
+// autoLength is the length of `[...]T`
+type autoLength struct{}
+
+func (autoLength) Expr() Expression {
+	return nil
+}
+
+func (autoLength) Value() constant.Value {
+	return nil
+}
+
 type chanTypeDefinition struct {
+	scope *fileScope
 	typ *ast.ChanType
 }
 
 func (d *chanTypeDefinition) ValueType() TypeDefinition {
-	return exprToTypeDefinition(d.typ.Value)
+	return exprToTypeDefinition(d.scope, d.typ.Value)
 }
 
 func (d *chanTypeDefinition) Directions() (receive bool, send bool) {
-	return d.typ.Dir & ast.SEND != 0, d.typ.Dir & ast.RECV != 0
+	return d.typ.Dir & ast.RECV != 0, d.typ.Dir & ast.SEND != 0
 }
 func (d *chanTypeDefinition) ToRawNode() interface{} {
 	return d.typ
 }
 
 type pointerTypeDefinition struct {
+	scope *fileScope
 	typ *ast.StarExpr
 }
 
 func (d *pointerTypeDefinition) ReferentType() TypeDefinition {
-	return exprToTypeDefinition(d.typ.X)
+	return exprToTypeDefinition(d.scope, d.typ.X)
 }
 
 func (d *pointerTypeDefinition) ToRawNode() interface{} {
@@ -218,26 +341,106 @@ func (d *pointerTypeDefinition) ToRawNode() interface{} {
 }
 
 type splatTypeDefinition struct {
+	scope *fileScope
 	typ *ast.Ellipsis
 }
 
 func (d *splatTypeDefinition) ElemType() TypeDefinition {
-	return exprToTypeDefinition(d.typ.Elt)
+	return exprToTypeDefinition(d.scope, d.typ.Elt)
 }
 func (d *splatTypeDefinition) IsSplat() struct{} {
 	return struct{}{}
 }
 
+// instantiatedTypeDefinition represents a generic type with type arguments.
+// It covers both single-argument (ast.IndexExpr) and multi-argument
+// (ast.IndexListExpr) instantiations.
+type instantiatedTypeDefinition struct {
+	scope *fileScope
+	typ ast.Expr
+	generic ast.Expr
+	args []ast.Expr
+}
+
+func (d *instantiatedTypeDefinition) GenericType() TypeDefinition {
+	return exprToTypeDefinition(d.scope, d.generic)
+}
+
+func (d *instantiatedTypeDefinition) TypeArgs() []TypeDefinition {
+	res := make([]TypeDefinition, len(d.args))
+	for i, arg := range d.args {
+		res[i] = exprToTypeDefinition(d.scope, arg)
+	}
+	return res
+}
+func (d *instantiatedTypeDefinition) ToRawNode() interface{} {
+	return d.typ
+}
+
+type unionTypeDefinition struct {
+	scope *fileScope
+	typ *ast.BinaryExpr
+}
+
+// Terms returns the terms of the union, flattening the
+// (left-associative) chain of `|` expressions.
+func (d *unionTypeDefinition) Terms() []TypeDefinition {
+	var terms []ast.Expr
+	var expr ast.Expr = d.typ
+	for {
+		union, isUnion := expr.(*ast.BinaryExpr)
+		if !isUnion || union.Op != token.OR {
+			break
+		}
+		terms = append(terms, union.Y)
+		expr = union.X
+	}
+	terms = append(terms, expr)
+
+	res := make([]TypeDefinition, len(terms))
+	for i, term := range terms {
+		// we collected the terms from right to left
+		res[len(terms)-1-i] = exprToTypeDefinition(d.scope, term)
+	}
+	return res
+}
+func (d *unionTypeDefinition) ToRawNode() interface{} {
+	return d.typ
+}
+
+type tildeTypeDefinition struct {
+	scope *fileScope
+	typ *ast.UnaryExpr
+}
+
+func (d *tildeTypeDefinition) Approximates() TypeDefinition {
+	return exprToTypeDefinition(d.scope, d.typ.X)
+}
+func (d *tildeTypeDefinition) ToRawNode() interface{} {
+	return d.typ
+}
+
 type field struct {
+	scope *fileScope
 	field *ast.Field
 	name *ast.Ident
+	// member is set for struct fields and interface elements,
+	// which are embedded when they have no name
+	member bool
+}
+
+func (f *field) IsEmbedded() bool {
+	return f.member && f.name == nil
 }
 
 func (f *field) Doc() []string {
-	// TODO: line comments?
 	return extractCommentGroup(f.field.Doc)
 }
 
+func (f *field) LineComment() []string {
+	return extractCommentGroup(f.field.Comment)
+}
+
 // Name returns the name of the field, or nil for
 // an anonymous field.
 func (f *field) Name() Ident {
@@ -250,14 +453,20 @@ func (f *field) Name() Ident {
 
 // Type returns the type of the field.
 func (f *field) Type() TypeDefinition {
-	return exprToTypeDefinition(f.field.Type)
+	return exprToTypeDefinition(f.scope, f.field.Type)
 }
 
+// Tag returns the field's tag, without the surrounding quotes.
 func (f *field) Tag() reflect.StructTag {
 	if f.field.Tag == nil {
 		return reflect.StructTag("")
 	}
-	return reflect.StructTag(f.field.Tag.Value)
+	tag, err := strconv.Unquote(f.field.Tag.Value)
+	if err != nil {
+		// malformed literals are left as-is
+		return reflect.StructTag(f.field.Tag.Value)
+	}
+	return reflect.StructTag(tag)
 }
 
 func (f *field) ToRawNode() interface{} {
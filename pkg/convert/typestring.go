@@ -0,0 +1,305 @@
+package convert
+
+import (
+	"fmt"
+	"strings"
+
+	"go/constant"
+)
+
+// Qualifier controls how references to other packages are printed by
+// TypeString.  It's called with the package name that a qualified
+// identifier was written with (empty for identifiers from dot imports)
+// and its import path (empty if unknown), and returns the package name
+// to print, or the empty string to print the identifier unqualified.
+type Qualifier func(pkgName, importPath string) string
+
+// RelativeTo returns a Qualifier that leaves references to the package
+// with the given import path unqualified, and qualifies the rest.
+// Identifiers from dot imports are qualified with a name guessed
+// from their import path.
+func RelativeTo(importPath string) Qualifier {
+	return func(pkgName, identPath string) string {
+		if identPath != "" && identPath == importPath {
+			return ""
+		}
+		if pkgName == "" && identPath != "" {
+			names := assumedPackageNames(identPath)
+			return names[len(names)-1]
+		}
+		return pkgName
+	}
+}
+
+// TypeString renders a type definition (parsed or built) as Go source,
+// like `map[string][]*v1.Pod`.  Qualified identifiers are printed with the
+// package name given by qualifier, or as written if qualifier is nil.
+func TypeString(def TypeDefinition, qualifier Qualifier) string {
+	w := &typeWriter{qualifier: qualifier}
+	w.typ(def)
+	return w.buf.String()
+}
+
+type typeWriter struct {
+	buf strings.Builder
+	qualifier Qualifier
+}
+
+func (w *typeWriter) string(strs ...string) {
+	for _, str := range strs {
+		w.buf.WriteString(str)
+	}
+}
+
+func (w *typeWriter) typ(def TypeDefinition) {
+	switch typed := def.(type) {
+	case nil:
+		w.string("<nil>")
+	case StructTypeDefinition:
+		w.string("struct{")
+		for i, field := range typed.Fields() {
+			if i > 0 {
+				w.string("; ")
+			}
+			if name := field.Name(); name != nil && !field.IsEmbedded() {
+				w.string(name.Name(), " ")
+			}
+			w.typ(field.Type())
+			if tag := field.Tag(); tag != "" {
+				w.string(" ", QuoteStructTag(tag))
+			}
+		}
+		w.string("}")
+	case InterfaceTypeDefinition:
+		w.string("interface{")
+		for i, elem := range typed.Elements() {
+			if i > 0 {
+				w.string("; ")
+			}
+			if elem.IsEmbedded() {
+				w.typ(elem.Type())
+				continue
+			}
+			w.string(elem.Name().Name())
+			if sig, isFunc := elem.Type().(FuncTypeDefinition); isFunc {
+				w.signature(sig)
+			} else {
+				w.string(" ")
+				w.typ(elem.Type())
+			}
+		}
+		w.string("}")
+	case FuncTypeDefinition:
+		w.string("func")
+		w.signature(typed)
+	case MapTypeDefinition:
+		w.string("map[")
+		w.typ(typed.KeyType())
+		w.string("]")
+		w.typ(typed.ValueType())
+	case ChanTypeDefinition:
+		recv, send := typed.Directions()
+		switch {
+		case recv && !send:
+			w.string("<-chan ")
+		case send && !recv:
+			w.string("chan<- ")
+		default:
+			w.string("chan ")
+		}
+		// `chan <-chan T` would be parsed as `chan<- chan T`
+		if elem, isChan := typed.ValueType().(ChanTypeDefinition); isChan && recv && send {
+			if elemRecv, elemSend := elem.Directions(); elemRecv && !elemSend {
+				w.string("(")
+				w.typ(elem)
+				w.string(")")
+				return
+			}
+		}
+		w.typ(typed.ValueType())
+	case PointerTypeDefinition:
+		w.string("*")
+		w.typ(typed.ReferentType())
+	case SplatTypeDefinition:
+		w.string("...")
+		w.typ(typed.ElemType())
+	case ArrayTypeDefinition:
+		w.string("[")
+		if length := typed.Length(); length == AutoLength {
+			w.string("...")
+		} else if length != nil {
+			w.arrayLength(length)
+		}
+		w.string("]")
+		w.typ(typed.ElemType())
+	case InstantiatedTypeDefinition:
+		w.typ(typed.GenericType())
+		w.string("[")
+		w.typeList(typed.TypeArgs(), ", ")
+		w.string("]")
+	case UnionTypeDefinition:
+		w.typeList(typed.Terms(), " | ")
+	case TildeTypeDefinition:
+		w.string("~")
+		w.typ(typed.Approximates())
+	case QualifiedIdent:
+		w.qualified(typed.PackageName(), typed.ImportPath(), typed.Name())
+	case ImportedIdent:
+		if w.qualifier == nil {
+			w.string(typed.Name())
+			return
+		}
+		if importPath := typed.ImportPath(); importPath != "" {
+			w.qualified("", importPath, typed.Name())
+			return
+		}
+		w.string(typed.Name())
+	case Ident:
+		w.string(typed.Name())
+	default:
+		w.string(fmt.Sprintf("<unknown type %T>", def))
+	}
+}
+
+func (w *typeWriter) qualified(pkgName, importPath, name string) {
+	if w.qualifier != nil {
+		pkgName = w.qualifier(pkgName, importPath)
+	}
+	if pkgName != "" {
+		w.string(pkgName, ".")
+	}
+	w.string(name)
+}
+
+func (w *typeWriter) typeList(defs []TypeDefinition, sep string) {
+	for i, def := range defs {
+		if i > 0 {
+			w.string(sep)
+		}
+		w.typ(def)
+	}
+}
+
+// signature writes a function signature, without the `func` keyword.
+func (w *typeWriter) signature(sig FuncTypeDefinition) {
+	if params := sig.TypeParams(); len(params) > 0 {
+		w.string("[")
+		w.fields(params)
+		w.string("]")
+	}
+	w.string("(")
+	w.fields(sig.Params())
+	w.string(")")
+
+	results := sig.Results()
+	switch {
+	case len(results) == 0:
+	case len(results) == 1 && results[0].Name() == nil:
+		w.string(" ")
+		w.typ(results[0].Type())
+	default:
+		w.string(" (")
+		w.fields(results)
+		w.string(")")
+	}
+}
+
+func (w *typeWriter) fields(fields []Field) {
+	for i, field := range fields {
+		if i > 0 {
+			w.string(", ")
+		}
+		if name := field.Name(); name != nil {
+			w.string(name.Name(), " ")
+		}
+		w.typ(field.Type())
+	}
+}
+
+// arrayLength writes the length expression of an array, falling
+// back to its value for expressions that can't be printed.
+func (w *typeWriter) arrayLength(length ArrayLength) {
+	if expr := length.Expr(); expr != nil {
+		lengthWriter := &typeWriter{qualifier: w.qualifier}
+		if lengthWriter.expr(expr) {
+			w.string(lengthWriter.buf.String())
+			return
+		}
+	}
+	if val := length.Value(); val != nil && val.Kind() == constant.Int {
+		w.string(val.ExactString())
+		return
+	}
+	w.string("<unknown length>")
+}
+
+// expr writes the kinds of expressions found in constant expressions,
+// returning false for anything else.
+func (w *typeWriter) expr(expr Expression) bool {
+	switch typed := expr.(type) {
+	case BasicLiteral:
+		w.string(typed.Literal())
+	case ParenExpression:
+		w.string("(")
+		if !w.expr(typed.Inner()) {
+			return false
+		}
+		w.string(")")
+	case UnaryExpression:
+		w.string(typed.Op().String())
+		return w.expr(typed.Operand())
+	case BinaryExpression:
+		if !w.expr(typed.Left()) {
+			return false
+		}
+		w.string(" ", typed.Op().String(), " ")
+		return w.expr(typed.Right())
+	case CallExpression:
+		if !w.expr(typed.Func()) {
+			return false
+		}
+		w.string("(")
+		for i, arg := range typed.Args() {
+			if i > 0 {
+				w.string(", ")
+			}
+			if !w.expr(arg) {
+				return false
+			}
+		}
+		w.string(")")
+	case ConversionExpression:
+		w.typ(typed.TargetType())
+		w.string("(")
+		if !w.expr(typed.Operand()) {
+			return false
+		}
+		w.string(")")
+	case SelectorExpression:
+		if !w.expr(typed.Operand()) {
+			return false
+		}
+		w.string(".", typed.Selector().Name())
+	case Ident:
+		w.typ(typed)
+	default:
+		return false
+	}
+	return true
+}
+
+// String methods, for printing types in logs and errors.
+
+func (d *structTypeDefinition) String() string { return TypeString(d, nil) }
+func (d *interfaceTypeDefinition) String() string { return TypeString(d, nil) }
+func (d *funcTypeDefinition) String() string { return TypeString(d, nil) }
+func (d *mapTypeDefinition) String() string { return TypeString(d, nil) }
+func (d *arrayTypeDefinition) String() string { return TypeString(d, nil) }
+func (d *chanTypeDefinition) String() string { return TypeString(d, nil) }
+func (d *pointerTypeDefinition) String() string { return TypeString(d, nil) }
+func (d *splatTypeDefinition) String() string { return TypeString(d, nil) }
+func (d *instantiatedTypeDefinition) String() string { return TypeString(d, nil) }
+func (d *unionTypeDefinition) String() string { return TypeString(d, nil) }
+func (d *tildeTypeDefinition) String() string { return TypeString(d, nil) }
+func (i unqualifiedIdent) String() string { return string(i) }
+func (i qualifiedIdent) String() string { return TypeString(i, nil) }
@@ -0,0 +1,245 @@
+package convert
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Visitor receives callbacks from Walk.  Each method returns whether
+// or not Walk should continue on to the children of the given node,
+// so that whole subtrees can be skipped.
+type Visitor interface {
+	// VisitTypeDeclaration is called for each type declaration,
+	// before its type parameters and type definition.
+	VisitTypeDeclaration(decl TypeDeclaration) bool
+	// VisitFuncDeclaration is called for each function or method,
+	// before its receiver type and signature.
+	VisitFuncDeclaration(decl FuncDeclaration) bool
+	// VisitValueDeclaration is called for each const or var,
+	// before its type (if it has one).
+	VisitValueDeclaration(decl ValueDeclaration) bool
+	// VisitTypeDefinition is called for each type definition, including
+	// those nested inside other type definitions (struct fields, func
+	// params, map/chan/pointer elements, etc), before its children.
+	VisitTypeDefinition(def TypeDefinition) bool
+	// VisitField is called for each struct field, type parameter,
+	// and func param or result, before its type.
+	VisitField(field Field) bool
+	// VisitMethod is called for each interface method, and each embedded
+	// interface or constraint (for which IsEmbedded is true), before its type.
+	VisitMethod(method Field) bool
+}
+
+// BaseVisitor is a Visitor that visits everything, doing nothing.
+// Embed it to implement just the callbacks you need.
+type BaseVisitor struct{}
+
+func (BaseVisitor) VisitTypeDeclaration(TypeDeclaration) bool { return true }
+func (BaseVisitor) VisitFuncDeclaration(FuncDeclaration) bool { return true }
+func (BaseVisitor) VisitValueDeclaration(ValueDeclaration) bool { return true }
+func (BaseVisitor) VisitTypeDefinition(TypeDefinition) bool { return true }
+func (BaseVisitor) VisitField(Field) bool { return true }
+func (BaseVisitor) VisitMethod(Field) bool { return true }
+
+// Walk traverses the declarations in the given AST, depth-first, calling
+// the visitor for each declaration, type definition, field, and method.
+// Function bodies and value expressions are not traversed.
+//
+// ASTs from FromRaw and FromPackage are walked in source order directly
+// from the underlying Go AST, without building intermediate slices.  Other
+// ASTs are walked through their interfaces: types first, then values, then
+// funcs.
+func Walk(a AST, v Visitor) {
+	switch impl := a.(type) {
+	case *astImpl:
+		impl.walk(v)
+		return
+	case *packageImpl:
+		impl.walk(v)
+		return
+	}
+
+	for _, decl := range a.Types() {
+		walkTypeDeclaration(decl, v)
+	}
+	for _, decl := range a.Values() {
+		walkValueDeclaration(decl, v)
+	}
+	for _, decl := range a.Funcs() {
+		walkFuncDeclaration(decl, v)
+	}
+}
+
+func walkTypeDeclaration(decl TypeDeclaration, v Visitor) {
+	if !v.VisitTypeDeclaration(decl) {
+		return
+	}
+	walkFields(decl.TypeParams(), v.VisitField, v)
+	walkTypeDefinition(decl.Type(), v)
+}
+
+func walkValueDeclaration(decl ValueDeclaration, v Visitor) {
+	if !v.VisitValueDeclaration(decl) {
+		return
+	}
+	walkTypeDefinition(decl.Type(), v)
+}
+
+func walkFuncDeclaration(decl FuncDeclaration, v Visitor) {
+	if !v.VisitFuncDeclaration(decl) {
+		return
+	}
+	_, recvType := decl.Receiver()
+	walkTypeDefinition(recvType, v)
+	walkTypeDefinition(decl.Type(), v)
+}
+
+func walkFields(fields []Field, visit func(Field) bool, v Visitor) {
+	for _, field := range fields {
+		if visit(field) {
+			walkTypeDefinition(field.Type(), v)
+		}
+	}
+}
+
+// walkTypeDefinition walks any implementation of the type definition interfaces.
+func walkTypeDefinition(def TypeDefinition, v Visitor) {
+	if def == nil || !v.VisitTypeDefinition(def) {
+		return
+	}
+
+	switch typed := def.(type) {
+	case StructTypeDefinition:
+		walkFields(typed.Fields(), v.VisitField, v)
+	case InterfaceTypeDefinition:
+		walkFields(typed.Elements(), v.VisitMethod, v)
+	case FuncTypeDefinition:
+		walkFields(typed.TypeParams(), v.VisitField, v)
+		walkFields(typed.Params(), v.VisitField, v)
+		walkFields(typed.Results(), v.VisitField, v)
+	case MapTypeDefinition:
+		walkTypeDefinition(typed.KeyType(), v)
+		walkTypeDefinition(typed.ValueType(), v)
+	case ChanTypeDefinition:
+		walkTypeDefinition(typed.ValueType(), v)
+	case PointerTypeDefinition:
+		walkTypeDefinition(typed.ReferentType(), v)
+	case SplatTypeDefinition:
+		walkTypeDefinition(typed.ElemType(), v)
+	case ArrayTypeDefinition:
+		walkTypeDefinition(typed.ElemType(), v)
+	case InstantiatedTypeDefinition:
+		walkTypeDefinition(typed.GenericType(), v)
+		for _, arg := range typed.TypeArgs() {
+			walkTypeDefinition(arg, v)
+		}
+	case UnionTypeDefinition:
+		for _, term := range typed.Terms() {
+			walkTypeDefinition(term, v)
+		}
+	case TildeTypeDefinition:
+		walkTypeDefinition(typed.Approximates(), v)
+	}
+}
+
+// walk walks the underlying Go AST directly, wrapping each
+// node only as it's visited.
+func (a *astImpl) walk(v Visitor) {
+	s := a.scope
+	a.eachDecl(declVisitor{
+		typeDecl: func(d *typeDeclaration) {
+			if !v.VisitTypeDeclaration(d) {
+				return
+			}
+			walkRawFields(s, d.spec.TypeParams, false, v.VisitField, v)
+			walkRawTypeDefinition(s, d.spec.Type, v)
+		},
+		valueDecl: func(d *valueDeclaration) {
+			if !v.VisitValueDeclaration(d) {
+				return
+			}
+			if d.typ != nil {
+				walkRawTypeDefinition(s, d.typ, v)
+			}
+		},
+		funcDecl: func(d *funcDeclaration) {
+			if !v.VisitFuncDeclaration(d) {
+				return
+			}
+			if recv := d.decl.Recv; recv != nil && len(recv.List) > 0 {
+				walkRawTypeDefinition(s, recv.List[0].Type, v)
+			}
+			walkRawTypeDefinition(s, d.decl.Type, v)
+		},
+	})
+}
+
+func walkRawFields(s *fileScope, l *ast.FieldList, member bool, visit func(Field) bool, v Visitor) {
+	eachField(s, l, member, func(f *field) {
+		if visit(f) {
+			walkRawTypeDefinition(s, f.field.Type, v)
+		}
+	})
+}
+
+// walkRawTypeDefinition is the equivalent of walkTypeDefinition
+// for the raw type expressions backing the wrapper types.
+func walkRawTypeDefinition(s *fileScope, expr ast.Expr, v Visitor) {
+	// parens aren't represented in the convert types
+	for {
+		paren, isParen := expr.(*ast.ParenExpr)
+		if !isParen {
+			break
+		}
+		expr = paren.X
+	}
+
+	if !v.VisitTypeDefinition(exprToTypeDefinition(s, expr)) {
+		return
+	}
+
+	switch typed := expr.(type) {
+	case *ast.StructType:
+		walkRawFields(s, typed.Fields, true, v.VisitField, v)
+	case *ast.InterfaceType:
+		walkRawFields(s, typed.Methods, true, v.VisitMethod, v)
+	case *ast.FuncType:
+		walkRawFields(s, typed.TypeParams, false, v.VisitField, v)
+		walkRawFields(s, typed.Params, false, v.VisitField, v)
+		walkRawFields(s, typed.Results, false, v.VisitField, v)
+	case *ast.MapType:
+		walkRawTypeDefinition(s, typed.Key, v)
+		walkRawTypeDefinition(s, typed.Value, v)
+	case *ast.ChanType:
+		walkRawTypeDefinition(s, typed.Value, v)
+	case *ast.StarExpr:
+		walkRawTypeDefinition(s, typed.X, v)
+	case *ast.Ellipsis:
+		walkRawTypeDefinition(s, typed.Elt, v)
+	case *ast.ArrayType:
+		walkRawTypeDefinition(s, typed.Elt, v)
+	case *ast.IndexExpr:
+		walkRawTypeDefinition(s, typed.X, v)
+		walkRawTypeDefinition(s, typed.Index, v)
+	case *ast.IndexListExpr:
+		walkRawTypeDefinition(s, typed.X, v)
+		for _, arg := range typed.Indices {
+			walkRawTypeDefinition(s, arg, v)
+		}
+	case *ast.BinaryExpr:
+		walkRawUnionTerms(s, typed, v)
+	case *ast.UnaryExpr:
+		walkRawTypeDefinition(s, typed.X, v)
+	}
+}
+
+// walkRawUnionTerms walks the terms of a (left-associative) chain
+// of `|` expressions in order, like UnionTypeDefinition.Terms.
+func walkRawUnionTerms(s *fileScope, expr *ast.BinaryExpr, v Visitor) {
+	if left, isUnion := expr.X.(*ast.BinaryExpr); isUnion && left.Op == token.OR {
+		walkRawUnionTerms(s, left, v)
+	} else {
+		walkRawTypeDefinition(s, expr.X, v)
+	}
+	walkRawTypeDefinition(s, expr.Y, v)
+}
@@ -5,21 +5,23 @@ package builder
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 
-	"go/ast"
+	"go/constant"
+	"go/token"
 
 	"github.com/directxman12/envmap/pkg/convert"
 )
 
 // builtPtr represents a concrete pointer to another type
-type builtPtr struct { referent convert.TypeDefinition }
+type builtPtr struct { builtPosition; referent convert.TypeDefinition }
 func (p *builtPtr) ReferentType() convert.TypeDefinition { return p.referent }
 func PointerTo(referent convert.TypeDefinition) convert.PointerTypeDefinition {
 	return &builtPtr{referent: referent}
 }
 
 // builtSplat represents a concrete splat of another type
-type builtSplat struct { elemType convert.TypeDefinition }
+type builtSplat struct { builtPosition; elemType convert.TypeDefinition }
 func (b *builtSplat) ElemType() convert.TypeDefinition { return b.elemType }
 func (b *builtSplat) IsSplat() struct{} { return struct{}{} }
 func SplatOf(elemType convert.TypeDefinition) convert.SplatTypeDefinition {
@@ -28,20 +30,43 @@ func SplatOf(elemType convert.TypeDefinition) convert.SplatTypeDefinition {
 
 // builtArray represents a concrete array or slice of another type
 type builtArray struct {
+	builtPosition
 	elemType convert.TypeDefinition
-	length *int
+	length convert.ArrayLength
 }
 func (b *builtArray) ElemType() convert.TypeDefinition { return b.elemType }
-func (b *builtArray) Length() *int { return b.length }
+func (b *builtArray) Length() convert.ArrayLength { return b.length }
 func SliceOf(elemType convert.TypeDefinition) convert.ArrayTypeDefinition {
 	return &builtArray{elemType: elemType}
 }
 func ArrayOf(elemType convert.TypeDefinition, length int) convert.ArrayTypeDefinition {
-	return &builtArray{elemType: elemType, length: &length}
+	return &builtArray{elemType: elemType, length: &builtLength{value: constant.MakeInt64(int64(length))}}
+}
+// ArrayOfExpr constructs an array whose length is the given constant
+// expression (e.g. `sha256.Size` or `maxItems * 2`).
+func ArrayOfExpr(elemType convert.TypeDefinition, length convert.Expression) convert.ArrayTypeDefinition {
+	return &builtArray{elemType: elemType, length: &builtLength{expr: length, value: convert.EvalConstExpr(length)}}
+}
+// AutoArrayOf constructs an array whose length is determined by its literal (`[...]T`).
+func AutoArrayOf(elemType convert.TypeDefinition) convert.ArrayTypeDefinition {
+	return &builtArray{elemType: elemType, length: convert.AutoLength}
 }
 
+// builtLength represents a concrete array length
+type builtLength struct {
+	expr convert.Expression
+	value constant.Value
+}
+func (l *builtLength) Expr() convert.Expression {
+	if l.expr == nil {
+		return BasicLit(token.INT, l.value.ExactString())
+	}
+	return l.expr
+}
+func (l *builtLength) Value() constant.Value { return l.value }
+
 // builtMap represents a concrete map type
-type builtMap struct { key, value convert.TypeDefinition }
+type builtMap struct { builtPosition; key, value convert.TypeDefinition }
 func (b *builtMap) KeyType() convert.TypeDefinition { return b.key }
 func (b *builtMap) ValueType() convert.TypeDefinition { return b.value }
 func MapOf(key, value convert.TypeDefinition) convert.MapTypeDefinition {
@@ -50,6 +75,7 @@ func MapOf(key, value convert.TypeDefinition) convert.MapTypeDefinition {
 
 // builtChan represents a concrete channel type
 type builtChan struct {
+	builtPosition
 	elem convert.TypeDefinition
 	recv, send bool
 }
@@ -75,50 +101,136 @@ func ReceiveChanOf(value convert.TypeDefinition) convert.ChanTypeDefinition {
 	}
 }
 
+// builtInstantiation represents a concrete instantiation of a generic type
+type builtInstantiation struct {
+	builtPosition
+	generic convert.TypeDefinition
+	args []convert.TypeDefinition
+}
+func (b *builtInstantiation) GenericType() convert.TypeDefinition { return b.generic }
+func (b *builtInstantiation) TypeArgs() []convert.TypeDefinition { return b.args }
+func Instantiate(generic convert.TypeDefinition, args ...convert.TypeDefinition) convert.InstantiatedTypeDefinition {
+	return &builtInstantiation{generic: generic, args: args}
+}
+
+// builtUnion represents a concrete union of constraint terms
+type builtUnion struct { builtPosition; terms []convert.TypeDefinition }
+func (b *builtUnion) Terms() []convert.TypeDefinition { return b.terms }
+func Union(terms ...convert.TypeDefinition) convert.UnionTypeDefinition {
+	return &builtUnion{terms: terms}
+}
+
+// builtTilde represents a concrete `~T` constraint term
+type builtTilde struct { builtPosition; typ convert.TypeDefinition }
+func (b *builtTilde) Approximates() convert.TypeDefinition { return b.typ }
+func Tilde(typ convert.TypeDefinition) convert.TildeTypeDefinition {
+	return &builtTilde{typ: typ}
+}
+
+// Synthetic is the position reported by built nodes
+// which haven't been given one explicitly.
+var Synthetic = token.Position{Filename: "<builder>"}
+
+// builtPosition represents the origin of a built node
+type builtPosition struct {
+	pos token.Position
+}
+func (p *builtPosition) Position() token.Position {
+	if p.pos == (token.Position{}) {
+		return Synthetic
+	}
+	return p.pos
+}
+
 // builtDoc represents some concrete docs
 type builtDoc struct {
 	doc []string
 }
 func (d *builtDoc) Doc() []string { return d.doc }
 
+// builtLineComment represents a concrete trailing comment
+type builtLineComment struct {
+	lineComment []string
+}
+func (c *builtLineComment) LineComment() []string { return c.lineComment }
+
 // builtField represents a concrete field
 type builtField struct {
+	builtPosition
+	builtDoc
+	builtLineComment
 	name string
 	typ convert.TypeDefinition
 	tag reflect.StructTag
+	embedded bool
 }
 func (f *builtField) Name() convert.Ident {
 	if f.name == "" { return nil }
 	return convert.NewIdent(f.name)
 }
 func (f *builtField) Type() convert.TypeDefinition { return f.typ }
+func (f *builtField) IsEmbedded() bool { return f.embedded }
 func (f *builtField) Tag() reflect.StructTag { return f.tag }
 
+// TypeParam constructs a type parameter with the given constraint,
+// for use with WithTypeParams.
+func TypeParam(name string, constraint convert.TypeDefinition) convert.Field {
+	return &builtField{name: name, typ: constraint}
+}
+
 // builtImport represents a concrete imported package
 // TODO: expose constructing this manually?
 type builtImport struct {
+	builtPosition
+	builtDoc
+	builtLineComment
 	alias, path string
 }
 func (i *builtImport) Name() convert.Ident {
 	if (i.alias != "") { return convert.NewIdent(i.alias) }
 	return nil
 }
-func (i *builtImport) Path() string { return i.path }
+func (i *builtImport) Path() string { return strconv.Quote(i.path) }
+func (i *builtImport) ImportPath() string { return i.path }
 
 // TypeDeclarationBuilder builds a concrete type declaration
 type TypeDeclarationBuilder struct {
+	builtPosition
 	builtDoc
+	builtLineComment
 	name string
 	isAlias bool
 	typ convert.TypeDefinition
+	typeParams []convert.Field
 }
 func (d *TypeDeclarationBuilder) Name() convert.Ident { return convert.NewIdent(d.name) }
 func (d *TypeDeclarationBuilder) IsAlias() bool { return d.isAlias }
 func (d *TypeDeclarationBuilder) Type() convert.TypeDefinition { return d.typ }
+func (d *TypeDeclarationBuilder) TypeParams() []convert.Field { return d.typeParams }
+// Named renames the declared type.
+func (d *TypeDeclarationBuilder) Named(name string) *TypeDeclarationBuilder {
+	d.name = name
+	return d
+}
 func (d *TypeDeclarationBuilder) WithDoc(lines ...string) *TypeDeclarationBuilder {
 	d.doc = lines
 	return d
 }
+func (d *TypeDeclarationBuilder) WithLineComment(lines ...string) *TypeDeclarationBuilder {
+	d.lineComment = lines
+	return d
+}
+// At sets the position reported for this declaration.
+func (d *TypeDeclarationBuilder) At(pos token.Position) *TypeDeclarationBuilder {
+	d.pos = pos
+	return d
+}
+// WithTypeParams makes this a generic type with the given type parameters
+// (see TypeParam).
+func (d *TypeDeclarationBuilder) WithTypeParams(params ...convert.Field) *TypeDeclarationBuilder {
+	d.typeParams = append(d.typeParams, params...)
+	return d
+}
 func Alias(name string, typ convert.TypeDefinition) *TypeDeclarationBuilder {
 	return &TypeDeclarationBuilder{
 		name: name,
@@ -135,23 +247,30 @@ func Type(name string, typ convert.TypeDefinition) *TypeDeclarationBuilder {
 
 // FuncDeclBuilder build a function or method declarations
 type FuncDeclBuilder struct {
+	builtPosition
 	builtDoc
 	name string
 	typ convert.FuncTypeDefinition
-	body *ast.BlockStmt
+	body convert.BlockStatement
 
 	receiverName string
-	receiverType convert.Ident
+	receiverType convert.TypeDefinition
 	ptrReceiver bool
 }
 func (d *FuncDeclBuilder) Name() convert.Ident { return convert.NewIdent(d.name) }
 func (d *FuncDeclBuilder) Type() convert.FuncTypeDefinition { return d.typ }
-func (d *FuncDeclBuilder) Body() *ast.BlockStmt { return d.body }
+func (d *FuncDeclBuilder) Body() convert.BlockStatement { return d.body }
 func (d *FuncDeclBuilder) Receiver() (convert.Ident, convert.TypeDefinition) {
-	name := convert.NewIdent(d.receiverName)
-	var typ convert.TypeDefinition = d.receiverType
+	if d.receiverType == nil {
+		return nil, nil
+	}
+	var name convert.Ident
+	if d.receiverName != "" {
+		name = convert.NewIdent(d.receiverName)
+	}
+	typ := d.receiverType
 	if d.ptrReceiver {
-		typ = &builtPtr{typ}
+		typ = &builtPtr{referent: typ}
 	}
 
 	return name, typ
@@ -161,8 +280,13 @@ func (d *FuncDeclBuilder) WithDoc(lines ...string) *FuncDeclBuilder {
 	d.doc = lines
 	return d
 }
-func (d *FuncDeclBuilder) WithBody(body *ast.BlockStmt) *FuncDeclBuilder {
-	d.body = body
+// At sets the position reported for this declaration.
+func (d *FuncDeclBuilder) At(pos token.Position) *FuncDeclBuilder {
+	d.pos = pos
+	return d
+}
+func (d *FuncDeclBuilder) WithBody(stmts ...convert.Statement) *FuncDeclBuilder {
+	d.body = Block(stmts...)
 	return d
 }
 func (d *FuncDeclBuilder) AsMethodFor(id, typeName string) *FuncDeclBuilder {
@@ -176,14 +300,33 @@ func (d *FuncDeclBuilder) AsMethodForPointer(id, typeName string) *FuncDeclBuild
 	d.ptrReceiver = true
 	return d
 }
+// AsMethodOf makes this a method with the given receiver type, which may
+// be any (pointer to a, possibly instantiated) type name, like `*List[T]`.
+// The receiver name may be empty.
+func (d *FuncDeclBuilder) AsMethodOf(id string, recvType convert.TypeDefinition) *FuncDeclBuilder {
+	d.receiverName = id
+	d.receiverType = recvType
+	d.ptrReceiver = false
+	return d
+}
 
 // ValueDeclBuilder builds a variable or constant declaration
 type ValueDeclBuilder struct {
+	builtPosition
 	builtDoc
+	builtLineComment
 	isConst bool
 	name string
 	typ convert.TypeDefinition
-	val ast.Expr
+	val convert.Expression
+	iota int
+	// value is the evaluated value of a constant copied by FromConvert,
+	// whose expression may reference constants that can't be resolved
+	// once copied.
+	value constant.Value
+	// tuple holds the variables initialized together with this one,
+	// shared between them (see VarTuple).
+	tuple []convert.ValueDeclaration
 }
 func (d *ValueDeclBuilder) IsConst() bool { return d.isConst }
 func (d *ValueDeclBuilder) Name() convert.Ident {
@@ -191,19 +334,38 @@ func (d *ValueDeclBuilder) Name() convert.Ident {
 	return convert.NewIdent(d.name)
 }
 func (d *ValueDeclBuilder) Type() convert.TypeDefinition { return d.typ }
-func (d *ValueDeclBuilder) Value() ast.Expr { return d.val }
+func (d *ValueDeclBuilder) Value() convert.Expression { return d.val }
+func (d *ValueDeclBuilder) Tuple() []convert.ValueDeclaration { return d.tuple }
+func (d *ValueDeclBuilder) Iota() int {
+	if !d.isConst { return -1 }
+	return d.iota
+}
+func (d *ValueDeclBuilder) ConstValue() constant.Value {
+	if !d.isConst { return nil }
+	if d.value != nil { return d.value }
+	return convert.EvalIotaConstExpr(d.val, d.iota)
+}
 func (d *ValueDeclBuilder) WithDoc(lines ...string) *ValueDeclBuilder {
 	d.doc = lines
 	return d
 }
-func Var(name string, typ convert.TypeDefinition, val ast.Expr) *ValueDeclBuilder {
+func (d *ValueDeclBuilder) WithLineComment(lines ...string) *ValueDeclBuilder {
+	d.lineComment = lines
+	return d
+}
+// At sets the position reported for this declaration.
+func (d *ValueDeclBuilder) At(pos token.Position) *ValueDeclBuilder {
+	d.pos = pos
+	return d
+}
+func Var(name string, typ convert.TypeDefinition, val convert.Expression) *ValueDeclBuilder {
 	return &ValueDeclBuilder{
 		name: name,
 		typ: typ,
 		val: val,
 	}
 }
-func Const(name string, typ convert.TypeDefinition, val ast.Expr) *ValueDeclBuilder {
+func Const(name string, typ convert.TypeDefinition, val convert.Expression) *ValueDeclBuilder {
 	return &ValueDeclBuilder{
 		isConst: true,
 		name: name,
@@ -212,14 +374,45 @@ func Const(name string, typ convert.TypeDefinition, val ast.Expr) *ValueDeclBuil
 	}
 }
 
+// VarTuple declares variables initialized together from the results of
+// a single multi-valued expression, like `var a, b = f()` (use "_" to
+// discard a result).  The type may be nil.  The variables are emitted
+// as a single declaration, so they should be declared (or grouped)
+// together.
+func VarTuple(typ convert.TypeDefinition, val convert.Expression, names ...string) []*ValueDeclBuilder {
+	res := make([]*ValueDeclBuilder, len(names))
+	tuple := make([]convert.ValueDeclaration, len(names))
+	for i, name := range names {
+		res[i] = &ValueDeclBuilder{name: name, typ: typ, val: val, tuple: tuple}
+		tuple[i] = res[i]
+	}
+	return res
+}
+
+// AssertImplements declares a blank variable asserting that the given
+// type implements the given interface, like `var _ Iface = (*T)(nil)`.
+// Non-pointer types are asserted with an empty composite literal
+// (`T{}`), so must be structs, arrays, slices, or maps.
+func AssertImplements(iface, typ convert.TypeDefinition) *ValueDeclBuilder {
+	var val convert.Expression
+	if _, isPtr := typ.(convert.PointerTypeDefinition); isPtr {
+		val = Convert(typ, convert.NewIdent("nil"))
+	} else {
+		val = Composite(typ)
+	}
+	return Var("_", iface, val)
+}
+
 // PackageBuilder builds a package (convert.AST)
 type PackageBuilder struct {
+	builtDoc
 	name string
 
 	types []convert.TypeDeclaration
 	funcs []convert.FuncDeclaration
 	vals  []convert.ValueDeclaration
 	imports []convert.Import
+	groups []convert.DeclGroup
 }
 
 func (b *PackageBuilder) PackageName() convert.Ident { return convert.NewIdent(b.name) }
@@ -227,22 +420,54 @@ func (b *PackageBuilder) Types() []convert.TypeDeclaration { return b.types }
 func (b *PackageBuilder) Funcs() []convert.FuncDeclaration { return b.funcs }
 func (b *PackageBuilder) Values() []convert.ValueDeclaration { return b.vals }
 func (b *PackageBuilder) Imports() []convert.Import { return b.imports }
+func (b *PackageBuilder) Groups() []convert.DeclGroup { return b.groups }
 
 func Package(name string) *PackageBuilder {
 	return &PackageBuilder{
 		name: name,
 	}
 }
+// WithDoc sets the package docs.
+func (b *PackageBuilder) WithDoc(lines ...string) *PackageBuilder {
+	b.doc = lines
+	return b
+}
+// Import adds an import of the given path, which may be quoted or unquoted.
 func (b *PackageBuilder) Import(path string) *PackageBuilder {
-	b.imports = append(b.imports, &builtImport{path: path})
+	b.imports = append(b.imports, &builtImport{path: unquoteImportPath(path)})
 	return b
 }
+// ImportAs adds an import of the given path with an explicit name
+// (which may be `.` or `_`).
 func (b *PackageBuilder) ImportAs(name, path string) *PackageBuilder {
-	b.imports = append(b.imports, &builtImport{alias: name, path: path})
+	b.imports = append(b.imports, &builtImport{alias: name, path: unquoteImportPath(path)})
 	return b
 }
+func unquoteImportPath(path string) string {
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
+// Declare adds a declaration, or a DeclGroup of declarations, to the package.
 func (b *PackageBuilder) Declare(decl convert.Declaration) *PackageBuilder {
+	if err := b.DeclareChecked(decl); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// DeclareChecked is like Declare, but returns an error for
+// unknown declaration types instead of panicking.
+func (b *PackageBuilder) DeclareChecked(decl convert.Declaration) error {
 	switch typedDecl := decl.(type) {
+	case convert.DeclGroup:
+		for _, member := range typedDecl.Declarations() {
+			if err := b.DeclareChecked(member); err != nil {
+				return err
+			}
+		}
+		b.groups = append(b.groups, typedDecl)
 	case convert.TypeDeclaration:
 		b.types = append(b.types, typedDecl)
 	case convert.FuncDeclaration:
@@ -250,23 +475,35 @@ func (b *PackageBuilder) Declare(decl convert.Declaration) *PackageBuilder {
 	case convert.ValueDeclaration:
 		b.vals = append(b.vals, typedDecl)
 	default:
-		// TODO: figure out a better way to communicate this
-		panic(fmt.Sprintf("unknown declaration type %T", decl))
+		return fmt.Errorf("unknown declaration type %T", decl)
 	}
-
-	return b
+	return nil
 }
 
 // FuncTypeBuilder builds a function type definition
 type FuncTypeBuilder struct {
+	builtPosition
+	typeParams []convert.Field
 	params []convert.Field
 	results []convert.Field
 }
+func (b *FuncTypeBuilder) TypeParams() []convert.Field { return b.typeParams }
 func (b *FuncTypeBuilder) Params() []convert.Field { return b.params }
 func (b *FuncTypeBuilder) Results() []convert.Field { return b.results }
 
 func Function() *FuncTypeBuilder { return &FuncTypeBuilder{} }
+// At sets the position reported for this function type.
+func (b *FuncTypeBuilder) At(pos token.Position) *FuncTypeBuilder {
+	b.pos = pos
+	return b
+}
 
+// TypeParam adds a type parameter, making this a generic function.
+// Only functions used with DeclaredAs may have type parameters.
+func (b *FuncTypeBuilder) TypeParam(name string, constraint convert.TypeDefinition) *FuncTypeBuilder {
+	b.typeParams = append(b.typeParams, &builtField{name: name, typ: constraint})
+	return b
+}
 func (b *FuncTypeBuilder) Param(name string, typ convert.TypeDefinition) *FuncTypeBuilder {
 	b.params = append(b.params, &builtField{name: name, typ: typ})
 	return b
@@ -283,31 +520,153 @@ func (b *FuncTypeBuilder) DeclaredAs(name string) *FuncDeclBuilder {
 }
 
 // StructTypeBuilder builds a struct type definition
-type StructTypeBuilder struct { fields []convert.Field }
+type StructTypeBuilder struct { builtPosition; fields []convert.Field }
 func Struct() *StructTypeBuilder { return &StructTypeBuilder{} }
 func (b *StructTypeBuilder) Fields() []convert.Field { return b.fields }
+// At sets the position reported for this struct type.
+func (b *StructTypeBuilder) At(pos token.Position) *StructTypeBuilder {
+	b.pos = pos
+	return b
+}
 
+// Field adds a field with the given (unquoted) tag, like `json:"name"`.
+// A field with an empty name is embedded (see Embed).  See also WithFieldTag.
 func (b *StructTypeBuilder) Field(name string, typ convert.TypeDefinition, tag string) *StructTypeBuilder {
-	// TODO: field-level doc?
 	b.fields = append(b.fields, &builtField{
 		name: name,
 		typ: typ,
 		tag: reflect.StructTag(tag),
+		embedded: name == "",
 	})
 	return b
 }
+// Embed adds an embedded field, whose type must be a (possibly
+// qualified or instantiated) type name, or a pointer to one.
+func (b *StructTypeBuilder) Embed(typ convert.TypeDefinition) *StructTypeBuilder {
+	named := typ
+	if ptr, isPtr := named.(convert.PointerTypeDefinition); isPtr {
+		named = ptr.ReferentType()
+	}
+	if inst, isInst := named.(convert.InstantiatedTypeDefinition); isInst {
+		named = inst.GenericType()
+	}
+	if _, isIdent := named.(convert.Ident); !isIdent {
+		panic(fmt.Sprintf("can't embed %T in a struct, only type names or pointers to them", typ))
+	}
+	b.fields = append(b.fields, &builtField{
+		typ: typ,
+		embedded: true,
+	})
+	return b
+}
+// WithFieldTag sets the tag on the most recently added field.
+func (b *StructTypeBuilder) WithFieldTag(tag convert.StructTag) *StructTypeBuilder {
+	b.lastField().tag = tag.StructTag()
+	return b
+}
+// WithFieldDoc sets the docs on the most recently added field.
+func (b *StructTypeBuilder) WithFieldDoc(lines ...string) *StructTypeBuilder {
+	b.lastField().doc = lines
+	return b
+}
+// WithFieldComment sets the trailing comment on the most recently added field.
+func (b *StructTypeBuilder) WithFieldComment(lines ...string) *StructTypeBuilder {
+	b.lastField().lineComment = lines
+	return b
+}
+// WithFieldPosition sets the position reported for the most recently added field.
+func (b *StructTypeBuilder) WithFieldPosition(pos token.Position) *StructTypeBuilder {
+	b.lastField().pos = pos
+	return b
+}
+func (b *StructTypeBuilder) lastField() *builtField {
+	if len(b.fields) == 0 {
+		panic("no fields have been added to this struct")
+	}
+	return b.fields[len(b.fields)-1].(*builtField)
+}
 
-type InterfaceTypeBuilder struct { methods []convert.Field }
+type InterfaceTypeBuilder struct { builtPosition; methods []convert.Field; embeds []convert.Field; elems []convert.Field }
 func Interface() *InterfaceTypeBuilder { return &InterfaceTypeBuilder{} }
 func (b *InterfaceTypeBuilder) Methods() []convert.Field { return b.methods }
+func (b *InterfaceTypeBuilder) Embeds() []convert.Field { return b.embeds }
+// Elements returns the methods and embeds in the order they were added.
+func (b *InterfaceTypeBuilder) Elements() []convert.Field { return b.elems }
+// At sets the position reported for this interface type.
+func (b *InterfaceTypeBuilder) At(pos token.Position) *InterfaceTypeBuilder {
+	b.pos = pos
+	return b
+}
 
 func (b *InterfaceTypeBuilder) Method(name string, typ convert.FuncTypeDefinition) *InterfaceTypeBuilder {
 	// TODO: method-level doc?
-	b.methods = append(b.methods, &builtField{
+	return b.addElement(&builtField{
 		name: name,
 		typ: typ,
 	})
+}
+// Embed adds an embedded interface, or a constraint element
+// like Union(Tilde(convert.NewIdent("int")), ...).
+func (b *InterfaceTypeBuilder) Embed(typ convert.TypeDefinition) *InterfaceTypeBuilder {
+	return b.addElement(&builtField{
+		typ: typ,
+		embedded: true,
+	})
+}
+func (b *InterfaceTypeBuilder) addElement(elem convert.Field) *InterfaceTypeBuilder {
+	if elem.IsEmbedded() {
+		b.embeds = append(b.embeds, elem)
+	} else {
+		b.methods = append(b.methods, elem)
+	}
+	b.elems = append(b.elems, elem)
 	return b
 }
 
-// TODO: support for iota and groupings
+// DeclGroupBuilder builds a parenthesized group of declarations
+type DeclGroupBuilder struct {
+	builtPosition
+	builtDoc
+	kind token.Token
+	decls []convert.Declaration
+}
+func (g *DeclGroupBuilder) Kind() token.Token { return g.kind }
+func (g *DeclGroupBuilder) Declarations() []convert.Declaration { return g.decls }
+func (g *DeclGroupBuilder) WithDoc(lines ...string) *DeclGroupBuilder {
+	g.doc = lines
+	return g
+}
+// At sets the position reported for this group.
+func (g *DeclGroupBuilder) At(pos token.Position) *DeclGroupBuilder {
+	g.pos = pos
+	return g
+}
+
+// ConstGroup groups constants into a `const (...)` block, setting their
+// iota by position.  Constants declared without a type or value implicitly
+// repeat those of the preceding constant, like in Go.
+func ConstGroup(consts ...*ValueDeclBuilder) *DeclGroupBuilder {
+	res := &DeclGroupBuilder{kind: token.CONST}
+	for i, decl := range consts {
+		decl.iota = i
+		if i > 0 && decl.typ == nil && decl.val == nil {
+			decl.typ, decl.val = consts[i-1].typ, consts[i-1].val
+		}
+		res.decls = append(res.decls, decl)
+	}
+	return res
+}
+func VarGroup(vars ...*ValueDeclBuilder) *DeclGroupBuilder {
+	res := &DeclGroupBuilder{kind: token.VAR}
+	for _, decl := range vars {
+		res.decls = append(res.decls, decl)
+	}
+	return res
+}
+func TypeGroup(types ...*TypeDeclarationBuilder) *DeclGroupBuilder {
+	res := &DeclGroupBuilder{kind: token.TYPE}
+	for _, decl := range types {
+		res.decls = append(res.decls, decl)
+	}
+	return res
+}
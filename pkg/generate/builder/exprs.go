@@ -0,0 +1,199 @@
+package builder
+
+import (
+	"strconv"
+
+	"go/token"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// NB: identifiers used as expressions are just convert.NewIdent
+// and convert.NewQualifiedIdent, and types used as expressions
+// are just the normal type definition builders.
+
+// builtBasicLit represents a concrete basic literal
+type builtBasicLit struct {
+	kind token.Token
+	lit string
+}
+func (e *builtBasicLit) Kind() token.Token { return e.kind }
+func (e *builtBasicLit) Literal() string { return e.lit }
+// BasicLit constructs a literal of the given kind from its source form
+// (including quotes for strings and chars).
+func BasicLit(kind token.Token, literal string) convert.BasicLiteral {
+	return &builtBasicLit{kind: kind, lit: literal}
+}
+func IntLit(val int) convert.BasicLiteral {
+	return BasicLit(token.INT, strconv.Itoa(val))
+}
+func FloatLit(val float64) convert.BasicLiteral {
+	lit := strconv.FormatFloat(val, 'g', -1, 64)
+	if _, err := strconv.Atoi(lit); err == nil {
+		// make sure it's still a float literal
+		lit += ".0"
+	}
+	return BasicLit(token.FLOAT, lit)
+}
+func StringLit(val string) convert.BasicLiteral {
+	return BasicLit(token.STRING, strconv.Quote(val))
+}
+func CharLit(val rune) convert.BasicLiteral {
+	return BasicLit(token.CHAR, strconv.QuoteRune(val))
+}
+
+// builtComposite represents a concrete composite literal
+type builtComposite struct {
+	typ convert.TypeDefinition
+	elems []convert.Expression
+}
+func (e *builtComposite) LiteralType() convert.TypeDefinition { return e.typ }
+func (e *builtComposite) Elements() []convert.Expression { return e.elems }
+// Composite constructs a composite literal.  The type may be nil
+// for literals nested in other composite literals.  Use KeyValue
+// for keyed elements.
+func Composite(typ convert.TypeDefinition, elems ...convert.Expression) convert.CompositeLiteral {
+	return &builtComposite{typ: typ, elems: elems}
+}
+
+// builtKeyValue represents a concrete keyed element
+type builtKeyValue struct { key, value convert.Expression }
+func (e *builtKeyValue) Key() convert.Expression { return e.key }
+func (e *builtKeyValue) Value() convert.Expression { return e.value }
+func KeyValue(key, value convert.Expression) convert.KeyValueExpression {
+	return &builtKeyValue{key: key, value: value}
+}
+
+// builtFuncLit represents a concrete function literal
+type builtFuncLit struct {
+	typ convert.FuncTypeDefinition
+	body convert.BlockStatement
+}
+func (e *builtFuncLit) Type() convert.FuncTypeDefinition { return e.typ }
+func (e *builtFuncLit) Body() convert.BlockStatement { return e.body }
+func FuncLit(typ convert.FuncTypeDefinition, body ...convert.Statement) convert.FuncLiteral {
+	return &builtFuncLit{typ: typ, body: Block(body...)}
+}
+
+// CallBuilder builds a function or method call
+type CallBuilder struct {
+	fn convert.Expression
+	args []convert.Expression
+	hasEllipsis bool
+}
+func (e *CallBuilder) Func() convert.Expression { return e.fn }
+func (e *CallBuilder) Args() []convert.Expression { return e.args }
+func (e *CallBuilder) HasEllipsis() bool { return e.hasEllipsis }
+// WithEllipsis passes the last argument as variadic arguments (`f(xs...)`).
+func (e *CallBuilder) WithEllipsis() *CallBuilder {
+	e.hasEllipsis = true
+	return e
+}
+func Call(fn convert.Expression, args ...convert.Expression) *CallBuilder {
+	return &CallBuilder{fn: fn, args: args}
+}
+
+// builtConversion represents a concrete type conversion
+type builtConversion struct {
+	typ convert.TypeDefinition
+	operand convert.Expression
+}
+func (e *builtConversion) TargetType() convert.TypeDefinition { return e.typ }
+func (e *builtConversion) Operand() convert.Expression { return e.operand }
+func Convert(typ convert.TypeDefinition, operand convert.Expression) convert.ConversionExpression {
+	return &builtConversion{typ: typ, operand: operand}
+}
+
+// builtSelector represents a concrete field or method selector
+type builtSelector struct {
+	operand convert.Expression
+	sel string
+}
+func (e *builtSelector) Operand() convert.Expression { return e.operand }
+func (e *builtSelector) Selector() convert.Ident { return convert.NewIdent(e.sel) }
+// Selector selects a field or method of the operand.  For references to
+// other packages, use convert.NewQualifiedIdent instead.
+func Selector(operand convert.Expression, name string) convert.SelectorExpression {
+	return &builtSelector{operand: operand, sel: name}
+}
+
+// builtIndex represents a concrete index expression
+type builtIndex struct {
+	operand convert.Expression
+	indices []convert.Expression
+}
+func (e *builtIndex) Operand() convert.Expression { return e.operand }
+func (e *builtIndex) Indices() []convert.Expression { return e.indices }
+// Index indexes the operand, or instantiates a generic
+// function if given multiple indices.
+func Index(operand convert.Expression, indices ...convert.Expression) convert.IndexExpression {
+	return &builtIndex{operand: operand, indices: indices}
+}
+
+// builtSlice represents a concrete slice expression
+type builtSlice struct {
+	operand convert.Expression
+	low, high, max convert.Expression
+	full bool
+}
+func (e *builtSlice) Operand() convert.Expression { return e.operand }
+func (e *builtSlice) Low() convert.Expression { return e.low }
+func (e *builtSlice) High() convert.Expression { return e.high }
+func (e *builtSlice) Max() convert.Expression { return e.max }
+func (e *builtSlice) IsFullSlice() bool { return e.full }
+// Slice constructs `operand[low:high]`.  Either bound may be nil.
+func Slice(operand, low, high convert.Expression) convert.SliceExpression {
+	return &builtSlice{operand: operand, low: low, high: high}
+}
+// FullSlice constructs `operand[low:high:max]`.
+func FullSlice(operand, low, high, max convert.Expression) convert.SliceExpression {
+	return &builtSlice{operand: operand, low: low, high: high, max: max, full: true}
+}
+
+// builtTypeAssert represents a concrete type assertion
+type builtTypeAssert struct {
+	operand convert.Expression
+	typ convert.TypeDefinition
+}
+func (e *builtTypeAssert) Operand() convert.Expression { return e.operand }
+func (e *builtTypeAssert) AssertedType() convert.TypeDefinition { return e.typ }
+// TypeAssert constructs `operand.(typ)`.  A nil type constructs
+// a type switch guard (`operand.(type)`).
+func TypeAssert(operand convert.Expression, typ convert.TypeDefinition) convert.TypeAssertExpression {
+	return &builtTypeAssert{operand: operand, typ: typ}
+}
+
+// builtUnary represents a concrete unary expression
+type builtUnary struct {
+	op token.Token
+	operand convert.Expression
+}
+func (e *builtUnary) Op() token.Token { return e.op }
+func (e *builtUnary) Operand() convert.Expression { return e.operand }
+func Unary(op token.Token, operand convert.Expression) convert.UnaryExpression {
+	return &builtUnary{op: op, operand: operand}
+}
+func Not(operand convert.Expression) convert.UnaryExpression { return Unary(token.NOT, operand) }
+func Negate(operand convert.Expression) convert.UnaryExpression { return Unary(token.SUB, operand) }
+func AddressOf(operand convert.Expression) convert.UnaryExpression { return Unary(token.AND, operand) }
+func Deref(operand convert.Expression) convert.UnaryExpression { return Unary(token.MUL, operand) }
+func Receive(operand convert.Expression) convert.UnaryExpression { return Unary(token.ARROW, operand) }
+
+// builtBinary represents a concrete binary expression
+type builtBinary struct {
+	op token.Token
+	left, right convert.Expression
+}
+func (e *builtBinary) Op() token.Token { return e.op }
+func (e *builtBinary) Left() convert.Expression { return e.left }
+func (e *builtBinary) Right() convert.Expression { return e.right }
+func Binary(left convert.Expression, op token.Token, right convert.Expression) convert.BinaryExpression {
+	return &builtBinary{op: op, left: left, right: right}
+}
+
+// builtParen represents a concrete parenthesized expression
+type builtParen struct { inner convert.Expression }
+func (e *builtParen) Inner() convert.Expression { return e.inner }
+func Paren(inner convert.Expression) convert.ParenExpression {
+	return &builtParen{inner: inner}
+}
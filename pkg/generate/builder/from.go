@@ -0,0 +1,337 @@
+package builder
+
+import (
+	"reflect"
+
+	"go/token"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// FromConvert copies any implementation of the convert interfaces (such
+// as the wrappers from convert.FromRaw) into the corresponding builders,
+// so that it can be modified and then generated again.  It returns:
+//
+// - a *PackageBuilder for a convert.AST
+// - a *DeclGroupBuilder for a convert.DeclGroup
+// - a *TypeDeclarationBuilder for a convert.TypeDeclaration
+// - a *FuncDeclBuilder for a convert.FuncDeclaration
+// - a *ValueDeclBuilder for a convert.ValueDeclaration
+// - a built convert.Import or convert.Field for those
+// - a built convert.TypeDefinition for type definitions, where structs,
+//   interfaces, and function types are a *StructTypeBuilder,
+//   *InterfaceTypeBuilder, and *FuncTypeBuilder respectively
+//
+// Docs, line comments, tags, and positions are copied along with the
+// rest.  Expressions and function bodies can't be modified, so they're
+// shared with the original instead of being copied.  Variables in a tuple
+// (see convert.ValueDeclaration.Tuple) are copied together.  Qualified identifiers
+// keep their resolved import path, but other identifiers are copied by
+// name only, so type information is lost.
+func FromConvert(node interface{}) interface{} {
+	return (&copier{}).copy(node)
+}
+
+// copier copies convert nodes into builders,
+// optionally rewriting types as it goes.
+type copier struct {
+	// rewrite, if set, is called on each type
+	// definition before it's copied (see Rewrite).
+	rewrite TypeRewriter
+	// tupleCopies holds the copies of variables in tuples, which
+	// are copied all at once, so that they share their tuple.
+	tupleCopies map[convert.ValueDeclaration]*ValueDeclBuilder
+}
+
+func (c *copier) copy(node interface{}) interface{} {
+	switch typed := node.(type) {
+	case convert.AST:
+		return c.copyAST(typed)
+	case convert.DeclGroup:
+		return c.copyDeclGroup(typed, nil)
+	case convert.TypeDeclaration:
+		return c.copyTypeDecl(typed)
+	case convert.FuncDeclaration:
+		return c.copyFuncDecl(typed)
+	case convert.ValueDeclaration:
+		return c.copyValueDecl(typed)
+	case convert.Import:
+		return c.copyImport(typed)
+	case convert.Field:
+		return c.copyField(typed)
+	default:
+		return c.copyType(node)
+	}
+}
+
+func (c *copier) copyAST(a convert.AST) *PackageBuilder {
+	res := &PackageBuilder{
+		builtDoc: builtDoc{doc: docOf(a)},
+		name: a.PackageName().Name(),
+	}
+	for _, imp := range a.Imports() {
+		res.imports = append(res.imports, c.copyImport(imp))
+	}
+
+	// the declarations in groups are the same values returned
+	// individually, so make sure their copies are too
+	copies := make(map[convert.Declaration]convert.Declaration)
+	remember := func(orig, copied convert.Declaration) {
+		if reflect.TypeOf(orig).Comparable() {
+			copies[orig] = copied
+		}
+	}
+	for _, decl := range a.Types() {
+		copied := c.copyTypeDecl(decl)
+		remember(decl, copied)
+		res.types = append(res.types, copied)
+	}
+	for _, decl := range a.Funcs() {
+		res.funcs = append(res.funcs, c.copyFuncDecl(decl))
+	}
+	for _, decl := range a.Values() {
+		copied := c.copyValueDecl(decl)
+		remember(decl, copied)
+		res.vals = append(res.vals, copied)
+	}
+	for _, group := range a.Groups() {
+		res.groups = append(res.groups, c.copyDeclGroup(group, copies))
+	}
+	return res
+}
+
+// copyDeclGroup copies a group, reusing the copies of
+// its declarations from the given map if present.
+func (c *copier) copyDeclGroup(g convert.DeclGroup, copies map[convert.Declaration]convert.Declaration) *DeclGroupBuilder {
+	res := &DeclGroupBuilder{
+		builtPosition: builtPosition{pos: positionOf(g)},
+		builtDoc: builtDoc{doc: docOf(g)},
+		kind: g.Kind(),
+	}
+	for _, decl := range g.Declarations() {
+		if reflect.TypeOf(decl).Comparable() {
+			if copied, known := copies[decl]; known {
+				res.decls = append(res.decls, copied)
+				continue
+			}
+		}
+		switch typedDecl := decl.(type) {
+		case convert.TypeDeclaration:
+			res.decls = append(res.decls, c.copyTypeDecl(typedDecl))
+		case convert.ValueDeclaration:
+			res.decls = append(res.decls, c.copyValueDecl(typedDecl))
+		default:
+			res.decls = append(res.decls, decl)
+		}
+	}
+	return res
+}
+
+func (c *copier) copyTypeDecl(d convert.TypeDeclaration) *TypeDeclarationBuilder {
+	return &TypeDeclarationBuilder{
+		builtPosition: builtPosition{pos: positionOf(d)},
+		builtDoc: builtDoc{doc: docOf(d)},
+		builtLineComment: builtLineComment{lineComment: lineCommentOf(d)},
+		name: d.Name().Name(),
+		isAlias: d.IsAlias(),
+		typ: c.copyType(d.Type()),
+		typeParams: c.copyFields(d.TypeParams()),
+	}
+}
+
+func (c *copier) copyFuncDecl(d convert.FuncDeclaration) *FuncDeclBuilder {
+	res := &FuncDeclBuilder{
+		builtPosition: builtPosition{pos: positionOf(d)},
+		builtDoc: builtDoc{doc: docOf(d)},
+		name: d.Name().Name(),
+		typ: c.copyFuncType(d.Type()),
+		body: d.Body(),
+	}
+	if recvName, recvType := d.Receiver(); recvType != nil {
+		res.receiverName = nameOf(recvName)
+		res.receiverType = c.copyType(recvType)
+	}
+	return res
+}
+
+// copyValueDecl copies a value declaration, copying the
+// rest of its tuple along with it if it's part of one.
+func (c *copier) copyValueDecl(d convert.ValueDeclaration) *ValueDeclBuilder {
+	tuple := d.Tuple()
+	if len(tuple) == 0 || !reflect.TypeOf(d).Comparable() {
+		return c.copyValue(d)
+	}
+	if copied, known := c.tupleCopies[d]; known {
+		return copied
+	}
+
+	if c.tupleCopies == nil {
+		c.tupleCopies = make(map[convert.ValueDeclaration]*ValueDeclBuilder)
+	}
+	copiedTuple := make([]convert.ValueDeclaration, len(tuple))
+	for i, member := range tuple {
+		copied := c.copyValue(member)
+		copied.tuple = copiedTuple
+		copiedTuple[i] = copied
+		c.tupleCopies[member] = copied
+	}
+	return c.tupleCopies[d]
+}
+
+func (c *copier) copyValue(d convert.ValueDeclaration) *ValueDeclBuilder {
+	res := &ValueDeclBuilder{
+		builtPosition: builtPosition{pos: positionOf(d)},
+		builtDoc: builtDoc{doc: docOf(d)},
+		builtLineComment: builtLineComment{lineComment: lineCommentOf(d)},
+		isConst: d.IsConst(),
+		name: nameOf(d.Name()),
+		typ: c.copyType(d.Type()),
+		val: d.Value(),
+	}
+	if res.isConst {
+		res.iota = d.Iota()
+		res.value = d.ConstValue()
+	}
+	return res
+}
+
+func (c *copier) copyImport(i convert.Import) convert.Import {
+	return &builtImport{
+		builtPosition: builtPosition{pos: positionOf(i)},
+		builtDoc: builtDoc{doc: docOf(i)},
+		builtLineComment: builtLineComment{lineComment: lineCommentOf(i)},
+		alias: nameOf(i.Name()),
+		path: i.ImportPath(),
+	}
+}
+
+func (c *copier) copyFields(fields []convert.Field) []convert.Field {
+	if fields == nil {
+		return nil
+	}
+	res := make([]convert.Field, len(fields))
+	for i, field := range fields {
+		res[i] = c.copyField(field)
+	}
+	return res
+}
+
+func (c *copier) copyField(f convert.Field) *builtField {
+	return &builtField{
+		builtPosition: builtPosition{pos: positionOf(f)},
+		builtDoc: builtDoc{doc: docOf(f)},
+		builtLineComment: builtLineComment{lineComment: lineCommentOf(f)},
+		name: nameOf(f.Name()),
+		typ: c.copyType(f.Type()),
+		tag: f.Tag(),
+		embedded: f.IsEmbedded(),
+	}
+}
+
+func (c *copier) copyFuncType(d convert.FuncTypeDefinition) *FuncTypeBuilder {
+	return &FuncTypeBuilder{
+		builtPosition: builtPosition{pos: positionOf(d)},
+		typeParams: c.copyFields(d.TypeParams()),
+		params: c.copyFields(d.Params()),
+		results: c.copyFields(d.Results()),
+	}
+}
+
+// copyType copies a type definition, or returns its rewritten
+// replacement.  Unknown kinds of type definitions are returned as-is.
+func (c *copier) copyType(def convert.TypeDefinition) convert.TypeDefinition {
+	if c.rewrite != nil && def != nil {
+		if replacement := c.rewrite(def); replacement != nil {
+			return replacement
+		}
+	}
+	pos := builtPosition{pos: positionOf(def)}
+	switch typed := def.(type) {
+	case nil:
+		return nil
+	case convert.StructTypeDefinition:
+		return &StructTypeBuilder{builtPosition: pos, fields: c.copyFields(typed.Fields())}
+	case convert.InterfaceTypeDefinition:
+		res := &InterfaceTypeBuilder{builtPosition: pos}
+		for _, elem := range c.copyFields(typed.Elements()) {
+			res.addElement(elem)
+		}
+		return res
+	case convert.FuncTypeDefinition:
+		return c.copyFuncType(typed)
+	case convert.MapTypeDefinition:
+		return &builtMap{builtPosition: pos, key: c.copyType(typed.KeyType()), value: c.copyType(typed.ValueType())}
+	case convert.ChanTypeDefinition:
+		recv, send := typed.Directions()
+		return &builtChan{builtPosition: pos, elem: c.copyType(typed.ValueType()), recv: recv, send: send}
+	case convert.PointerTypeDefinition:
+		return &builtPtr{builtPosition: pos, referent: c.copyType(typed.ReferentType())}
+	case convert.SplatTypeDefinition:
+		return &builtSplat{builtPosition: pos, elemType: c.copyType(typed.ElemType())}
+	case convert.ArrayTypeDefinition:
+		res := &builtArray{builtPosition: pos, elemType: c.copyType(typed.ElemType())}
+		switch length := typed.Length(); length {
+		case nil, convert.AutoLength:
+			res.length = length
+		default:
+			res.length = &builtLength{expr: length.Expr(), value: length.Value()}
+		}
+		return res
+	case convert.InstantiatedTypeDefinition:
+		return &builtInstantiation{
+			builtPosition: pos,
+			generic: c.copyType(typed.GenericType()),
+			args: c.copyTypes(typed.TypeArgs()),
+		}
+	case convert.UnionTypeDefinition:
+		return &builtUnion{builtPosition: pos, terms: c.copyTypes(typed.Terms())}
+	case convert.TildeTypeDefinition:
+		return &builtTilde{builtPosition: pos, typ: c.copyType(typed.Approximates())}
+	case convert.QualifiedIdent:
+		return convert.NewImportedIdent(typed.ImportPath(), typed.PackageName(), convert.NewIdent(typed.Name()))
+	case convert.Ident:
+		return convert.NewIdent(typed.Name())
+	default:
+		return def
+	}
+}
+
+func (c *copier) copyTypes(defs []convert.TypeDefinition) []convert.TypeDefinition {
+	res := make([]convert.TypeDefinition, len(defs))
+	for i, def := range defs {
+		res[i] = c.copyType(def)
+	}
+	return res
+}
+
+// positionOf returns the position of the given node,
+// or the zero position if it's unknown.
+func positionOf(node interface{}) token.Position {
+	if positioned, isPositioned := node.(convert.Positioned); isPositioned {
+		if pos := positioned.Position(); pos.IsValid() {
+			return pos
+		}
+	}
+	return token.Position{}
+}
+
+func docOf(node interface{}) []string {
+	if doced, isDoced := node.(convert.Doced); isDoced {
+		return append([]string(nil), doced.Doc()...)
+	}
+	return nil
+}
+
+func lineCommentOf(node interface{}) []string {
+	if commented, isCommented := node.(convert.LineCommented); isCommented {
+		return append([]string(nil), commented.LineComment()...)
+	}
+	return nil
+}
+
+func nameOf(id convert.Ident) string {
+	if id == nil {
+		return ""
+	}
+	return id.Name()
+}
@@ -0,0 +1,21 @@
+package builder
+
+import (
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// InferredType returns the declared type, or the type inferred from the
+// value (see convert.InferType).  Built values are self-contained, so
+// identifiers other than predeclared ones are never resolved.
+func (d *ValueDeclBuilder) InferredType() (convert.TypeDefinition, bool) {
+	if d.typ != nil {
+		return d.typ, false
+	}
+	for i, member := range d.tuple {
+		if member == convert.ValueDeclaration(d) {
+			return convert.InferTupleMemberType(d.val, i, len(d.tuple), nil), false
+		}
+	}
+	typ, untyped := convert.InferType(d.val, nil)
+	return typ, untyped && d.isConst
+}
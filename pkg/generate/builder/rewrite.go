@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// TypeRewriter maps a type definition to its replacement, or returns nil
+// to keep the definition (in which case the types within it are
+// rewritten instead).
+type TypeRewriter func(def convert.TypeDefinition) convert.TypeDefinition
+
+// Rewrite copies the given AST into a PackageBuilder, like FromConvert,
+// replacing types as it goes.  The rewriter is called on every type
+// definition reachable from the declarations, outermost first: declared
+// types, type params and constraints, fields and embeds, func params and
+// results, receivers, the types of values, and the elements of maps,
+// chans, pointers, arrays, instantiations, and unions.  Replacements are
+// used as-is, without rewriting the types within them.
+//
+// Types used in expressions and function bodies (such as conversions)
+// aren't rewritten, and neither are the names of declarations (use
+// TypeDeclarationBuilder.Named to rename those).
+//
+// (This lives here instead of in "pkg/convert", since it produces builders).
+func Rewrite(a convert.AST, rewriter TypeRewriter) *PackageBuilder {
+	return (&copier{rewrite: rewriter}).copyAST(a)
+}
+
+// RewriteType is like Rewrite, but rewrites a single type definition.
+func RewriteType(def convert.TypeDefinition, rewriter TypeRewriter) convert.TypeDefinition {
+	return (&copier{rewrite: rewriter}).copyType(def)
+}
+
+// ReplaceType returns a TypeRewriter which replaces every type that's
+// identical to old (as per convert.Identical) with replacement, such as
+// `*time.Time` with `metav1.Time`.  Qualified identifiers are compared
+// by import path when it's known, so the package names don't need to
+// match the ones used in the source.
+func ReplaceType(old, replacement convert.TypeDefinition) TypeRewriter {
+	return func(def convert.TypeDefinition) convert.TypeDefinition {
+		if convert.Identical(def, old) {
+			return replacement
+		}
+		return nil
+	}
+}
+
+// RenameType returns a TypeRewriter which replaces references to the
+// local or predeclared type with the given name (like `int`) with
+// references to newName.
+func RenameType(name, newName string) TypeRewriter {
+	return func(def convert.TypeDefinition) convert.TypeDefinition {
+		if imported, isImported := def.(convert.ImportedIdent); isImported && imported.ImportPath() != "" {
+			return nil
+		}
+		if _, isQualified := def.(convert.QualifiedIdent); isQualified {
+			return nil
+		}
+		if ident, isIdent := def.(convert.Ident); isIdent && ident.Name() == name {
+			return convert.NewIdent(newName)
+		}
+		return nil
+	}
+}
+
+// Chain returns a TypeRewriter which tries each of the given
+// rewriters in order, using the first replacement.
+func Chain(rewriters ...TypeRewriter) TypeRewriter {
+	return func(def convert.TypeDefinition) convert.TypeDefinition {
+		for _, rewriter := range rewriters {
+			if replacement := rewriter(def); replacement != nil {
+				return replacement
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,339 @@
+package builder
+
+import (
+	"go/token"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// labelOrNil converts a label name to an identifier,
+// with the empty string meaning no label
+func labelOrNil(label string) convert.Ident {
+	if label == "" { return nil }
+	return convert.NewIdent(label)
+}
+
+// builtBlock represents a concrete block of statements
+type builtBlock struct { stmts []convert.Statement }
+func (s *builtBlock) Statements() []convert.Statement { return s.stmts }
+func Block(stmts ...convert.Statement) convert.BlockStatement {
+	return &builtBlock{stmts: stmts}
+}
+
+// builtExprStmt represents a concrete expression statement
+type builtExprStmt struct { expr convert.Expression }
+func (s *builtExprStmt) Expression() convert.Expression { return s.expr }
+// Do uses an expression (normally a call) as a statement.
+func Do(expr convert.Expression) convert.ExpressionStatement {
+	return &builtExprStmt{expr: expr}
+}
+
+// AssignBuilder builds an assignment or short variable declaration
+type AssignBuilder struct {
+	left []convert.Expression
+	op token.Token
+	right []convert.Expression
+}
+func (s *AssignBuilder) Left() []convert.Expression { return s.left }
+func (s *AssignBuilder) Op() token.Token { return s.op }
+func (s *AssignBuilder) Right() []convert.Expression { return s.right }
+// To sets the values being assigned.
+func (s *AssignBuilder) To(right ...convert.Expression) *AssignBuilder {
+	s.right = right
+	return s
+}
+// Assign starts an assignment (`left = ...`).  Use To to set the values.
+func Assign(left ...convert.Expression) *AssignBuilder {
+	return &AssignBuilder{left: left, op: token.ASSIGN}
+}
+// Define starts a short variable declaration (`names := ...`).
+// Use To to set the values.
+func Define(names ...string) *AssignBuilder {
+	left := make([]convert.Expression, len(names))
+	for i, name := range names {
+		left[i] = convert.NewIdent(name)
+	}
+	return &AssignBuilder{left: left, op: token.DEFINE}
+}
+// AssignOp constructs an assignment operation (e.g. `left += right`
+// for token.ADD_ASSIGN).
+func AssignOp(left convert.Expression, op token.Token, right convert.Expression) *AssignBuilder {
+	return &AssignBuilder{
+		left: []convert.Expression{left},
+		op: op,
+		right: []convert.Expression{right},
+	}
+}
+
+// builtIncDec represents a concrete increment or decrement
+type builtIncDec struct {
+	target convert.Expression
+	inc bool
+}
+func (s *builtIncDec) Target() convert.Expression { return s.target }
+func (s *builtIncDec) IsIncrement() bool { return s.inc }
+func Inc(target convert.Expression) convert.IncDecStatement { return &builtIncDec{target: target, inc: true} }
+func Dec(target convert.Expression) convert.IncDecStatement { return &builtIncDec{target: target} }
+
+// builtSend represents a concrete channel send
+type builtSend struct { ch, val convert.Expression }
+func (s *builtSend) Channel() convert.Expression { return s.ch }
+func (s *builtSend) SentValue() convert.Expression { return s.val }
+func Send(ch, val convert.Expression) convert.SendStatement {
+	return &builtSend{ch: ch, val: val}
+}
+
+// builtDeclStmt represents a concrete local declaration
+type builtDeclStmt struct { decls []convert.Declaration }
+func (s *builtDeclStmt) Declarations() []convert.Declaration { return s.decls }
+// DeclareLocal declares variables, constants, or types inside a function.
+// All the declarations must be of the same kind.
+func DeclareLocal(decls ...convert.Declaration) convert.DeclStatement {
+	return &builtDeclStmt{decls: decls}
+}
+
+// IfBuilder builds an if statement
+type IfBuilder struct {
+	init convert.Statement
+	cond convert.Expression
+	body convert.BlockStatement
+	elseBranch convert.Statement
+}
+func (s *IfBuilder) Init() convert.Statement { return s.init }
+func (s *IfBuilder) Cond() convert.Expression { return s.cond }
+func (s *IfBuilder) Body() convert.BlockStatement { return s.body }
+func (s *IfBuilder) ElseBranch() convert.Statement { return s.elseBranch }
+func If(cond convert.Expression) *IfBuilder {
+	return &IfBuilder{cond: cond, body: Block()}
+}
+// WithInit sets the statement run before the condition (`if init; cond {`).
+func (s *IfBuilder) WithInit(init convert.Statement) *IfBuilder {
+	s.init = init
+	return s
+}
+func (s *IfBuilder) Then(stmts ...convert.Statement) *IfBuilder {
+	s.body = Block(stmts...)
+	return s
+}
+func (s *IfBuilder) Else(stmts ...convert.Statement) *IfBuilder {
+	s.elseBranch = Block(stmts...)
+	return s
+}
+// ElseIf chains another if statement (`} else if cond {`).
+func (s *IfBuilder) ElseIf(next *IfBuilder) *IfBuilder {
+	s.elseBranch = next
+	return s
+}
+
+// ForBuilder builds a for loop
+type ForBuilder struct {
+	init convert.Statement
+	cond convert.Expression
+	post convert.Statement
+	body convert.BlockStatement
+}
+func (s *ForBuilder) Init() convert.Statement { return s.init }
+func (s *ForBuilder) Cond() convert.Expression { return s.cond }
+func (s *ForBuilder) Post() convert.Statement { return s.post }
+func (s *ForBuilder) Body() convert.BlockStatement { return s.body }
+// For starts a for loop.  A nil condition loops forever.
+func For(cond convert.Expression) *ForBuilder {
+	return &ForBuilder{cond: cond, body: Block()}
+}
+func (s *ForBuilder) WithInit(init convert.Statement) *ForBuilder {
+	s.init = init
+	return s
+}
+func (s *ForBuilder) WithPost(post convert.Statement) *ForBuilder {
+	s.post = post
+	return s
+}
+// Do sets the body of the loop.
+func (s *ForBuilder) Do(stmts ...convert.Statement) *ForBuilder {
+	s.body = Block(stmts...)
+	return s
+}
+
+// RangeBuilder builds a for-range loop
+type RangeBuilder struct {
+	key, value convert.Expression
+	define bool
+	over convert.Expression
+	body convert.BlockStatement
+}
+func (s *RangeBuilder) Key() convert.Expression { return s.key }
+func (s *RangeBuilder) Value() convert.Expression { return s.value }
+func (s *RangeBuilder) IsDefine() bool { return s.define }
+func (s *RangeBuilder) Range() convert.Expression { return s.over }
+func (s *RangeBuilder) Body() convert.BlockStatement { return s.body }
+// Range starts a loop over the given value.  Use Define or
+// Assign to set the key and value.
+func Range(over convert.Expression) *RangeBuilder {
+	return &RangeBuilder{over: over, body: Block()}
+}
+// Define declares the key and value variables (`for key, value := range`).
+// An empty value name omits the value.
+func (s *RangeBuilder) Define(key, value string) *RangeBuilder {
+	s.key, s.value = convert.NewIdent(key), nil
+	if value != "" {
+		s.value = convert.NewIdent(value)
+	}
+	s.define = true
+	return s
+}
+// Assign assigns the key and value to existing variables (`for key, value = range`).
+// The value may be nil.
+func (s *RangeBuilder) Assign(key, value convert.Expression) *RangeBuilder {
+	s.key, s.value = key, value
+	s.define = false
+	return s
+}
+// Do sets the body of the loop.
+func (s *RangeBuilder) Do(stmts ...convert.Statement) *RangeBuilder {
+	s.body = Block(stmts...)
+	return s
+}
+
+// CaseBuilder builds a clause of a switch or type switch
+type CaseBuilder struct {
+	values []convert.Expression
+	types []convert.TypeDefinition
+	stmts []convert.Statement
+	isDefault bool
+}
+func (c *CaseBuilder) IsDefault() bool { return c.isDefault }
+func (c *CaseBuilder) Values() []convert.Expression { return c.values }
+func (c *CaseBuilder) Types() []convert.TypeDefinition { return c.types }
+func (c *CaseBuilder) Statements() []convert.Statement { return c.stmts }
+func (c *CaseBuilder) Then(stmts ...convert.Statement) *CaseBuilder {
+	c.stmts = stmts
+	return c
+}
+// Case starts a clause of a switch matching the given values.
+func Case(values ...convert.Expression) *CaseBuilder { return &CaseBuilder{values: values} }
+// TypeCase starts a clause of a type switch matching the given types.
+func TypeCase(types ...convert.TypeDefinition) *CaseBuilder { return &CaseBuilder{types: types} }
+// Default starts the default clause of a switch or type switch.
+func Default() *CaseBuilder { return &CaseBuilder{isDefault: true} }
+
+// SwitchBuilder builds a switch statement
+type SwitchBuilder struct {
+	init convert.Statement
+	tag convert.Expression
+	clauses []convert.CaseClause
+}
+func (s *SwitchBuilder) Init() convert.Statement { return s.init }
+func (s *SwitchBuilder) Tag() convert.Expression { return s.tag }
+func (s *SwitchBuilder) Clauses() []convert.CaseClause { return s.clauses }
+// Switch constructs a switch on the given tag (which may be nil for `switch {`).
+func Switch(tag convert.Expression, clauses ...*CaseBuilder) *SwitchBuilder {
+	res := &SwitchBuilder{tag: tag}
+	for _, clause := range clauses {
+		res.clauses = append(res.clauses, clause)
+	}
+	return res
+}
+func (s *SwitchBuilder) WithInit(init convert.Statement) *SwitchBuilder {
+	s.init = init
+	return s
+}
+
+// TypeSwitchBuilder builds a type switch statement
+type TypeSwitchBuilder struct {
+	init convert.Statement
+	binding string
+	subject convert.Expression
+	clauses []convert.TypeCaseClause
+}
+func (s *TypeSwitchBuilder) Init() convert.Statement { return s.init }
+func (s *TypeSwitchBuilder) Binding() convert.Ident { return labelOrNil(s.binding) }
+func (s *TypeSwitchBuilder) Subject() convert.Expression { return s.subject }
+func (s *TypeSwitchBuilder) Clauses() []convert.TypeCaseClause { return s.clauses }
+// TypeSwitch constructs a switch on the type of the subject, optionally
+// binding it to a variable (`switch binding := subject.(type)`).
+func TypeSwitch(binding string, subject convert.Expression, clauses ...*CaseBuilder) *TypeSwitchBuilder {
+	res := &TypeSwitchBuilder{binding: binding, subject: subject}
+	for _, clause := range clauses {
+		res.clauses = append(res.clauses, clause)
+	}
+	return res
+}
+func (s *TypeSwitchBuilder) WithInit(init convert.Statement) *TypeSwitchBuilder {
+	s.init = init
+	return s
+}
+
+// CommBuilder builds a clause of a select statement
+type CommBuilder struct {
+	comm convert.Statement
+	stmts []convert.Statement
+}
+func (c *CommBuilder) Comm() convert.Statement { return c.comm }
+func (c *CommBuilder) Statements() []convert.Statement { return c.stmts }
+func (c *CommBuilder) Then(stmts ...convert.Statement) *CommBuilder {
+	c.stmts = stmts
+	return c
+}
+// SelectCase starts a clause of a select statement for the given send (see
+// Send) or receive (see Do and Define with Receive).  A nil comm is the
+// default clause.
+func SelectCase(comm convert.Statement) *CommBuilder { return &CommBuilder{comm: comm} }
+
+// builtSelect represents a concrete select statement
+type builtSelect struct { clauses []convert.CommClause }
+func (s *builtSelect) Clauses() []convert.CommClause { return s.clauses }
+func Select(clauses ...*CommBuilder) convert.SelectStatement {
+	res := &builtSelect{}
+	for _, clause := range clauses {
+		res.clauses = append(res.clauses, clause)
+	}
+	return res
+}
+
+// builtReturn represents a concrete return statement
+type builtReturn struct { results []convert.Expression }
+func (s *builtReturn) Results() []convert.Expression { return s.results }
+func Return(results ...convert.Expression) convert.ReturnStatement {
+	return &builtReturn{results: results}
+}
+
+// builtDefer represents a concrete defer statement
+type builtDefer struct { call convert.CallExpression }
+func (s *builtDefer) Call() convert.CallExpression { return s.call }
+func (s *builtDefer) IsDefer() struct{} { return struct{}{} }
+func Defer(call convert.CallExpression) convert.DeferStatement {
+	return &builtDefer{call: call}
+}
+
+// builtGo represents a concrete go statement
+type builtGo struct { call convert.CallExpression }
+func (s *builtGo) Call() convert.CallExpression { return s.call }
+func (s *builtGo) IsGo() struct{} { return struct{}{} }
+func Go(call convert.CallExpression) convert.GoStatement {
+	return &builtGo{call: call}
+}
+
+// builtBranch represents a concrete break, continue, goto, or fallthrough
+type builtBranch struct {
+	keyword token.Token
+	label string
+}
+func (s *builtBranch) Keyword() token.Token { return s.keyword }
+func (s *builtBranch) Label() convert.Ident { return labelOrNil(s.label) }
+// Break constructs a break statement, with an optional label.
+func Break(label string) convert.BranchStatement { return &builtBranch{keyword: token.BREAK, label: label} }
+// Continue constructs a continue statement, with an optional label.
+func Continue(label string) convert.BranchStatement { return &builtBranch{keyword: token.CONTINUE, label: label} }
+func Goto(label string) convert.BranchStatement { return &builtBranch{keyword: token.GOTO, label: label} }
+func Fallthrough() convert.BranchStatement { return &builtBranch{keyword: token.FALLTHROUGH} }
+
+// builtLabeled represents a concrete labeled statement
+type builtLabeled struct {
+	label string
+	stmt convert.Statement
+}
+func (s *builtLabeled) Label() convert.Ident { return convert.NewIdent(s.label) }
+func (s *builtLabeled) Statement() convert.Statement { return s.stmt }
+func Labeled(label string, stmt convert.Statement) convert.LabeledStatement {
+	return &builtLabeled{label: label, stmt: stmt}
+}
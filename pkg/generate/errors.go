@@ -0,0 +1,57 @@
+package generate
+
+import (
+	"fmt"
+
+	"go/token"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// GenerationError indicates that a value could not be converted into Go
+// AST, generally because it's an unknown implementation of one of the
+// convert interfaces, or is missing required pieces.
+type GenerationError struct {
+	// Subject is the value that could not be converted.
+	Subject interface{}
+	// Position is the position of the subject, if it's Positioned.
+	Position token.Position
+	Message string
+}
+
+func (e *GenerationError) Error() string {
+	if e.Position.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Position, e.Message)
+	}
+	return e.Message
+}
+
+// generationError constructs a new GenerationError for the given subject.
+// The From* methods panic with the result; the *Checked variants recover it.
+func generationError(subject interface{}, format string, args ...interface{}) *GenerationError {
+	res := &GenerationError{
+		Subject: subject,
+		Message: fmt.Sprintf(format, args...),
+	}
+	if positioned, isPositioned := subject.(convert.Positioned); isPositioned {
+		res.Position = positioned.Position()
+	}
+	return res
+}
+
+// recoverError recovers a GenerationError (or a ConversionError from
+// lazily-converted parsed code) into err, re-panicking on anything else.
+// It must be called directly via defer.
+func recoverError(err *error) {
+	r := recover()
+	switch typed := r.(type) {
+	case nil:
+		return
+	case *GenerationError:
+		*err = typed
+	case *convert.ConversionError:
+		*err = typed
+	default:
+		panic(r)
+	}
+}
@@ -0,0 +1,190 @@
+package generate
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// FromExpression converts any of the convert expression types to a Go
+// expression.  Anything that isn't one of the expression types is assumed to
+// be a TypeDefinition (including Idents and QualifiedIdents).
+func (b *ASTBuilder) FromExpression(e convert.Expression) ast.Expr {
+	switch typed := e.(type) {
+	case nil:
+		return nil
+	case convert.BasicLiteral:
+		return b.FromBasicLiteral(typed)
+	case convert.CompositeLiteral:
+		return b.FromCompositeLiteral(typed)
+	case convert.KeyValueExpression:
+		return b.FromKeyValueExpression(typed)
+	case convert.FuncLiteral:
+		return b.FromFuncLiteral(typed)
+	case convert.CallExpression:
+		return b.FromCallExpression(typed)
+	case convert.ConversionExpression:
+		return b.FromConversionExpression(typed)
+	case convert.SelectorExpression:
+		return b.FromSelectorExpression(typed)
+	case convert.IndexExpression:
+		return b.FromIndexExpression(typed)
+	case convert.SliceExpression:
+		return b.FromSliceExpression(typed)
+	case convert.TypeAssertExpression:
+		return b.FromTypeAssertExpression(typed)
+	case convert.UnaryExpression:
+		return b.FromUnaryExpression(typed)
+	case convert.BinaryExpression:
+		return b.FromBinaryExpression(typed)
+	case convert.ParenExpression:
+		return b.FromParenExpression(typed)
+	default:
+		return b.FromTypeDefinition(typed)
+	}
+}
+
+func (b *ASTBuilder) fromExpressions(exprs []convert.Expression) []ast.Expr {
+	if len(exprs) == 0 {
+		return nil
+	}
+	res := make([]ast.Expr, len(exprs))
+	for i, expr := range exprs {
+		res[i] = b.FromExpression(expr)
+	}
+	return res
+}
+
+func (b *ASTBuilder) FromBasicLiteral(e convert.BasicLiteral) ast.Expr {
+	return &ast.BasicLit{
+		ValuePos: b.nextPos(),
+		Kind: e.Kind(),
+		Value: e.Literal(),
+	}
+}
+
+func (b *ASTBuilder) FromCompositeLiteral(e convert.CompositeLiteral) ast.Expr {
+	res := &ast.CompositeLit{}
+	if typ := e.LiteralType(); typ != nil {
+		res.Type = b.FromTypeDefinition(typ)
+	}
+	res.Lbrace = b.nextPos()
+	res.Elts = b.fromExpressions(e.Elements())
+	res.Rbrace = b.nextPos()
+	return res
+}
+
+func (b *ASTBuilder) FromKeyValueExpression(e convert.KeyValueExpression) ast.Expr {
+	return &ast.KeyValueExpr{
+		Key: b.FromExpression(e.Key()),
+		Value: b.FromExpression(e.Value()),
+	}
+}
+
+func (b *ASTBuilder) FromFuncLiteral(e convert.FuncLiteral) ast.Expr {
+	return &ast.FuncLit{
+		Type: b.FromFuncTypeDefinition(e.Type()),
+		Body: b.FromBlockStatement(e.Body()),
+	}
+}
+
+func (b *ASTBuilder) FromCallExpression(e convert.CallExpression) ast.Expr {
+	res := &ast.CallExpr{
+		Fun: b.FromExpression(e.Func()),
+		Lparen: b.nextPos(),
+		Args: b.fromExpressions(e.Args()),
+	}
+	if e.HasEllipsis() {
+		res.Ellipsis = b.nextPos()
+	}
+	res.Rparen = b.nextPos()
+	return res
+}
+
+func (b *ASTBuilder) FromConversionExpression(e convert.ConversionExpression) ast.Expr {
+	target := b.FromTypeDefinition(e.TargetType())
+	switch target.(type) {
+	case *ast.StarExpr, *ast.FuncType, *ast.ChanType:
+		// these need parens to avoid being parsed as something else
+		// (e.g. `*T(x)` is a dereference of a conversion)
+		target = &ast.ParenExpr{X: target}
+	}
+	return &ast.CallExpr{
+		Fun: target,
+		Lparen: b.nextPos(),
+		Args: []ast.Expr{b.FromExpression(e.Operand())},
+		Rparen: b.nextPos(),
+	}
+}
+
+func (b *ASTBuilder) FromSelectorExpression(e convert.SelectorExpression) ast.Expr {
+	return &ast.SelectorExpr{
+		X: b.FromExpression(e.Operand()),
+		Sel: b.FromIdent(e.Selector()),
+	}
+}
+
+func (b *ASTBuilder) FromIndexExpression(e convert.IndexExpression) ast.Expr {
+	operand := b.FromExpression(e.Operand())
+	indices := b.fromExpressions(e.Indices())
+	if len(indices) == 1 {
+		return &ast.IndexExpr{
+			X: operand,
+			Index: indices[0],
+		}
+	}
+	return &ast.IndexListExpr{
+		X: operand,
+		Indices: indices,
+	}
+}
+
+func (b *ASTBuilder) FromSliceExpression(e convert.SliceExpression) ast.Expr {
+	return &ast.SliceExpr{
+		X: b.FromExpression(e.Operand()),
+		Low: b.FromExpression(e.Low()),
+		High: b.FromExpression(e.High()),
+		Max: b.FromExpression(e.Max()),
+		Slice3: e.IsFullSlice(),
+	}
+}
+
+func (b *ASTBuilder) FromTypeAssertExpression(e convert.TypeAssertExpression) ast.Expr {
+	res := &ast.TypeAssertExpr{
+		X: b.FromExpression(e.Operand()),
+	}
+	// a nil type means `x.(type)`
+	if typ := e.AssertedType(); typ != nil {
+		res.Type = b.FromTypeDefinition(typ)
+	}
+	return res
+}
+
+func (b *ASTBuilder) FromUnaryExpression(e convert.UnaryExpression) ast.Expr {
+	if e.Op() == token.MUL {
+		// dereferences are StarExprs
+		return &ast.StarExpr{
+			X: b.FromExpression(e.Operand()),
+		}
+	}
+	return &ast.UnaryExpr{
+		Op: e.Op(),
+		X: b.FromExpression(e.Operand()),
+	}
+}
+
+func (b *ASTBuilder) FromBinaryExpression(e convert.BinaryExpression) ast.Expr {
+	return &ast.BinaryExpr{
+		X: b.FromExpression(e.Left()),
+		OpPos: b.nextPos(),
+		Op: e.Op(),
+		Y: b.FromExpression(e.Right()),
+	}
+}
+
+func (b *ASTBuilder) FromParenExpression(e convert.ParenExpression) ast.Expr {
+	return &ast.ParenExpr{
+		X: b.FromExpression(e.Inner()),
+	}
+}
@@ -2,10 +2,14 @@ package generate
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"go/ast"
+	"go/constant"
 	"go/token"
+	"go/types"
 
 	"github.com/directxman12/envmap/pkg/convert"
 )
@@ -81,6 +85,10 @@ func (b *ASTBuilder) nextPos() token.Pos {
 func (b *ASTBuilder) newCommentGroup(strs ...string) *ast.CommentGroup {
 	comments := make([]*ast.Comment, len(strs))
 	for i, rawComment := range strs {
+		// each comment goes on its own line, so that docs
+		// never end up trailing the previous node
+		b.line()
+
 		if strings.Contains(rawComment, "\n") {
 			// multiline
 			rawComment = "/*"+rawComment+"*/"
@@ -95,6 +103,10 @@ func (b *ASTBuilder) newCommentGroup(strs ...string) *ast.CommentGroup {
 			Slash: b.nextPos(),
 		}
 	}
+	if len(comments) > 0 {
+		// ...and so does whatever it documents
+		b.line()
+	}
 	return &ast.CommentGroup{
 		List: comments,
 	}
@@ -107,8 +119,42 @@ func (b *ASTBuilder) maybeCommentGroup(obj interface{}) *ast.CommentGroup {
 	if !hasDocs {
 		return nil
 	}
+	docs := asDoced.Doc()
+	if len(docs) == 0 {
+		return nil
+	}
+
+	return b.newCommentGroup(docs...)
+}
 
-	return b.newCommentGroup(asDoced.Doc()...)
+// maybeLineComment checks if the given object implements LineCommented, and
+// extracts its trailing comment into a comment group if it does.  Since
+// it's positioned at the current position, it must be called after
+// generating the rest of the node that it trails.
+func (b *ASTBuilder) maybeLineComment(obj interface{}) *ast.CommentGroup {
+	asCommented, hasComment := obj.(convert.LineCommented)
+	if !hasComment {
+		return nil
+	}
+	lines := asCommented.LineComment()
+	if len(lines) == 0 {
+		return nil
+	}
+
+	comments := make([]*ast.Comment, len(lines))
+	for i, line := range lines {
+		if i > 0 {
+			// continue onto the following lines
+			b.line()
+		}
+		comments[i] = &ast.Comment{
+			Text: "// "+line,
+			Slash: b.nextPos(),
+		}
+	}
+	return &ast.CommentGroup{
+		List: comments,
+	}
 }
 
 // The b.FromXXX methods convert *any* implementation of one of the
@@ -123,6 +169,22 @@ func (b *ASTBuilder) line() {
 }
 
 func (b *ASTBuilder) FromAST(a convert.AST) *ast.File {
+	return b.fromAST(a, nil)
+}
+
+// FromASTChecked is like FromAST, but skips any declarations that can't be
+// converted, returning errors for them instead of panicking.
+func (b *ASTBuilder) FromASTChecked(a convert.AST) (*ast.File, []error) {
+	var errs []error
+	res := b.fromAST(a, func(err error) {
+		errs = append(errs, err)
+	})
+	return res, errs
+}
+
+// fromAST converts an AST, passing declaration errors to onError.
+// If onError is nil, errors will cause a panic instead.
+func (b *ASTBuilder) fromAST(a convert.AST, onError func(error)) *ast.File {
 	// reset lines before generating any positions
 	b.lines = nil
 
@@ -134,14 +196,39 @@ func (b *ASTBuilder) FromAST(a convert.AST) *ast.File {
 
 	res := &ast.File{
 		Doc: b.maybeCommentGroup(a),
+		// the package clause needs a position after the docs
+		Package: b.nextPos(),
 		Name: b.FromIdent(a.PackageName()),
-		Imports: make([]*ast.ImportSpec, len(sortedImports)),
-		Decls: make([]ast.Decl, 0, len(typeDecls)+len(valDecls)+len(funcDecls)),
+		Decls: make([]ast.Decl, 0, len(typeDecls)+len(valDecls)+len(funcDecls)+1),
 	}
 
-	for i, imp := range sortedImports {
-		res.Imports[i] = b.FromImport(imp)
+	// imports must be declared as well as listed in the file
+	if len(sortedImports) > 0 {
+		importDecl := &ast.GenDecl{
+			TokPos: b.nextPos(),
+			Tok: token.IMPORT,
+		}
+		importDecl.Lparen = b.nextPos()
+		for _, imp := range sortedImports {
+			spec := b.FromImport(imp)
+			res.Imports = append(res.Imports, spec)
+			importDecl.Specs = append(importDecl.Specs, spec)
+		}
+		importDecl.Rparen = b.nextPos()
+		res.Decls = append(res.Decls, importDecl)
+	}
+
+	// grouped declarations are emitted together, in place of the first one
+	groups := a.Groups()
+	groupOf := make(map[convert.Declaration]int)
+	for i, group := range groups {
+		for _, member := range group.Declarations() {
+			if isComparable(member) {
+				groupOf[member] = i
+			}
+		}
 	}
+	emittedGroups := make([]bool, len(groups))
 
 	for _, decl := range sortedDecls {
 		if decl == nil {
@@ -149,14 +236,29 @@ func (b *ASTBuilder) FromAST(a convert.AST) *ast.File {
 			continue
 		}
 
-		switch typedDecl := decl.(type) {
-		case convert.ValueDeclaration:
-			res.Decls = append(res.Decls, b.FromValueDeclaration(typedDecl))
-		case convert.FuncDeclaration:
-			res.Decls = append(res.Decls, b.FromFuncDeclaration(typedDecl))
-		case convert.TypeDeclaration:
-			res.Decls = append(res.Decls, b.FromTypeDeclaration(typedDecl))
+		if isComparable(decl) {
+			if groupInd, inGroup := groupOf[decl]; inGroup {
+				if emittedGroups[groupInd] {
+					continue
+				}
+				emittedGroups[groupInd] = true
+				decl = groups[groupInd]
+			}
 		}
+		if isTupleTail(decl) {
+			continue
+		}
+
+		if onError == nil {
+			res.Decls = append(res.Decls, b.FromDeclaration(decl))
+			continue
+		}
+		genDecl, err := b.FromDeclarationChecked(decl)
+		if err != nil {
+			onError(err)
+			continue
+		}
+		res.Decls = append(res.Decls, genDecl)
 	}
 
 
@@ -170,15 +272,87 @@ func (b *ASTBuilder) FromAST(a convert.AST) *ast.File {
 	return res
 }
 
+// isComparable checks if the given declaration can be used as a map key.
+func isComparable(decl convert.Declaration) bool {
+	return reflect.TypeOf(decl).Comparable()
+}
+
+// isTupleTail checks if the given declaration is a variable in a tuple
+// (like `b` in `var a, b = f()`) other than the first, which are
+// emitted along with the first one.
+func isTupleTail(decl convert.Declaration) bool {
+	valDecl, isVal := decl.(convert.ValueDeclaration)
+	if !isVal {
+		return false
+	}
+	tuple := valDecl.Tuple()
+	return len(tuple) > 0 && isComparable(decl) && tuple[0] != valDecl
+}
+
+// setExprPos sets the starting position of simple expressions
+// (identifiers and pointers to them), which otherwise have none.
+func setExprPos(expr ast.Expr, pos token.Pos) {
+	switch typed := expr.(type) {
+	case *ast.Ident:
+		typed.NamePos = pos
+	case *ast.StarExpr:
+		typed.Star = pos
+	case *ast.SelectorExpr:
+		setExprPos(typed.X, pos)
+	}
+}
+
+// FromDeclaration converts any of the top-level declaration types,
+// or a DeclGroup of them.
+func (b *ASTBuilder) FromDeclaration(d convert.Declaration) ast.Decl {
+	switch typed := d.(type) {
+	case convert.DeclGroup:
+		return b.FromDeclGroup(typed)
+	case convert.ValueDeclaration:
+		return b.FromValueDeclaration(typed)
+	case convert.FuncDeclaration:
+		return b.FromFuncDeclaration(typed)
+	case convert.TypeDeclaration:
+		return b.FromTypeDeclaration(typed)
+	default:
+		panic(generationError(d, "unknown/invalid declaration type %T", d))
+	}
+}
+
+// FromDeclarationChecked is like FromDeclaration, but returns an error
+// instead of panicking if the declaration can't be converted.
+func (b *ASTBuilder) FromDeclarationChecked(d convert.Declaration) (res ast.Decl, err error) {
+	defer func() {
+		// errors from unpositioned parts of a declaration
+		// fall back to the declaration's position
+		genErr, isGenErr := err.(*GenerationError)
+		if !isGenErr || genErr.Position.IsValid() {
+			return
+		}
+		if positioned, isPositioned := d.(convert.Positioned); isPositioned {
+			genErr.Position = positioned.Position()
+		}
+	}()
+	defer recoverError(&err)
+	return b.FromDeclaration(d), nil
+}
+
 func (b *ASTBuilder) FromImport(i convert.Import) *ast.ImportSpec {
-	return &ast.ImportSpec{
+	res := &ast.ImportSpec{
 		Doc: b.maybeCommentGroup(i),
 		Name: b.FromIdent(i.Name()),
-		Path: &ast.BasicLit{
-			Kind: token.STRING,
-			Value: i.Path(),
-		},
 	}
+	// the name and path need positions after the docs
+	if res.Name != nil {
+		res.Name.NamePos = b.nextPos()
+	}
+	res.Path = &ast.BasicLit{
+		ValuePos: b.nextPos(),
+		Kind: token.STRING,
+		Value: i.Path(),
+	}
+	res.Comment = b.maybeLineComment(i)
+	return res
 }
 
 func (b *ASTBuilder) FromValueDeclaration(d convert.ValueDeclaration) ast.Decl {
@@ -186,21 +360,109 @@ func (b *ASTBuilder) FromValueDeclaration(d convert.ValueDeclaration) ast.Decl {
 	if d.IsConst() {
 		tok = token.CONST
 	}
+	return &ast.GenDecl{
+		Doc: b.maybeCommentGroup(d),
+		// always put token later, so that we get docs before the keyword
+		TokPos: b.nextPos(),
+		Tok: tok,
+		Specs: []ast.Spec{b.fromValueSpec(d, 0)},
+	}
+}
+
+// fromValueSpec converts a value declaration to a spec at the given
+// position (iota) in its declaration.  Variables in a tuple are
+// converted together, into a single spec.
+func (b *ASTBuilder) fromValueSpec(d convert.ValueDeclaration, iota int) *ast.ValueSpec {
 	var vals []ast.Expr
 	if d.Value() != nil {
-		vals = []ast.Expr{d.Value()}
+		vals = []ast.Expr{b.fromValueExpr(d, iota)}
 	}
 	spec := &ast.ValueSpec{
 		Names: []*ast.Ident{b.FromIdent(d.Name())},
-		Type: b.FromTypeDefinition(d.Type()),
 		Values: vals,
 	}
-	return &ast.GenDecl{
-		Doc: b.maybeCommentGroup(d),
-		// always put token later, so that we get docs before the keyword
-		TokPos: b.nextPos(),
-		Tok: tok,
-		Specs: []ast.Spec{spec},
+	if tuple := d.Tuple(); len(tuple) > 0 {
+		// all the variables share the (multi-valued) value
+		spec.Names = make([]*ast.Ident, len(tuple))
+		for i, member := range tuple {
+			spec.Names[i] = b.FromIdent(member.Name())
+		}
+	}
+	if typ := d.Type(); typ != nil {
+		spec.Type = b.FromTypeDefinition(typ)
+	}
+	spec.Comment = b.maybeLineComment(d)
+	return spec
+}
+
+// fromValueExpr returns the value expression for a value declaration
+// at the given position (iota) in its declaration.  Constants whose value
+// depends on their original position (via iota) are emitted as their
+// evaluated value if that position differs.
+func (b *ASTBuilder) fromValueExpr(d convert.ValueDeclaration, iota int) ast.Expr {
+	val := d.Value()
+	if !d.IsConst() || d.Iota() == iota || !usesIota(val) {
+		return b.FromExpression(val)
+	}
+
+	if res := constValueToExpr(d.ConstValue()); res != nil {
+		return res
+	}
+	panic(generationError(d, "unable to evaluate iota-based value for constant %q", d.Name().Name()))
+}
+
+// usesIota checks if the given constant expression references iota.
+func usesIota(expr convert.Expression) bool {
+	switch typed := expr.(type) {
+	case convert.ParenExpression:
+		return usesIota(typed.Inner())
+	case convert.UnaryExpression:
+		return usesIota(typed.Operand())
+	case convert.BinaryExpression:
+		return usesIota(typed.Left()) || usesIota(typed.Right())
+	case convert.ConversionExpression:
+		return usesIota(typed.Operand())
+	case convert.CallExpression:
+		for _, arg := range typed.Args() {
+			if usesIota(arg) {
+				return true
+			}
+		}
+		return false
+	case convert.QualifiedIdent:
+		return false
+	case convert.Ident:
+		return typed.Name() == "iota"
+	default:
+		return false
+	}
+}
+
+// constValueToExpr converts a constant value into a literal expression,
+// returning nil for nil values and kinds that can't be represented as literals.
+func constValueToExpr(val constant.Value) ast.Expr {
+	if val == nil {
+		return nil
+	}
+	switch val.Kind() {
+	case constant.Bool:
+		return &ast.Ident{Name: val.ExactString()}
+	case constant.String:
+		return &ast.BasicLit{Kind: token.STRING, Value: val.ExactString()}
+	case constant.Int, constant.Float:
+		if constant.Sign(val) < 0 {
+			return &ast.UnaryExpr{
+				Op: token.SUB,
+				X: constValueToExpr(constant.UnaryOp(token.SUB, val, 0)),
+			}
+		}
+		if val.Kind() == constant.Int {
+			return &ast.BasicLit{Kind: token.INT, Value: val.ExactString()}
+		}
+		floatVal, _ := constant.Float64Val(val)
+		return &ast.BasicLit{Kind: token.FLOAT, Value: strconv.FormatFloat(floatVal, 'g', -1, 64)}
+	default:
+		return nil
 	}
 }
 
@@ -225,7 +487,7 @@ func (b *ASTBuilder) FromFuncDeclaration(d convert.FuncDeclaration) ast.Decl {
 		Name: b.FromIdent(d.Name()),
 		Type: b.FromFuncTypeDefinition(d.Type()),
 		Recv: receiver,
-		Body: d.Body(),
+		Body: b.FromBlockStatement(d.Body()),
 	}
 	return res
 }
@@ -235,12 +497,14 @@ func (b *ASTBuilder) FromTypeDeclaration(d convert.TypeDeclaration) ast.Decl {
 	typeTokPos := b.nextPos()
 	spec := &ast.TypeSpec{
 		Name: b.FromIdent(d.Name()),
+		TypeParams: b.newTypeParamList(d.TypeParams()),
 		Type: b.FromTypeDefinition(d.Type()),
 	}
 	if d.IsAlias() {
 		// any position will do
 		spec.Assign = b.nextPos()
 	}
+	spec.Comment = b.maybeLineComment(d)
 	res := &ast.GenDecl{
 		Tok: token.TYPE,
 		// always put token later, so that we get docs before the keyword
@@ -251,6 +515,109 @@ func (b *ASTBuilder) FromTypeDeclaration(d convert.TypeDeclaration) ast.Decl {
 	return res
 }
 
+// FromDeclGroup converts a group of declarations into a single
+// parenthesized declaration.  Consecutive constants with the same iota
+// share a spec, and specs which repeat the type and values of the
+// preceding spec have them omitted, as in iota-based enums.
+func (b *ASTBuilder) FromDeclGroup(g convert.DeclGroup) ast.Decl {
+	res := &ast.GenDecl{
+		Doc: b.maybeCommentGroup(g),
+		TokPos: b.nextPos(),
+		Tok: g.Kind(),
+	}
+	res.Lparen = b.nextPos()
+
+	// specIota is the position of the next value spec, which
+	// differs from that of the declarations when they share specs
+	var lastType, lastValues string
+	specIota := 0
+	decls := g.Declarations()
+	for i := 0; i < len(decls); i++ {
+		switch typedDecl := decls[i].(type) {
+		case convert.TypeDeclaration:
+			if res.Tok != token.TYPE {
+				panic(generationError(g, "type %q in %s group", typedDecl.Name().Name(), res.Tok))
+			}
+			genDecl := b.FromTypeDeclaration(typedDecl).(*ast.GenDecl)
+			spec := genDecl.Specs[0].(*ast.TypeSpec)
+			// the spec needs a position after its docs
+			spec.Doc, spec.Name.NamePos = genDecl.Doc, genDecl.TokPos
+			res.Specs = append(res.Specs, spec)
+		case convert.ValueDeclaration:
+			if (res.Tok == token.CONST) != typedDecl.IsConst() || res.Tok == token.TYPE {
+				panic(generationError(g, "value %q in %s group", typedDecl.Name().Name(), res.Tok))
+			}
+			if isTupleTail(typedDecl) {
+				continue
+			}
+			doc := b.maybeCommentGroup(typedDecl)
+			namePos := b.nextPos()
+			spec := b.fromValueSpec(typedDecl, specIota)
+			// the spec needs a position after its docs
+			spec.Doc, spec.Names[0].NamePos = doc, namePos
+
+			// constants from the same spec (like `a, b = iota, iota*10`)
+			// share their iota, and are put back into a single spec
+			for i+1 < len(decls) {
+				next, isValue := decls[i+1].(convert.ValueDeclaration)
+				if !isValue || !sameConstSpec(typedDecl, next) {
+					break
+				}
+				spec.Names = append(spec.Names, b.FromIdent(next.Name()))
+				if len(spec.Values) > 0 {
+					spec.Values = append(spec.Values, b.fromValueExpr(next, specIota))
+				}
+				i++
+			}
+
+			if res.Tok == token.CONST && len(spec.Values) > 0 {
+				typ, values := "", exprListString(spec.Values)
+				if spec.Type != nil {
+					typ = types.ExprString(spec.Type)
+				}
+				if specIota > 0 && typ == lastType && values == lastValues {
+					// implicitly repeat the previous type and values
+					spec.Type, spec.Values = nil, nil
+				}
+				lastType, lastValues = typ, values
+			}
+			res.Specs = append(res.Specs, spec)
+			specIota++
+		default:
+			panic(generationError(g, "invalid grouped declaration type %T", decls[i]))
+		}
+	}
+
+	res.Rparen = b.nextPos()
+	return res
+}
+
+// sameConstSpec checks if next could have been declared in the same spec
+// as first: both are constants with the same iota and type, and both
+// have values (or neither does).
+func sameConstSpec(first, next convert.ValueDeclaration) bool {
+	if !first.IsConst() || !next.IsConst() || next.Iota() < 0 || next.Iota() != first.Iota() {
+		return false
+	}
+	if (first.Value() == nil) != (next.Value() == nil) {
+		return false
+	}
+	firstType, nextType := first.Type(), next.Type()
+	if firstType == nil || nextType == nil {
+		return firstType == nil && nextType == nil
+	}
+	return convert.Identical(firstType, nextType)
+}
+
+// exprListString formats a list of expressions as Go source.
+func exprListString(exprs []ast.Expr) string {
+	strs := make([]string, len(exprs))
+	for i, expr := range exprs {
+		strs[i] = types.ExprString(expr)
+	}
+	return strings.Join(strs, ", ")
+}
+
 func (b *ASTBuilder) FromTypeDefinition(d convert.TypeDefinition) ast.Expr {
 	switch typed := d.(type) {
 	case convert.StructTypeDefinition:
@@ -269,17 +636,29 @@ func (b *ASTBuilder) FromTypeDefinition(d convert.TypeDefinition) ast.Expr {
 		return b.FromSplatTypeDefinition(typed)
 	case convert.ArrayTypeDefinition:
 		return b.FromArrayTypeDefinition(typed)
+	case convert.InstantiatedTypeDefinition:
+		return b.FromInstantiatedTypeDefinition(typed)
+	case convert.UnionTypeDefinition:
+		return b.FromUnionTypeDefinition(typed)
+	case convert.TildeTypeDefinition:
+		return b.FromTildeTypeDefinition(typed)
 	case convert.QualifiedIdent:
 		return b.FromQualifiedIdent(typed)
 	case convert.Ident:
 		// NB: this *must* be after qualified ident, for similar reasons to array/splat
 		return b.FromIdent(typed)
 	default:
-		// TODO: return error instead of panic
-		panic(fmt.Sprintf("unknown/invalid expression type %T", d))
+		panic(generationError(d, "unknown/invalid type definition type %T", d))
 	}
 }
 
+// FromTypeDefinitionChecked is like FromTypeDefinition, but returns an
+// error instead of panicking if the type definition can't be converted.
+func (b *ASTBuilder) FromTypeDefinitionChecked(d convert.TypeDefinition) (res ast.Expr, err error) {
+	defer recoverError(&err)
+	return b.FromTypeDefinition(d), nil
+}
+
 func (b *ASTBuilder) newFieldList(fields []convert.Field) *ast.FieldList {
 	rawFields := make([]*ast.Field, len(fields))
 	for i, field := range fields {
@@ -290,20 +669,43 @@ func (b *ASTBuilder) newFieldList(fields []convert.Field) *ast.FieldList {
 	}
 }
 
+// newTypeParamList is like newFieldList, except that it returns nil
+// for an empty list, since an empty type parameter list is printed
+// as `[]`.
+func (b *ASTBuilder) newTypeParamList(params []convert.Field) *ast.FieldList {
+	if len(params) == 0 {
+		return nil
+	}
+	return b.newFieldList(params)
+}
+
 func (b *ASTBuilder) FromField(f convert.Field) *ast.Field {
+	doc := b.maybeCommentGroup(f)
+	fieldPos := b.nextPos()
 	res := &ast.Field{
-		Doc: b.maybeCommentGroup(f),
+		Doc: doc,
 		Type: b.FromTypeDefinition(f.Type()),
 	}
-	name := f.Name()
-	if name != nil {
-		res.Names = []*ast.Ident{b.FromIdent(f.Name())}
+	// embedded fields are just their type
+	if name := f.Name(); name != nil && !f.IsEmbedded() {
+		res.Names = []*ast.Ident{b.FromIdent(name)}
 	}
-	tag := f.Tag()
-	if tag != "" {
+	if tag := f.Tag(); tag != "" {
 		res.Tag = &ast.BasicLit{
 			Kind: token.STRING,
-			Value: string(f.Tag()),
+			Value: convert.QuoteStructTag(tag),
+		}
+	}
+	res.Comment = b.maybeLineComment(f)
+
+	// commented fields need a position after their docs, and on the
+	// same line as their trailing comment.  Otherwise, leave it unset,
+	// since positioned params let the printer collapse func bodies.
+	if doc != nil || res.Comment != nil {
+		if res.Names != nil {
+			res.Names[0].NamePos = fieldPos
+		} else {
+			setExprPos(res.Type, fieldPos)
 		}
 	}
 	return res
@@ -318,13 +720,14 @@ func (b *ASTBuilder) FromStructTypeDefinition(d convert.StructTypeDefinition) as
 
 func (b *ASTBuilder) FromInterfaceTypeDefinition(d convert.InterfaceTypeDefinition) ast.Expr {
 	return &ast.InterfaceType{
-		Methods: b.newFieldList(d.Methods()),
+		Methods: b.newFieldList(d.Elements()),
 	}
 
 }
 
 func (b *ASTBuilder) FromFuncTypeDefinition(d convert.FuncTypeDefinition) *ast.FuncType {
 	return &ast.FuncType{
+		TypeParams: b.newTypeParamList(d.TypeParams()),
 		Params: b.newFieldList(d.Params()),
 		Results: b.newFieldList(d.Results()),
 	}
@@ -341,24 +744,34 @@ func (b *ASTBuilder) FromArrayTypeDefinition(d convert.ArrayTypeDefinition) ast.
 	res := &ast.ArrayType{
 		Elt: b.FromTypeDefinition(d.ElemType()),
 	}
-	maybeLen := d.Length()
-	if maybeLen == nil {
+	length := d.Length()
+	if length == nil {
 		// it's just a slice
 		return res
 	}
 
-	if *maybeLen == convert.AutoLength {
+	res.Len = b.FromArrayLength(length)
+	return res
+}
+
+// FromArrayLength converts an array length to an expression.  The original
+// length expression is reproduced if present, falling back to the evaluated
+// length as an integer literal otherwise.
+func (b *ASTBuilder) FromArrayLength(l convert.ArrayLength) ast.Expr {
+	if l == convert.AutoLength {
 		// auto length is `[...]T`
-		res.Len = &ast.Ellipsis{}
-		return res
+		return &ast.Ellipsis{}
 	}
 
-	res.Len = &ast.BasicLit{
-		Kind: token.INT,
-		Value: fmt.Sprintf("%d", *maybeLen),
+	if expr := l.Expr(); expr != nil {
+		return b.FromExpression(expr)
 	}
 
-	return res
+	val := l.Value()
+	if val == nil || val.Kind() != constant.Int {
+		panic(generationError(l, "array length has neither an expression nor an integer value"))
+	}
+	return constValueToExpr(val)
 }
 
 func (b *ASTBuilder) FromChanTypeDefinition(d convert.ChanTypeDefinition) ast.Expr {
@@ -387,6 +800,51 @@ func (b *ASTBuilder) FromSplatTypeDefinition(d convert.SplatTypeDefinition) ast.
 	}
 }
 
+func (b *ASTBuilder) FromInstantiatedTypeDefinition(d convert.InstantiatedTypeDefinition) ast.Expr {
+	generic := b.FromTypeDefinition(d.GenericType())
+	rawArgs := d.TypeArgs()
+	args := make([]ast.Expr, len(rawArgs))
+	for i, arg := range rawArgs {
+		args[i] = b.FromTypeDefinition(arg)
+	}
+
+	if len(args) == 1 {
+		return &ast.IndexExpr{
+			X: generic,
+			Index: args[0],
+		}
+	}
+	return &ast.IndexListExpr{
+		X: generic,
+		Indices: args,
+	}
+}
+
+func (b *ASTBuilder) FromUnionTypeDefinition(d convert.UnionTypeDefinition) ast.Expr {
+	terms := d.Terms()
+	if len(terms) == 0 {
+		panic(generationError(d, "union type definitions must have at least one term"))
+	}
+
+	// unions are left-associative, so build up from the left
+	res := b.FromTypeDefinition(terms[0])
+	for _, term := range terms[1:] {
+		res = &ast.BinaryExpr{
+			X: res,
+			Op: token.OR,
+			Y: b.FromTypeDefinition(term),
+		}
+	}
+	return res
+}
+
+func (b *ASTBuilder) FromTildeTypeDefinition(d convert.TildeTypeDefinition) ast.Expr {
+	return &ast.UnaryExpr{
+		Op: token.TILDE,
+		X: b.FromTypeDefinition(d.Approximates()),
+	}
+}
+
 func (b *ASTBuilder) FromIdent(i convert.Ident) *ast.Ident {
 	if i == nil {
 		return nil
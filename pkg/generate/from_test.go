@@ -0,0 +1,151 @@
+package generate_test
+
+import (
+	"bytes"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/directxman12/envmap/pkg/convert"
+	"github.com/directxman12/envmap/pkg/generate"
+	"github.com/directxman12/envmap/pkg/generate/builder"
+)
+
+const constGroupSrc = `package x
+
+const (
+	a, b = iota, iota * 10
+	c, d
+	e    = 100
+	f
+)
+
+const (
+	M1 Mode = iota
+	M2
+	M3, M4 Mode = 5, 6
+)
+`
+
+// generateSource generates the given AST and formats it.
+func generateSource(t *testing.T, a convert.AST) string {
+	t.Helper()
+	b := generate.NewASTBuilder()
+	out := b.FromAST(a)
+	var buf bytes.Buffer
+	if err := format.Node(&buf, b.FileSet(), out); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestConstGroupRoundTrip(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", constGroupSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := convert.FromRawWithFileSet(fset, file)
+
+	expectedGroups := []string{
+		"const (\n\ta, b = iota, iota * 10\n\tc, d\n\te = 100\n\tf\n)",
+		"const (\n\tM1 Mode = iota\n\tM2\n\tM3, M4 Mode = 5, 6\n)",
+	}
+	cases := map[string]convert.AST{
+		"parsed": parsed,
+		"copied": builder.FromConvert(parsed).(convert.AST),
+	}
+	for name, a := range cases {
+		t.Run(name, func(t *testing.T) {
+			generated := generateSource(t, a)
+			for _, group := range expectedGroups {
+				if !strings.Contains(generated, group) {
+					t.Errorf("expected generated source to contain\n%s\n\ngot\n%s", group, generated)
+				}
+			}
+		})
+	}
+}
+
+func TestBuiltTagRoundTrip(t *testing.T) {
+	tag, err := convert.ParseStructTag(`json:"name"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag = tag.AddOption("json", "omitempty").Set("doc", "say \"hi\" to `x`")
+	pkg := builder.Package("x").Declare(builder.Type("T", builder.Struct().
+		Field("Name", convert.NewIdent("string"), "").WithFieldTag(tag).
+		Field("Plain", convert.NewIdent("int"), `yaml:"plain"`)))
+
+	generated := generateSource(t, pkg)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", generated, 0)
+	if err != nil {
+		t.Fatalf("expected valid Go, got %v in\n%s", err, generated)
+	}
+	fields := convert.FromRawWithFileSet(fset, file).Types()[0].Type().(convert.StructTypeDefinition).Fields()
+	if actual := fields[0].Tag(); actual != tag.StructTag() {
+		t.Errorf("expected tag %s, got %s", tag.StructTag(), actual)
+	}
+	if actual := fields[0].Tag().Get("doc"); actual != "say \"hi\" to `x`" {
+		t.Errorf("expected the doc value to survive quoting, got %q", actual)
+	}
+	// raw strings are used unless the tag contains a backquote
+	if !strings.Contains(generated, "`yaml:\"plain\"`") || !strings.Contains(generated, `"json:\"name,omitempty\"`) {
+		t.Errorf("expected a backquoted tag only for Plain, got\n%s", generated)
+	}
+}
+
+const interfaceOrderSrc = `package x
+
+type Thing interface {
+	// Foo does foo.
+	Foo() int
+	// Stringer is embedded.
+	fmt.Stringer
+	Bar()
+	~int | ~string
+}
+`
+
+func TestInterfaceElementOrder(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", interfaceOrderSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := convert.FromRawWithFileSet(fset, file)
+
+	expected := "type Thing interface {\n\t// Foo does foo.\n\tFoo() int\n\t// Stringer is embedded.\n\tfmt.Stringer\n\tBar()\n\t~int | ~string\n}"
+	cases := map[string]convert.AST{
+		"parsed": parsed,
+		"copied": builder.FromConvert(parsed).(convert.AST),
+	}
+	for name, a := range cases {
+		t.Run(name, func(t *testing.T) {
+			if generated := generateSource(t, a); !strings.Contains(generated, expected) {
+				t.Errorf("expected generated source to contain\n%s\n\ngot\n%s", expected, generated)
+			}
+			expectedString := "interface{Foo() int; fmt.Stringer; Bar(); ~int | ~string}"
+			if actual := convert.TypeString(a.Types()[0].Type(), nil); actual != expectedString {
+				t.Errorf("expected %s, got %s", expectedString, actual)
+			}
+		})
+	}
+
+	t.Run("built", func(t *testing.T) {
+		iface := builder.Interface().
+			Method("Foo", builder.Function().Return("", convert.NewIdent("int"))).
+			Embed(convert.NewQualifiedIdent("fmt", convert.NewIdent("Stringer"))).
+			Method("Bar", builder.Function())
+		expectedString := "interface{Foo() int; fmt.Stringer; Bar()}"
+		if actual := convert.TypeString(iface, nil); actual != expectedString {
+			t.Errorf("expected %s, got %s", expectedString, actual)
+		}
+		if len(iface.Methods()) != 2 || len(iface.Embeds()) != 1 {
+			t.Errorf("expected 2 methods and 1 embed, got %d and %d", len(iface.Methods()), len(iface.Embeds()))
+		}
+	})
+}
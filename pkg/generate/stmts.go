@@ -0,0 +1,280 @@
+package generate
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// FromStatement converts any of the convert statement types to a Go statement.
+func (b *ASTBuilder) FromStatement(s convert.Statement) ast.Stmt {
+	switch typed := s.(type) {
+	case nil:
+		return nil
+	case convert.BlockStatement:
+		return b.FromBlockStatement(typed)
+	case convert.ExpressionStatement:
+		return &ast.ExprStmt{X: b.FromExpression(typed.Expression())}
+	case convert.AssignStatement:
+		return b.FromAssignStatement(typed)
+	case convert.IncDecStatement:
+		return b.FromIncDecStatement(typed)
+	case convert.SendStatement:
+		return b.FromSendStatement(typed)
+	case convert.DeclStatement:
+		return b.FromDeclStatement(typed)
+	case convert.IfStatement:
+		return b.FromIfStatement(typed)
+	case convert.ForStatement:
+		return b.FromForStatement(typed)
+	case convert.RangeStatement:
+		return b.FromRangeStatement(typed)
+	case convert.SwitchStatement:
+		return b.FromSwitchStatement(typed)
+	case convert.TypeSwitchStatement:
+		return b.FromTypeSwitchStatement(typed)
+	case convert.SelectStatement:
+		return b.FromSelectStatement(typed)
+	case convert.ReturnStatement:
+		return b.FromReturnStatement(typed)
+	case convert.DeferStatement:
+		return &ast.DeferStmt{Call: b.fromCall(typed.Call())}
+	case convert.GoStatement:
+		return &ast.GoStmt{Call: b.fromCall(typed.Call())}
+	case convert.BranchStatement:
+		return b.FromBranchStatement(typed)
+	case convert.LabeledStatement:
+		return b.FromLabeledStatement(typed)
+	default:
+		panic(generationError(s, "unknown/invalid statement type %T", s))
+	}
+}
+
+func (b *ASTBuilder) fromStatements(stmts []convert.Statement) []ast.Stmt {
+	res := make([]ast.Stmt, len(stmts))
+	for i, stmt := range stmts {
+		res[i] = b.FromStatement(stmt)
+	}
+	return res
+}
+
+func (b *ASTBuilder) fromCall(e convert.CallExpression) *ast.CallExpr {
+	return b.FromCallExpression(e).(*ast.CallExpr)
+}
+
+// FromBlockStatement converts a block statement, returning nil for nil blocks.
+func (b *ASTBuilder) FromBlockStatement(s convert.BlockStatement) *ast.BlockStmt {
+	if s == nil {
+		return nil
+	}
+	res := &ast.BlockStmt{
+		Lbrace: b.nextPos(),
+		List: b.fromStatements(s.Statements()),
+	}
+	res.Rbrace = b.nextPos()
+	return res
+}
+
+func (b *ASTBuilder) FromAssignStatement(s convert.AssignStatement) ast.Stmt {
+	return &ast.AssignStmt{
+		Lhs: b.fromExpressions(s.Left()),
+		TokPos: b.nextPos(),
+		Tok: s.Op(),
+		Rhs: b.fromExpressions(s.Right()),
+	}
+}
+
+func (b *ASTBuilder) FromIncDecStatement(s convert.IncDecStatement) ast.Stmt {
+	tok := token.DEC
+	if s.IsIncrement() {
+		tok = token.INC
+	}
+	return &ast.IncDecStmt{
+		X: b.FromExpression(s.Target()),
+		Tok: tok,
+	}
+}
+
+func (b *ASTBuilder) FromSendStatement(s convert.SendStatement) ast.Stmt {
+	return &ast.SendStmt{
+		Chan: b.FromExpression(s.Channel()),
+		Value: b.FromExpression(s.SentValue()),
+	}
+}
+
+// FromDeclStatement converts a local declaration, combining
+// multiple declarations into a single parenthesized one.
+func (b *ASTBuilder) FromDeclStatement(s convert.DeclStatement) ast.Stmt {
+	var res *ast.GenDecl
+	for _, decl := range s.Declarations() {
+		if isTupleTail(decl) {
+			continue
+		}
+		var genDecl *ast.GenDecl
+		switch typedDecl := decl.(type) {
+		case convert.ValueDeclaration:
+			genDecl = b.FromValueDeclaration(typedDecl).(*ast.GenDecl)
+		case convert.TypeDeclaration:
+			genDecl = b.FromTypeDeclaration(typedDecl).(*ast.GenDecl)
+		default:
+			panic(generationError(decl, "invalid local declaration type %T", decl))
+		}
+
+		if res == nil {
+			res = genDecl
+			continue
+		}
+		if genDecl.Tok != res.Tok {
+			panic(generationError(s, "local declarations must all be of the same kind"))
+		}
+		res.Lparen = res.TokPos
+		res.Specs = append(res.Specs, genDecl.Specs...)
+	}
+	if res == nil {
+		panic(generationError(s, "local declarations must contain at least one declaration"))
+	}
+	if res.Lparen.IsValid() {
+		res.Rparen = b.nextPos()
+	}
+	return &ast.DeclStmt{Decl: res}
+}
+
+func (b *ASTBuilder) FromIfStatement(s convert.IfStatement) ast.Stmt {
+	return &ast.IfStmt{
+		If: b.nextPos(),
+		Init: b.FromStatement(s.Init()),
+		Cond: b.FromExpression(s.Cond()),
+		Body: b.FromBlockStatement(s.Body()),
+		Else: b.FromStatement(s.ElseBranch()),
+	}
+}
+
+func (b *ASTBuilder) FromForStatement(s convert.ForStatement) ast.Stmt {
+	return &ast.ForStmt{
+		For: b.nextPos(),
+		Init: b.FromStatement(s.Init()),
+		Cond: b.FromExpression(s.Cond()),
+		Post: b.FromStatement(s.Post()),
+		Body: b.FromBlockStatement(s.Body()),
+	}
+}
+
+func (b *ASTBuilder) FromRangeStatement(s convert.RangeStatement) ast.Stmt {
+	res := &ast.RangeStmt{
+		For: b.nextPos(),
+		Key: b.FromExpression(s.Key()),
+		Value: b.FromExpression(s.Value()),
+		Tok: token.ASSIGN,
+		X: b.FromExpression(s.Range()),
+		Body: b.FromBlockStatement(s.Body()),
+	}
+	if s.IsDefine() {
+		res.Tok = token.DEFINE
+	}
+	return res
+}
+
+func (b *ASTBuilder) FromSwitchStatement(s convert.SwitchStatement) ast.Stmt {
+	res := &ast.SwitchStmt{
+		Switch: b.nextPos(),
+		Init: b.FromStatement(s.Init()),
+		Tag: b.FromExpression(s.Tag()),
+		Body: &ast.BlockStmt{Lbrace: b.nextPos()},
+	}
+	for _, clause := range s.Clauses() {
+		var values []ast.Expr
+		if !clause.IsDefault() {
+			values = b.fromExpressions(clause.Values())
+		}
+		res.Body.List = append(res.Body.List, &ast.CaseClause{
+			Case: b.nextPos(),
+			List: values,
+			Colon: b.nextPos(),
+			Body: b.fromStatements(clause.Statements()),
+		})
+	}
+	res.Body.Rbrace = b.nextPos()
+	return res
+}
+
+func (b *ASTBuilder) FromTypeSwitchStatement(s convert.TypeSwitchStatement) ast.Stmt {
+	res := &ast.TypeSwitchStmt{
+		Switch: b.nextPos(),
+		Init: b.FromStatement(s.Init()),
+	}
+
+	guard := &ast.TypeAssertExpr{X: b.FromExpression(s.Subject())}
+	if binding := s.Binding(); binding != nil {
+		res.Assign = &ast.AssignStmt{
+			Lhs: []ast.Expr{b.FromIdent(binding)},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{guard},
+		}
+	} else {
+		res.Assign = &ast.ExprStmt{X: guard}
+	}
+
+	res.Body = &ast.BlockStmt{Lbrace: b.nextPos()}
+	for _, clause := range s.Clauses() {
+		var types []ast.Expr
+		if !clause.IsDefault() {
+			for _, typ := range clause.Types() {
+				types = append(types, b.FromTypeDefinition(typ))
+			}
+		}
+		res.Body.List = append(res.Body.List, &ast.CaseClause{
+			Case: b.nextPos(),
+			List: types,
+			Colon: b.nextPos(),
+			Body: b.fromStatements(clause.Statements()),
+		})
+	}
+	res.Body.Rbrace = b.nextPos()
+	return res
+}
+
+func (b *ASTBuilder) FromSelectStatement(s convert.SelectStatement) ast.Stmt {
+	res := &ast.SelectStmt{
+		Select: b.nextPos(),
+		Body: &ast.BlockStmt{Lbrace: b.nextPos()},
+	}
+	for _, clause := range s.Clauses() {
+		res.Body.List = append(res.Body.List, &ast.CommClause{
+			Case: b.nextPos(),
+			Comm: b.FromStatement(clause.Comm()),
+			Colon: b.nextPos(),
+			Body: b.fromStatements(clause.Statements()),
+		})
+	}
+	res.Body.Rbrace = b.nextPos()
+	return res
+}
+
+func (b *ASTBuilder) FromReturnStatement(s convert.ReturnStatement) ast.Stmt {
+	return &ast.ReturnStmt{
+		Return: b.nextPos(),
+		Results: b.fromExpressions(s.Results()),
+	}
+}
+
+func (b *ASTBuilder) FromBranchStatement(s convert.BranchStatement) ast.Stmt {
+	return &ast.BranchStmt{
+		TokPos: b.nextPos(),
+		Tok: s.Keyword(),
+		Label: b.FromIdent(s.Label()),
+	}
+}
+
+func (b *ASTBuilder) FromLabeledStatement(s convert.LabeledStatement) ast.Stmt {
+	res := &ast.LabeledStmt{
+		Label: b.FromIdent(s.Label()),
+		Colon: b.nextPos(),
+		Stmt: b.FromStatement(s.Statement()),
+	}
+	if res.Stmt == nil {
+		// a label at the end of a block
+		res.Stmt = &ast.EmptyStmt{Semicolon: res.Colon, Implicit: true}
+	}
+	return res
+}
@@ -0,0 +1,178 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// sourceImporter type checks imported packages from their source, like
+// importer.ForCompiler(fset, "source", nil), but never lets the go command
+// download anything (go/build runs it with the inherited environment, so
+// modules missing from the module cache would be fetched via GOPROXY).
+type sourceImporter struct {
+	fset *token.FileSet
+	buildContext build.Context
+
+	// found caches the package found for each import path
+	found map[string]*build.Package
+	// packages holds the checked packages by import path,
+	// with nil for packages still being checked
+	packages map[string]*types.Package
+}
+
+func newSourceImporter(fset *token.FileSet, buildContext build.Context) *sourceImporter {
+	return &sourceImporter{
+		fset: fset,
+		buildContext: buildContext,
+		found: make(map[string]*build.Package),
+		packages: make(map[string]*types.Package),
+	}
+}
+
+func (imp *sourceImporter) Import(path string) (*types.Package, error) {
+	return imp.ImportFrom(path, "", 0)
+}
+
+func (imp *sourceImporter) ImportFrom(path, srcDir string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+
+	bp, err := imp.findPackage(path, srcDir)
+	if err != nil {
+		return nil, err
+	}
+	if pkg, seen := imp.packages[bp.ImportPath]; seen {
+		if pkg == nil {
+			return nil, fmt.Errorf("import cycle through package %q", bp.ImportPath)
+		}
+		return pkg, nil
+	}
+	imp.packages[bp.ImportPath] = nil
+
+	var files []*ast.File
+	for _, filename := range append(bp.GoFiles, bp.CgoFiles...) {
+		file, err := parser.ParseFile(imp.fset, filepath.Join(bp.Dir, filename), nil, 0)
+		if err != nil {
+			delete(imp.packages, bp.ImportPath)
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	var firstErr error
+	config := &types.Config{
+		Importer: imp,
+		IgnoreFuncBodies: true,
+		FakeImportC: true,
+		Error: func(err error) {
+			var typeErr types.Error
+			if firstErr == nil && (!errors.As(err, &typeErr) || !typeErr.Soft) {
+				firstErr = err
+			}
+		},
+	}
+	// errors are collected by config.Error
+	pkg, _ := config.Check(bp.ImportPath, imp.fset, files, nil)
+	imp.packages[bp.ImportPath] = pkg
+	if firstErr != nil {
+		return pkg, fmt.Errorf("type checking package %q failed: %v", bp.ImportPath, firstErr)
+	}
+	return pkg, nil
+}
+
+// findPackage finds the source of the package with the given import path,
+// as imported from srcDir.
+func (imp *sourceImporter) findPackage(path, srcDir string) (*build.Package, error) {
+	key := path
+	if build.IsLocalImport(path) {
+		key = filepath.Join(srcDir, path)
+	}
+	if bp, found := imp.found[key]; found {
+		return bp, nil
+	}
+
+	var bp *build.Package
+	var err error
+	if imp.isStd(path, srcDir) {
+		// go/build finds the standard library itself, without the go command
+		bp, err = imp.buildContext.Import(path, srcDir, 0)
+	} else {
+		bp, err = imp.goList(path, srcDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	imp.found[key] = bp
+	return bp, nil
+}
+
+// isStd checks if the given import refers to a package in GOROOT.
+func (imp *sourceImporter) isStd(path, srcDir string) bool {
+	gorootSrc := filepath.Join(imp.buildContext.GOROOT, "src")
+	if srcDir != "" {
+		if rel, err := filepath.Rel(gorootSrc, srcDir); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	if build.IsLocalImport(path) {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(gorootSrc, path))
+	return err == nil && info.IsDir()
+}
+
+// goList finds a package with the go command, run from srcDir.  Module
+// and toolchain downloads are disabled, so only modules that are already
+// in the module cache (or vendored) are found.
+func (imp *sourceImporter) goList(path, srcDir string) (*build.Package, error) {
+	ctx := imp.buildContext
+	cmd := exec.Command(filepath.Join(ctx.GOROOT, "bin", "go"), "list", "-e",
+		"-tags="+strings.Join(ctx.BuildTags, ","),
+		"-f={{.Dir}}\n{{.ImportPath}}\n{{with .Error}}{{.Err}}{{end}}",
+		"--", path)
+	cmd.Dir = srcDir
+	cmd.Env = append(os.Environ(),
+		"GOPROXY=off",
+		"GOTOOLCHAIN=local",
+		"GOOS="+ctx.GOOS,
+		"GOARCH="+ctx.GOARCH,
+		"GOROOT="+ctx.GOROOT,
+		"GOPATH="+ctx.GOPATH,
+	)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %v\n%s", path, err, stderr.String())
+	}
+
+	lines := strings.SplitN(string(out), "\n", 3)
+	if len(lines) < 3 {
+		return nil, fmt.Errorf("go list %s: unexpected output %q", path, out)
+	}
+	dir, importPath, listErr := lines[0], lines[1], strings.TrimSpace(lines[2])
+	if listErr != "" {
+		return nil, fmt.Errorf("cannot find package %q: %s", path, listErr)
+	}
+	if dir == "" {
+		return nil, fmt.Errorf("cannot find package %q", path)
+	}
+
+	bp, err := ctx.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	bp.ImportPath = importPath
+	return bp, nil
+}
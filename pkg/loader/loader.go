@@ -2,6 +2,7 @@ package loader
 
 import (
 	"io"
+	"path/filepath"
 	"sync"
 
 	"go/ast"
@@ -21,6 +22,8 @@ type Loader interface {
 	// Files returns the parsed ASTs.  Do not call further
 	// parse functions until this returns.
 	Files() []*ast.File
+	// FileSet returns the FileSet used to position the parsed ASTs.
+	FileSet() *token.FileSet
 }
 
 // fileLoader loads content into a set of ast.Files, for use later.
@@ -52,8 +55,9 @@ func (f *fileLoader) FromDirectory(directory string) []error {
 	// TODO: what do we do about no go found?
 
 	var filenames []string
-	filenames = append(filenames, pkginfo.GoFiles...)
-	filenames = append(filenames, pkginfo.CgoFiles...)
+	for _, name := range append(pkginfo.GoFiles, pkginfo.CgoFiles...) {
+		filenames = append(filenames, filepath.Join(directory, name))
+	}
 
 	// TODO: consider test files and xtest files?
 
@@ -88,11 +92,14 @@ func (f *fileLoader) parse(name string, src interface{}) error {
 	f.waitForFiles.Add(1)
 
 	file, err := parser.ParseFile(f.fileSet, name, src, parser.ParseComments)
-	if err != nil {
+	if file == nil {
+		f.waitForFiles.Done()
 		return err
 	}
+	// files with syntax errors are still partially usable
+	// (see convert.FromRawChecked), so keep them around
 	f.fileChan <- file
-	return nil
+	return err
 }
 
 // receiveFiles watches the file channel and
@@ -109,6 +116,10 @@ func (f *fileLoader) Files() []*ast.File {
 	return f.files
 }
 
+func (f *fileLoader) FileSet() *token.FileSet {
+	return f.fileSet
+}
+
 // NewLoader returns a new Loader which can parse files concurrently.
 func NewLoader() Loader {
 	loader := &fileLoader{
@@ -0,0 +1,137 @@
+package loader
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// TypedPackage is a package that has been type checked with go/types.
+// Pass its fields to convert.FromTypedPackage to get a type-checked AST:
+//
+//   convert.FromTypedPackage(pkg.FileSet, convert.TypeInfo{
+//       Package: pkg.Types,
+//       Info: pkg.Info,
+//       ImportFiles: pkg.ImportFiles,
+//   }, pkg.Files...)
+type TypedPackage struct {
+	FileSet *token.FileSet
+	Files []*ast.File
+	Types *types.Package
+	Info *types.Info
+
+	buildContext build.Context
+}
+
+// TypeCheck runs go/types over the files in the given loader, which should
+// all be from the package with the given import path.  Imported packages are
+// type checked from their source, found in GOROOT, or with the go command
+// in GOPATH, vendor directories, or the module cache.  Module and toolchain
+// downloads are disabled (GOPROXY=off), so modules missing from the cache
+// are reported as import errors instead.  Type errors don't stop checking,
+// so the partially checked package is returned along with any errors.
+func TypeCheck(l Loader, path string) (*TypedPackage, []error) {
+	fset := l.FileSet()
+	files := append([]*ast.File(nil), l.Files()...)
+
+	// files arrive in whatever order they were parsed in
+	sort.Slice(files, func(i, j int) bool {
+		return fset.Position(files[i].Pos()).Filename < fset.Position(files[j].Pos()).Filename
+	})
+
+	var errs []error
+	files, errs = samePackageFiles(fset, files)
+
+	pkg := &TypedPackage{
+		FileSet: fset,
+		Files: files,
+		Info: &types.Info{
+			Types: make(map[ast.Expr]types.TypeAndValue),
+			Defs: make(map[*ast.Ident]types.Object),
+			Uses: make(map[*ast.Ident]types.Object),
+			Implicits: make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes: make(map[ast.Node]*types.Scope),
+		},
+		buildContext: build.Default,
+	}
+
+	config := &types.Config{
+		Importer: newSourceImporter(fset, pkg.buildContext),
+		Error: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	// errors are collected by config.Error
+	pkg.Types, _ = config.Check(path, fset, files, pkg.Info)
+
+	return pkg, errs
+}
+
+// samePackageFiles filters out files that aren't in the same package as the
+// rest (e.g. external test packages), returning errors for each one.
+func samePackageFiles(fset *token.FileSet, files []*ast.File) ([]*ast.File, []error) {
+	if len(files) == 0 {
+		return files, nil
+	}
+
+	// prefer the non-test package, if there is one
+	name := files[0].Name.Name
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name.Name, "_test") {
+			name = file.Name.Name
+			break
+		}
+	}
+
+	var res []*ast.File
+	var errs []error
+	for _, file := range files {
+		if file.Name.Name != name {
+			errs = append(errs, fmt.Errorf("%s: skipping file in package %s, not %s", fset.Position(file.Pos()).Filename, file.Name.Name, name))
+			continue
+		}
+		res = append(res, file)
+	}
+	return res, errs
+}
+
+// ImportFiles parses the source files of a package imported by this package,
+// returning nil if they can't be found.  It's intended for use as
+// convert.TypeInfo.ImportFiles.
+func (p *TypedPackage) ImportFiles(imported *types.Package) []*ast.File {
+	// any declaration in the package tells us where it lives
+	var dir string
+	scope := imported.Scope()
+	for _, name := range scope.Names() {
+		if pos := scope.Lookup(name).Pos(); pos.IsValid() {
+			dir = filepath.Dir(p.FileSet.Position(pos).Filename)
+			break
+		}
+	}
+	if dir == "" {
+		return nil
+	}
+
+	pkginfo, err := p.buildContext.ImportDir(dir, 0 /* No special flags */)
+	if err != nil {
+		return nil
+	}
+
+	var files []*ast.File
+	for _, filename := range append(pkginfo.GoFiles, pkginfo.CgoFiles...) {
+		file, err := parser.ParseFile(p.FileSet, filepath.Join(dir, filename), nil, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files
+}
@@ -0,0 +1,177 @@
+// Package markers parses marker comments, like `// +basicimpl:skip`, from
+// the docs of declarations.  Generators define the markers they understand,
+// along with the type of their arguments, and register them in a Registry,
+// which then parses them from the nodes in "pkg/convert".
+package markers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"go/token"
+)
+
+// TargetType is the kind of node that a marker may be placed on.
+type TargetType int
+
+const (
+	// DescribesPackage markers are placed in package docs.
+	DescribesPackage TargetType = iota
+	// DescribesType markers are placed on type declarations.
+	DescribesType
+	// DescribesField markers are placed on struct fields.
+	DescribesField
+	// DescribesFunc markers are placed on funcs and methods.
+	DescribesFunc
+)
+
+func (t TargetType) String() string {
+	switch t {
+	case DescribesPackage:
+		return "package"
+	case DescribesType:
+		return "type"
+	case DescribesField:
+		return "field"
+	case DescribesFunc:
+		return "func"
+	default:
+		return fmt.Sprintf("TargetType(%d)", int(t))
+	}
+}
+
+// Definition is a single marker, such as `+envmap:skip` or
+// `+envmap:default=3`, along with the type of its arguments.
+//
+// The argument type determines the syntax of the marker:
+//
+// - bools may be written as just `+name`, or `+name=true`
+// - strings, ints, and lists are written as `+name=value`, where
+//   strings may be bare or quoted, and lists are written as either
+//   `{a,b,c}` or `a;b;c`
+// - structs are written as `+name:arg1=value,arg2=value`, where each
+//   argument is an exported field, named as in the field, but starting
+//   with a lowercase letter, or as given by a `marker:"name"` tag.
+//   Pointer fields and fields tagged with `marker:",optional"` are
+//   optional.
+type Definition struct {
+	// Name is the name of the marker, without the leading `+`.
+	Name string
+	// Target is the kind of node the marker may be placed on.
+	Target TargetType
+	// Output is the type of the marker's argument.
+	Output reflect.Type
+
+	fields []argField
+}
+
+// argField is a single struct argument.
+type argField struct {
+	name string
+	index int
+	optional bool
+}
+
+// Define defines a marker whose argument has the same type as output
+// (e.g. `true`, `""`, or `MyArgs{}`).
+func Define(name string, target TargetType, output interface{}) (*Definition, error) {
+	if name == "" || strings.ContainsAny(name, "= \t") {
+		return nil, fmt.Errorf("invalid marker name %q", name)
+	}
+	def := &Definition{
+		Name: name,
+		Target: target,
+		Output: reflect.TypeOf(output),
+	}
+	if def.Output == nil {
+		return nil, fmt.Errorf("marker %s: missing output type", name)
+	}
+	if err := checkArgType(def.Output); err != nil {
+		return nil, fmt.Errorf("marker %s: %v", name, err)
+	}
+
+	if def.Output.Kind() == reflect.Struct {
+		for i := 0; i < def.Output.NumField(); i++ {
+			field := def.Output.Field(i)
+			if field.PkgPath != "" {
+				// unexported
+				continue
+			}
+			arg := argField{
+				name: lowerFirst(field.Name),
+				index: i,
+				optional: field.Type.Kind() == reflect.Ptr,
+			}
+			if tag, hasTag := field.Tag.Lookup("marker"); hasTag {
+				tagName, opts, _ := strings.Cut(tag, ",")
+				if tagName != "" {
+					arg.name = tagName
+				}
+				arg.optional = arg.optional || opts == "optional"
+			}
+			if field.Type.Kind() == reflect.Struct {
+				return nil, fmt.Errorf("marker %s: argument %s: nested struct arguments aren't supported", name, arg.name)
+			}
+			if err := checkArgType(field.Type); err != nil {
+				return nil, fmt.Errorf("marker %s: argument %s: %v", name, arg.name, err)
+			}
+			def.fields = append(def.fields, arg)
+		}
+	}
+
+	return def, nil
+}
+
+// Must panics if err is non-nil, for use with Define when
+// defining markers in package-level variables.
+func Must(def *Definition, err error) *Definition {
+	if err != nil {
+		panic(err)
+	}
+	return def
+}
+
+// checkArgType checks that the given type can be parsed as an argument.
+func checkArgType(typ reflect.Type) error {
+	switch typ.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return nil
+	case reflect.Slice, reflect.Ptr:
+		if typ.Elem().Kind() == reflect.Struct {
+			return fmt.Errorf("unsupported argument type %s", typ)
+		}
+		return checkArgType(typ.Elem())
+	case reflect.Struct:
+		return nil
+	default:
+		return fmt.Errorf("unsupported argument type %s", typ)
+	}
+}
+
+func lowerFirst(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+// Error is a malformed or unknown marker.
+type Error struct {
+	// Position is the position of the comment containing the marker (or
+	// of its line, in block comments), or of the node the marker was
+	// placed on if the comment's position is unknown.
+	Position token.Position
+	// Marker is the text of the marker, without the leading `+`.
+	Marker string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Position.IsValid() {
+		return fmt.Sprintf("%s: +%s: %s", e.Position, e.Marker, e.Message)
+	}
+	return fmt.Sprintf("+%s: %s", e.Marker, e.Message)
+}
@@ -0,0 +1,382 @@
+package markers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go/token"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// Values are the parsed markers on a single node, by name.  Markers
+// may be repeated, so each name has a list of values, in order.
+// Values are of the Output type of the corresponding Definition.
+type Values map[string][]interface{}
+
+// Get returns the first value for the given marker, or nil
+// if it's not present.
+func (v Values) Get(name string) interface{} {
+	if len(v[name]) == 0 {
+		return nil
+	}
+	return v[name][0]
+}
+
+// Has checks if the given marker is present.
+func (v Values) Has(name string) bool {
+	return len(v[name]) > 0
+}
+
+// PackageMarkers parses the markers in the package docs.  For packages
+// from convert.FromPackage, the docs of each file are parsed.
+func (r *Registry) PackageMarkers(a convert.AST) (Values, []error) {
+	pkg, isPkg := a.(convert.Package)
+	if !isPkg {
+		return r.Markers(DescribesPackage, a)
+	}
+
+	res := make(Values)
+	var errs []error
+	for _, file := range pkg.Files() {
+		values, fileErrs := r.Markers(DescribesPackage, file)
+		for name, vals := range values {
+			res[name] = append(res[name], vals...)
+		}
+		errs = append(errs, fileErrs...)
+	}
+	return res, errs
+}
+
+// TypeMarkers parses the markers in the docs of a type declaration.
+func (r *Registry) TypeMarkers(decl convert.TypeDeclaration) (Values, []error) {
+	return r.Markers(DescribesType, decl)
+}
+
+// FieldMarkers parses the markers in the docs of a struct field.
+func (r *Registry) FieldMarkers(field convert.Field) (Values, []error) {
+	return r.Markers(DescribesField, field)
+}
+
+// FuncMarkers parses the markers in the docs of a func or method.
+func (r *Registry) FuncMarkers(decl convert.FuncDeclaration) (Values, []error) {
+	return r.Markers(DescribesFunc, decl)
+}
+
+// Markers parses the markers in the docs of the given node, which should
+// implement convert.Doced (nodes without docs have no markers).  Lines
+// of the docs starting with `+` followed by a letter are markers.
+// Malformed and unknown markers, and markers that can't be placed on the
+// given target, are returned as *Error, positioned at their comments if
+// the node implements convert.DocPositioned, or otherwise at the node if
+// it implements convert.Positioned.
+func (r *Registry) Markers(target TargetType, node interface{}) (Values, []error) {
+	res := make(Values)
+	doced, isDoced := node.(convert.Doced)
+	if !isDoced {
+		return res, nil
+	}
+
+	var pos token.Position
+	if positioned, isPositioned := node.(convert.Positioned); isPositioned {
+		pos = positioned.Position()
+	}
+
+	doc := doced.Doc()
+	var docPositions []token.Position
+	if positioned, isPositioned := node.(convert.DocPositioned); isPositioned {
+		docPositions = positioned.DocPositions()
+	}
+
+	var errs []error
+	scan(doc, func(text string, comment, line, lineStart int) {
+		name, val, err := r.parseMarker(target, text)
+		if err != nil {
+			errPos := pos
+			if len(docPositions) == len(doc) && docPositions[comment].IsValid() {
+				errPos = linePosition(docPositions[comment], line, lineStart)
+			}
+			errs = append(errs, &Error{Position: errPos, Marker: text, Message: err.Error()})
+			return
+		}
+		res[name] = append(res[name], val)
+	})
+	return res, errs
+}
+
+// Scan returns the markers in the given docs, without the leading `+`,
+// in order.  Unlike Markers, it needs no Registry, and doesn't parse or
+// check the markers it finds.
+func Scan(doc []string) []string {
+	var res []string
+	scan(doc, func(text string, _, _, _ int) {
+		res = append(res, text)
+	})
+	return res
+}
+
+// scan calls fn with each marker in the given docs (lines starting
+// with `+` followed by a letter), without the `+`, along with the
+// index of its comment, and the index and offset of its line within
+// the comment.
+func scan(doc []string, fn func(text string, comment, line, lineStart int)) {
+	for i, comment := range doc {
+		// block comments may span several lines
+		lineStart := 0
+		for j, line := range strings.Split(comment, "\n") {
+			start := lineStart
+			lineStart += len(line)+1
+			text := strings.TrimSpace(line)
+			if len(text) < 2 || text[0] != '+' || !unicode.IsLetter(rune(text[1])) {
+				continue
+			}
+			fn(text[1:], i, j, start)
+		}
+	}
+}
+
+// linePosition returns the position of the given line of a comment.
+// Only block comments have several lines, and every line but the first
+// starts a line of the file.
+func linePosition(commentPos token.Position, line, lineStart int) token.Position {
+	if line == 0 {
+		return commentPos
+	}
+	pos := commentPos
+	pos.Line += line
+	pos.Column = 1
+	pos.Offset += len("/*") + lineStart
+	return pos
+}
+
+// Check parses all the package, type, field, and func markers in the
+// given AST, returning any errors.
+func (r *Registry) Check(a convert.AST) []error {
+	_, errs := r.PackageMarkers(a)
+	v := &checkVisitor{registry: r, errs: errs}
+	convert.Walk(a, v)
+	return v.errs
+}
+
+type checkVisitor struct {
+	convert.BaseVisitor
+	registry *Registry
+	errs []error
+}
+
+func (v *checkVisitor) VisitTypeDeclaration(decl convert.TypeDeclaration) bool {
+	_, errs := v.registry.TypeMarkers(decl)
+	v.errs = append(v.errs, errs...)
+	return true
+}
+
+func (v *checkVisitor) VisitFuncDeclaration(decl convert.FuncDeclaration) bool {
+	_, errs := v.registry.FuncMarkers(decl)
+	v.errs = append(v.errs, errs...)
+	return true
+}
+
+func (v *checkVisitor) VisitTypeDefinition(def convert.TypeDefinition) bool {
+	// only check the fields of structs, and not
+	// type params or func params and results
+	if structDef, isStruct := def.(convert.StructTypeDefinition); isStruct {
+		for _, field := range structDef.Fields() {
+			_, errs := v.registry.FieldMarkers(field)
+			v.errs = append(v.errs, errs...)
+		}
+	}
+	return true
+}
+
+// parseMarker parses a single marker (without the leading `+`).
+func (r *Registry) parseMarker(target TargetType, text string) (string, interface{}, error) {
+	name, rest, known := r.split(text)
+	if !known {
+		return "", nil, fmt.Errorf("unknown marker")
+	}
+	def := r.Lookup(name, target)
+	if def == nil {
+		var targets []string
+		for other := range r.defs[name] {
+			targets = append(targets, other.String())
+		}
+		sort.Strings(targets)
+		return "", nil, fmt.Errorf("marker may not be placed on a %s (only on %s)", target, strings.Join(targets, ", "))
+	}
+
+	val, err := def.parse(rest)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, val.Interface(), nil
+}
+
+// parse parses the arguments of a marker (the text after the name).
+func (d *Definition) parse(rest string) (reflect.Value, error) {
+	if d.Output.Kind() == reflect.Struct {
+		if rest != "" && rest[0] != ':' {
+			return reflect.Value{}, fmt.Errorf("expected arguments like `:name=value`, not %q", rest)
+		}
+		return d.parseArgs(strings.TrimPrefix(rest, ":"))
+	}
+
+	if rest == "" {
+		if d.Output.Kind() == reflect.Bool {
+			return reflect.ValueOf(true).Convert(d.Output), nil
+		}
+		return reflect.Value{}, fmt.Errorf("missing value (expected `=value`)")
+	}
+	if rest[0] != '=' {
+		return reflect.Value{}, fmt.Errorf("expected `=value`, not %q", rest)
+	}
+	return parseValue(d.Output, rest[1:])
+}
+
+// parseArgs parses the comma-separated arguments of a struct marker.
+func (d *Definition) parseArgs(text string) (reflect.Value, error) {
+	res := reflect.New(d.Output).Elem()
+	seen := make(map[string]bool)
+	if strings.TrimSpace(text) != "" {
+		for _, arg := range splitTopLevel(text, ',') {
+			key, raw, hasValue := strings.Cut(arg, "=")
+			key = strings.TrimSpace(key)
+
+			var field *argField
+			for i := range d.fields {
+				if d.fields[i].name == key {
+					field = &d.fields[i]
+					break
+				}
+			}
+			if field == nil {
+				return reflect.Value{}, fmt.Errorf("unknown argument %q", key)
+			}
+			if seen[key] {
+				return reflect.Value{}, fmt.Errorf("argument %q given more than once", key)
+			}
+			seen[key] = true
+
+			dest := res.Field(field.index)
+			if !hasValue {
+				// bare bool arguments are true
+				if dest.Kind() == reflect.Bool || (dest.Kind() == reflect.Ptr && dest.Type().Elem().Kind() == reflect.Bool) {
+					raw = "true"
+				} else {
+					return reflect.Value{}, fmt.Errorf("missing value for argument %q", key)
+				}
+			}
+			val, err := parseValue(dest.Type(), raw)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("argument %q: %v", key, err)
+			}
+			dest.Set(val)
+		}
+	}
+
+	for _, field := range d.fields {
+		if !field.optional && !seen[field.name] {
+			return reflect.Value{}, fmt.Errorf("missing required argument %q", field.name)
+		}
+	}
+	return res, nil
+}
+
+// parseValue parses a single (non-struct) value of the given type.
+func parseValue(typ reflect.Type, raw string) (reflect.Value, error) {
+	raw = strings.TrimSpace(raw)
+	res := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.Ptr:
+		elem, err := parseValue(typ.Elem(), raw)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		res.Set(reflect.New(typ.Elem()))
+		res.Elem().Set(elem)
+	case reflect.Bool:
+		val, err := strconv.ParseBool(raw)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("expected true or false, not %q", raw)
+		}
+		res.SetBool(val)
+	case reflect.String:
+		if strings.HasPrefix(raw, `"`) || strings.HasPrefix(raw, "`") {
+			val, err := strconv.Unquote(raw)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("bad quoted string %s: %v", raw, err)
+			}
+			raw = val
+		}
+		res.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val, err := strconv.ParseInt(raw, 0, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("expected an integer, not %q", raw)
+		}
+		res.SetInt(val)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val, err := strconv.ParseUint(raw, 0, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("expected a non-negative integer, not %q", raw)
+		}
+		res.SetUint(val)
+	case reflect.Slice:
+		// lists are either `{a,b,c}` or `a;b;c`
+		var items []string
+		if strings.HasPrefix(raw, "{") {
+			if !strings.HasSuffix(raw, "}") {
+				return reflect.Value{}, fmt.Errorf("unterminated list %s", raw)
+			}
+			if inner := raw[1:len(raw)-1]; strings.TrimSpace(inner) != "" {
+				items = splitTopLevel(inner, ',')
+			}
+		} else if raw != "" {
+			items = splitTopLevel(raw, ';')
+		}
+		res.Set(reflect.MakeSlice(typ, 0, len(items)))
+		for _, item := range items {
+			elem, err := parseValue(typ.Elem(), item)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			res.Set(reflect.Append(res, elem))
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported argument type %s", typ)
+	}
+	return res, nil
+}
+
+// splitTopLevel splits the given text on sep, ignoring
+// separators inside quoted strings and braces.
+func splitTopLevel(text string, sep byte) []string {
+	var res []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == sep && depth == 0:
+			res = append(res, text[start:i])
+			start = i+1
+		}
+	}
+	return append(res, text[start:])
+}
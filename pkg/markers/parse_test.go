@@ -0,0 +1,282 @@
+package markers
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/directxman12/envmap/pkg/convert"
+	"github.com/directxman12/envmap/pkg/generate/builder"
+)
+
+type rangeArgs struct {
+	Min       int
+	Max       *int
+	Name      string `marker:"label,optional"`
+	Exclusive bool   `marker:",optional"`
+}
+
+func testRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := &Registry{}
+	err := r.Register(
+		Must(Define("gen", DescribesType, true)),
+		Must(Define("gen:deepcopy", DescribesType, true)),
+		Must(Define("gen:deepcopy:root", DescribesType, "")),
+		Must(Define("default", DescribesField, "")),
+		Must(Define("enum", DescribesField, []string(nil))),
+		Must(Define("sizes", DescribesField, []int(nil))),
+		Must(Define("range", DescribesField, rangeArgs{})),
+		Must(Define("range", DescribesType, 0)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRegistrySplit(t *testing.T) {
+	r := testRegistry(t)
+	cases := []struct {
+		text  string
+		name  string
+		rest  string
+		known bool
+	}{
+		{text: "gen", name: "gen", rest: "", known: true},
+		{text: "gen=false", name: "gen", rest: "=false", known: true},
+		// the longest registered name wins
+		{text: "gen:deepcopy", name: "gen:deepcopy", rest: "", known: true},
+		{text: "gen:deepcopy=true", name: "gen:deepcopy", rest: "=true", known: true},
+		{text: "gen:deepcopy:root=x", name: "gen:deepcopy:root", rest: "=x", known: true},
+		// names only match at a boundary
+		{text: "gen:deepcopyish", name: "gen", rest: ":deepcopyish", known: true},
+		{text: "generate=x", name: "generate", rest: "=x", known: false},
+		{text: "unknown:thing=x", name: "unknown:thing", rest: "=x", known: false},
+		{text: "range:min=1", name: "range", rest: ":min=1", known: true},
+	}
+	for _, c := range cases {
+		t.Run(c.text, func(t *testing.T) {
+			name, rest, known := r.split(c.text)
+			if name != c.name || rest != c.rest || known != c.known {
+				t.Errorf("expected (%q, %q, %v), got (%q, %q, %v)", c.name, c.rest, c.known, name, rest, known)
+			}
+		})
+	}
+}
+
+func TestSplitTopLevel(t *testing.T) {
+	cases := []struct {
+		text     string
+		sep      byte
+		expected []string
+	}{
+		{text: "a,b,c", sep: ',', expected: []string{"a", "b", "c"}},
+		{text: "a", sep: ',', expected: []string{"a"}},
+		{text: `"a,b",c`, sep: ',', expected: []string{`"a,b"`, "c"}},
+		{text: "`a,b`,c", sep: ',', expected: []string{"`a,b`", "c"}},
+		{text: `"a\",b",c`, sep: ',', expected: []string{`"a\",b"`, "c"}},
+		{text: "x={a,b},y=c", sep: ',', expected: []string{"x={a,b}", "y=c"}},
+		{text: "a;b;c", sep: ';', expected: []string{"a", "b", "c"}},
+		{text: "a,b;c", sep: ';', expected: []string{"a,b", "c"}},
+	}
+	for _, c := range cases {
+		t.Run(c.text, func(t *testing.T) {
+			if actual := splitTopLevel(c.text, c.sep); !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	three := 3
+	cases := []struct {
+		name     string
+		typ      reflect.Type
+		raw      string
+		expected interface{}
+		err      string
+	}{
+		{name: "bool", typ: reflect.TypeOf(true), raw: "false", expected: false},
+		{name: "bad bool", typ: reflect.TypeOf(true), raw: "yes", err: "expected true or false"},
+		{name: "bare string", typ: reflect.TypeOf(""), raw: " hello ", expected: "hello"},
+		{name: "quoted string", typ: reflect.TypeOf(""), raw: `"a, b; {c}"`, expected: "a, b; {c}"},
+		{name: "raw string", typ: reflect.TypeOf(""), raw: "`a\\b`", expected: `a\b`},
+		{name: "bad quoted string", typ: reflect.TypeOf(""), raw: `"abc`, err: "bad quoted string"},
+		{name: "int", typ: reflect.TypeOf(0), raw: "-12", expected: -12},
+		{name: "hex int", typ: reflect.TypeOf(0), raw: "0x10", expected: 16},
+		{name: "bad int", typ: reflect.TypeOf(0), raw: "x", err: "expected an integer"},
+		{name: "uint", typ: reflect.TypeOf(uint8(0)), raw: "255", expected: uint8(255)},
+		{name: "negative uint", typ: reflect.TypeOf(uint(0)), raw: "-1", err: "non-negative"},
+		{name: "pointer", typ: reflect.TypeOf(&three), raw: "3", expected: &three},
+		{name: "braced list", typ: reflect.TypeOf([]string(nil)), raw: "{a,b,c}", expected: []string{"a", "b", "c"}},
+		{name: "semicolon list", typ: reflect.TypeOf([]string(nil)), raw: "a;b;c", expected: []string{"a", "b", "c"}},
+		{name: "commas in semicolon list", typ: reflect.TypeOf([]string(nil)), raw: "a,b;c", expected: []string{"a,b", "c"}},
+		{name: "quoted list items", typ: reflect.TypeOf([]string(nil)), raw: `{"a,b",c}`, expected: []string{"a,b", "c"}},
+		{name: "empty braced list", typ: reflect.TypeOf([]string(nil)), raw: "{}", expected: []string{}},
+		{name: "int list", typ: reflect.TypeOf([]int(nil)), raw: "{1, 2}", expected: []int{1, 2}},
+		{name: "unterminated list", typ: reflect.TypeOf([]string(nil)), raw: "{a,b", err: "unterminated list"},
+		{name: "bad list item", typ: reflect.TypeOf([]int(nil)), raw: "1;x", err: "expected an integer"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			val, err := parseValue(c.typ, c.raw)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected an error containing %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(val.Interface(), c.expected) {
+				t.Errorf("expected %#v, got %#v", c.expected, val.Interface())
+			}
+		})
+	}
+}
+
+func TestParseMarker(t *testing.T) {
+	r := testRegistry(t)
+	two := 2
+	cases := []struct {
+		text     string
+		target   TargetType
+		name     string
+		expected interface{}
+		err      string
+	}{
+		{text: "gen", target: DescribesType, name: "gen", expected: true},
+		{text: "gen=false", target: DescribesType, name: "gen", expected: false},
+		{text: "gen:deepcopy", target: DescribesType, name: "gen:deepcopy", expected: true},
+		{text: "default=foo", target: DescribesField, name: "default", expected: "foo"},
+		{text: `default="a=b"`, target: DescribesField, name: "default", expected: "a=b"},
+		{text: "default", target: DescribesField, err: "missing value"},
+		{text: "enum={a,b}", target: DescribesField, name: "enum", expected: []string{"a", "b"}},
+		{text: "enum=a;b", target: DescribesField, name: "enum", expected: []string{"a", "b"}},
+		{text: "sizes={1,2};", target: DescribesField, err: "unterminated list"},
+		{text: "range:min=1", target: DescribesField, name: "range", expected: rangeArgs{Min: 1}},
+		{text: "range:min=1,max=2,label=\"x, y\"", target: DescribesField, name: "range", expected: rangeArgs{Min: 1, Max: &two, Name: "x, y"}},
+		// bare bool struct arguments are true
+		{text: "range:min=1,exclusive", target: DescribesField, name: "range", expected: rangeArgs{Min: 1, Exclusive: true}},
+		{text: "range:min=1,exclusive=false", target: DescribesField, name: "range", expected: rangeArgs{Min: 1}},
+		{text: "range:max=2", target: DescribesField, err: `missing required argument "min"`},
+		{text: "range", target: DescribesField, err: `missing required argument "min"`},
+		{text: "range:min", target: DescribesField, err: `missing value for argument "min"`},
+		{text: "range:min=1,min=2", target: DescribesField, err: "more than once"},
+		{text: "range:min=1,other=2", target: DescribesField, err: `unknown argument "other"`},
+		{text: "range=1", target: DescribesField, err: "expected arguments like"},
+		// the same name may mean different things on different targets
+		{text: "range=5", target: DescribesType, name: "range", expected: 5},
+		{text: "gen", target: DescribesField, err: "may not be placed on a field (only on type)"},
+		{text: "range=5", target: DescribesFunc, err: "may not be placed on a func (only on field, type)"},
+		{text: "nope", target: DescribesType, err: "unknown marker"},
+	}
+	for _, c := range cases {
+		t.Run(c.text, func(t *testing.T) {
+			name, val, err := r.parseMarker(c.target, c.text)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected an error containing %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != c.name || !reflect.DeepEqual(val, c.expected) {
+				t.Errorf("expected %s=%#v, got %s=%#v", c.name, c.expected, name, val)
+			}
+		})
+	}
+}
+
+func TestMarkersErrors(t *testing.T) {
+	const src = `package x
+
+// Thing is a thing.
+// +gen
+// +default=x
+// +nope
+/* +gen=maybe
+   +range=5
+   +enum={a} */
+type Thing struct{}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decl := convert.FromRawWithFileSet(fset, file).Types()[0]
+
+	values, errs := testRegistry(t).TypeMarkers(decl)
+	if !values.Has("gen") || values.Get("gen") != true || values.Get("range") != 5 {
+		t.Errorf("expected +gen and +range to be parsed, got %v", values)
+	}
+
+	// each error is positioned at its own comment, or line of a block comment
+	expected := []string{
+		"x.go:5:1: +default=x: marker may not be placed on a type (only on field)",
+		"x.go:6:1: +nope: unknown marker",
+		`x.go:7:1: +gen=maybe: expected true or false, not "maybe"`,
+		"x.go:9:1: +enum={a}: marker may not be placed on a type (only on field)",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		markerErr, isMarkerErr := err.(*Error)
+		if !isMarkerErr {
+			t.Fatalf("expected a *Error, got %T", err)
+		}
+		if !strings.HasPrefix(markerErr.Error(), expected[i]) {
+			t.Errorf("expected %q, got %q", expected[i], markerErr.Error())
+		}
+		// offsets agree with lines
+		if offsetLine := fset.Position(fset.File(file.Pos()).Pos(markerErr.Position.Offset)).Line; offsetLine != markerErr.Position.Line {
+			t.Errorf("expected the offset of %v to be on line %d, got line %d", markerErr, markerErr.Position.Line, offsetLine)
+		}
+	}
+}
+
+func TestMarkersErrorsWithoutDocPositions(t *testing.T) {
+	field := builder.Struct().
+		Field("Thing", convert.NewIdent("int"), "").WithFieldDoc("+gen", "+default=x").
+		WithFieldPosition(token.Position{Filename: "x.go", Line: 3, Column: 2}).
+		Fields()[0]
+
+	_, errs := testRegistry(t).FieldMarkers(field)
+	if len(errs) != 1 {
+		t.Fatalf("expected a single error, got %v", errs)
+	}
+	// without comment positions, errors are positioned at the node
+	if expected := "x.go:3:2: +gen: marker may not be placed on a field (only on type)"; errs[0].Error() != expected {
+		t.Errorf("expected %q, got %q", expected, errs[0].Error())
+	}
+}
+
+func TestScan(t *testing.T) {
+	cases := []struct {
+		name     string
+		doc      []string
+		expected []string
+	}{
+		{name: "no markers", doc: []string{"Thing is a thing.", "It has no markers."}, expected: nil},
+		{name: "line comments", doc: []string{"Thing is a thing.", "+gen", " +default=x "}, expected: []string{"gen", "default=x"}},
+		{name: "block comment", doc: []string{"Thing is a thing.\n+gen:deepcopy\n  +enum={a,b}\n"}, expected: []string{"gen:deepcopy", "enum={a,b}"}},
+		{name: "unknown markers", doc: []string{"+whatever:you=like"}, expected: []string{"whatever:you=like"}},
+		{name: "not markers", doc: []string{"+", "+1", "++gen", "a +gen", "+ gen"}, expected: nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := Scan(c.doc); !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package markers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Registry holds the markers known to a generator.  The zero value
+// is an empty registry.  Registering markers isn't safe to do
+// concurrently with parsing.
+type Registry struct {
+	// defs maps names to the definitions for each target,
+	// since the same name may mean different things on
+	// different targets.
+	defs map[string]map[TargetType]*Definition
+}
+
+// Register adds the given markers to the registry, returning an error
+// if one of them is already registered for the same target.
+func (r *Registry) Register(defs ...*Definition) error {
+	if r.defs == nil {
+		r.defs = make(map[string]map[TargetType]*Definition)
+	}
+	for _, def := range defs {
+		byTarget := r.defs[def.Name]
+		if byTarget == nil {
+			byTarget = make(map[TargetType]*Definition)
+			r.defs[def.Name] = byTarget
+		}
+		if _, exists := byTarget[def.Target]; exists {
+			return fmt.Errorf("marker %s already registered for %s", def.Name, def.Target)
+		}
+		byTarget[def.Target] = def
+	}
+	return nil
+}
+
+// Lookup finds the definition of the marker with the given
+// name on the given target, or nil if there isn't one.
+func (r *Registry) Lookup(name string, target TargetType) *Definition {
+	return r.defs[name][target]
+}
+
+// split finds the name of the marker in the given text (without the
+// leading `+`), returning it along with the rest of the text.  Since
+// names may contain colons, the longest registered name wins.  Unknown
+// markers are split at the first `=`.
+func (r *Registry) split(text string) (name, rest string, known bool) {
+	for candidate := range r.defs {
+		if len(candidate) <= len(name) || !strings.HasPrefix(text, candidate) {
+			continue
+		}
+		if suffix := text[len(candidate):]; suffix != "" && suffix[0] != '=' && suffix[0] != ':' {
+			continue
+		}
+		name = candidate
+		known = true
+	}
+	if !known {
+		name, _, _ = strings.Cut(text, "=")
+	}
+	return name, text[len(name):], known
+}
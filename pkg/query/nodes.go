@@ -0,0 +1,260 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+
+	"go/token"
+
+	"github.com/directxman12/envmap/pkg/convert"
+	"github.com/directxman12/envmap/pkg/markers"
+)
+
+// nodesOf returns the tree of nodes for the declarations in the given AST.
+func nodesOf(a convert.AST) []*Match {
+	var res []*Match
+	for _, decl := range a.Types() {
+		node := &Match{Kind: "type", Name: decl.Name().Name(), Node: decl}
+		node.children = membersOf(node, decl.Type())
+		res = append(res, node)
+	}
+	for _, decl := range a.Funcs() {
+		node := &Match{Kind: "func", Name: decl.Name().Name(), Node: decl}
+		if _, recvType := decl.Receiver(); recvType != nil {
+			node.Kind = "method"
+			node.receiver = convert.BaseTypeName(recvType)
+		}
+		res = append(res, node)
+	}
+	for _, decl := range a.Values() {
+		kind := "var"
+		if decl.IsConst() {
+			kind = "const"
+		}
+		res = append(res, &Match{Kind: kind, Name: nameOf(decl.Name()), Node: decl})
+	}
+	return res
+}
+
+// membersOf returns the nodes for the fields of a struct,
+// or the elements of an interface.
+func membersOf(parent *Match, def convert.TypeDefinition) []*Match {
+	var res []*Match
+	switch typed := def.(type) {
+	case convert.StructTypeDefinition:
+		for _, field := range typed.Fields() {
+			node := &Match{Kind: "field", Name: memberName(field), Node: field, Parent: parent}
+			node.children = membersOf(node, field.Type())
+			res = append(res, node)
+		}
+	case convert.InterfaceTypeDefinition:
+		for _, elem := range typed.Elements() {
+			if elem.IsEmbedded() {
+				res = append(res, &Match{Kind: "embed", Name: memberName(elem), Node: elem, Parent: parent})
+			} else {
+				res = append(res, &Match{Kind: "method", Name: nameOf(elem.Name()), Node: elem, Parent: parent})
+			}
+		}
+	}
+	return res
+}
+
+// memberName returns the name of a field or embed, which for embedded
+// unions and other unnamed types is the type as Go source.
+func memberName(field convert.Field) string {
+	if name := convert.FieldName(field); name != "" {
+		return name
+	}
+	return convert.TypeString(field.Type(), nil)
+}
+
+func nameOf(id convert.Ident) string {
+	if id == nil {
+		return ""
+	}
+	return id.Name()
+}
+
+// typeOf returns the type of the given node, or nil if it has none.
+func typeOf(m *Match) convert.TypeDefinition {
+	switch node := m.Node.(type) {
+	case convert.TypeDeclaration:
+		return node.Type()
+	case convert.FuncDeclaration:
+		return node.Type()
+	case convert.ValueDeclaration:
+		return node.Type()
+	case convert.Field:
+		return node.Type()
+	default:
+		return nil
+	}
+}
+
+// shapeOf returns the value of the kind attribute for a type.
+func shapeOf(def convert.TypeDefinition) string {
+	switch typed := def.(type) {
+	case convert.StructTypeDefinition:
+		return "struct"
+	case convert.InterfaceTypeDefinition:
+		return "interface"
+	case convert.FuncTypeDefinition:
+		return "func"
+	case convert.MapTypeDefinition:
+		return "map"
+	case convert.ChanTypeDefinition:
+		return "chan"
+	case convert.PointerTypeDefinition:
+		return "pointer"
+	case convert.SplatTypeDefinition:
+		return "slice"
+	case convert.ArrayTypeDefinition:
+		if typed.Length() == nil {
+			return "slice"
+		}
+		return "array"
+	case convert.UnionTypeDefinition:
+		return "union"
+	case convert.TildeTypeDefinition:
+		return "tilde"
+	default:
+		// instantiations and identifiers
+		return "named"
+	}
+}
+
+// attr returns the values of the given attribute of a node,
+// and whether the node has the attribute at all.
+func (m *Match) attr(name string) ([]string, bool) {
+	switch name {
+	case "name":
+		return []string{m.Name}, true
+	case "kind":
+		if typ := typeOf(m); typ != nil {
+			return []string{shapeOf(typ)}, true
+		}
+	case "type":
+		if typ := typeOf(m); typ != nil {
+			return []string{convert.TypeString(typ, nil)}, true
+		}
+	case "doc":
+		if doc := docOf(m.Node); len(doc) > 0 {
+			return []string{strings.Join(doc, "\n")}, true
+		}
+	case "marker":
+		found := markers.Scan(docOf(m.Node))
+		return found, len(found) > 0
+	case "receiver":
+		if decl, isFunc := m.Node.(convert.FuncDeclaration); isFunc {
+			if _, recvType := decl.Receiver(); recvType != nil {
+				return []string{convert.TypeString(recvType, nil)}, true
+			}
+		}
+	case "exported":
+		return flag(token.IsExported(m.Name))
+	case "embedded":
+		field, isField := m.Node.(convert.Field)
+		return flag(isField && field.IsEmbedded())
+	case "alias":
+		decl, isType := m.Node.(convert.TypeDeclaration)
+		return flag(isType && decl.IsAlias())
+	case "generic":
+		switch node := m.Node.(type) {
+		case convert.TypeDeclaration:
+			return flag(len(node.TypeParams()) > 0)
+		case convert.FuncDeclaration:
+			return flag(len(node.Type().TypeParams()) > 0)
+		}
+	default:
+		if key := strings.TrimPrefix(name, "tag."); key != name {
+			if field, isField := m.Node.(convert.Field); isField {
+				return tagValue(field.Tag(), key)
+			}
+		}
+	}
+	return nil, false
+}
+
+// flag returns the value of a boolean attribute, which
+// is only present when true.
+func flag(present bool) ([]string, bool) {
+	if !present {
+		return nil, false
+	}
+	return []string{"true"}, true
+}
+
+func tagValue(tag reflect.StructTag, key string) ([]string, bool) {
+	var val string
+	var present bool
+	if parsed, err := convert.ParseStructTag(tag); err == nil {
+		val, present = parsed.Lookup(key)
+	} else {
+		val, present = tag.Lookup(key)
+	}
+	if !present {
+		return nil, false
+	}
+	return []string{val}, true
+}
+
+func docOf(node interface{}) []string {
+	if doced, isDoced := node.(convert.Doced); isDoced {
+		return doced.Doc()
+	}
+	return nil
+}
+
+// matches checks the filter against the given node.
+func (f filter) matches(m *Match) bool {
+	values, present := m.attr(f.attr)
+	if f.op == "" {
+		return present
+	}
+	if f.op == "!=" {
+		for _, val := range values {
+			if f.equal(val) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, val := range values {
+		if f.matchValue(val) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f filter) matchValue(val string) bool {
+	switch f.op {
+	case "=":
+		return f.equal(val)
+	case "^=":
+		return strings.HasPrefix(val, f.value)
+	case "$=":
+		return strings.HasSuffix(val, f.value)
+	case "*=":
+		return strings.Contains(val, f.value)
+	case "~=":
+		return f.pattern.MatchString(val)
+	default:
+		return false
+	}
+}
+
+// equal checks if the given value is equal to the filter's value.
+// Markers are compared by name, so that `marker=name` matches
+// `+name`, `+name=value`, and `+name:arg=value`.
+func (f filter) equal(val string) bool {
+	if f.attr != "marker" {
+		return val == f.value
+	}
+	name := strings.TrimPrefix(f.value, "+")
+	if val == name {
+		return true
+	}
+	rest := strings.TrimPrefix(val, name)
+	return rest != val && (rest[0] == '=' || rest[0] == ':')
+}
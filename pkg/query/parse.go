@@ -0,0 +1,220 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// filter checks a single attribute of a node.
+type filter struct {
+	attr string
+	// op is empty for filters that just check
+	// that the attribute is present
+	op string
+	value string
+	pattern *regexp.Regexp
+}
+
+// parser parses selectors.
+type parser struct {
+	text string
+	pos int
+}
+
+func parseSelector(text string) ([]step, error) {
+	p := &parser{text: text}
+	var steps []step
+	p.skipSpace()
+	if p.done() {
+		return nil, p.errorf("empty selector")
+	}
+	for !p.done() {
+		comb := descendant
+		if len(steps) > 0 {
+			hadSpace := p.skipSpace()
+			if p.peek() == '>' {
+				p.pos++
+				p.skipSpace()
+				comb = child
+			} else if !hadSpace {
+				return nil, p.errorf("expected `>` or whitespace between steps, not %q", p.peek())
+			}
+			if p.done() {
+				return nil, p.errorf("missing step after combinator")
+			}
+		}
+
+		s, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		s.combinator = comb
+		steps = append(steps, s)
+
+		// allow trailing whitespace
+		start := p.pos
+		p.skipSpace()
+		if !p.done() {
+			p.pos = start
+		}
+	}
+	return steps, nil
+}
+
+// parseStep parses a kind and its filters.
+func (p *parser) parseStep() (step, error) {
+	start := p.pos
+	var res step
+	if p.peek() == '*' {
+		p.pos++
+		res.kind = "*"
+	} else {
+		res.kind = p.word()
+		if res.kind == "" {
+			if p.peek() != '[' {
+				return res, p.errorf("expected a kind of node, not %q", p.peek())
+			}
+			// filters alone match any kind
+			res.kind = "*"
+		}
+	}
+	if !kinds[res.kind] {
+		p.pos = start
+		return res, p.errorf("unknown kind of node %q", res.kind)
+	}
+
+	for p.peek() == '[' {
+		f, err := p.parseFilter()
+		if err != nil {
+			return res, err
+		}
+		res.filters = append(res.filters, f)
+	}
+	return res, nil
+}
+
+// parseFilter parses a single bracketed filter.
+func (p *parser) parseFilter() (filter, error) {
+	var res filter
+	p.pos++ // [
+	p.skipSpace()
+	attrStart := p.pos
+	res.attr = p.word()
+	if !isValidAttribute(res.attr) {
+		p.pos = attrStart
+		if res.attr == "" {
+			return res, p.errorf("expected an attribute name")
+		}
+		return res, p.errorf("unknown attribute %q", res.attr)
+	}
+	p.skipSpace()
+
+	if p.peek() == ']' {
+		p.pos++
+		return res, nil
+	}
+
+	for _, op := range []string{"!=", "^=", "$=", "*=", "~=", "="} {
+		if strings.HasPrefix(p.text[p.pos:], op) {
+			res.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if res.op == "" {
+		return res, p.errorf("expected an operator or `]` after attribute %q", res.attr)
+	}
+	p.skipSpace()
+
+	valueStart := p.pos
+	value, err := p.value()
+	if err != nil {
+		return res, err
+	}
+	res.value = value
+	if res.op == "~=" {
+		res.pattern, err = regexp.Compile(value)
+		if err != nil {
+			p.pos = valueStart
+			return res, p.errorf("bad regular expression: %v", err)
+		}
+	}
+
+	p.skipSpace()
+	if p.peek() != ']' {
+		return res, p.errorf("expected `]` to close filter on %q", res.attr)
+	}
+	p.pos++
+	return res, nil
+}
+
+// value parses a quoted or bare value, which runs until the closing `]`.
+func (p *parser) value() (string, error) {
+	start := p.pos
+	if quote := p.peek(); quote == '"' || quote == '`' {
+		for p.pos++; !p.done(); p.pos++ {
+			switch p.text[p.pos] {
+			case '\\':
+				if quote == '"' {
+					p.pos++
+				}
+			case quote:
+				p.pos++
+				value, err := strconv.Unquote(p.text[start:p.pos])
+				if err != nil {
+					p.pos = start
+					return "", p.errorf("bad quoted value: %v", err)
+				}
+				return value, nil
+			}
+		}
+		p.pos = start
+		return "", p.errorf("unterminated quoted value")
+	}
+
+	end := strings.IndexByte(p.text[p.pos:], ']')
+	if end < 0 {
+		return "", p.errorf("unterminated filter")
+	}
+	p.pos += end
+	return strings.TrimSpace(p.text[start:p.pos]), nil
+}
+
+// word parses a kind or attribute name.
+func (p *parser) word() string {
+	start := p.pos
+	for !p.done() {
+		c := p.text[p.pos]
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' && c != '.' && c != '-' {
+			break
+		}
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+// skipSpace skips whitespace, returning true if there was any.
+func (p *parser) skipSpace() bool {
+	start := p.pos
+	for !p.done() && strings.IndexByte(" \t\n\r", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *parser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.text[p.pos]
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.text)
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Selector: p.text, Offset: p.pos, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,121 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describeSteps formats parsed steps canonically, like
+// `type[kind=struct] > field[tag.json]`.
+func describeSteps(steps []step) string {
+	var res strings.Builder
+	for i, s := range steps {
+		if i > 0 {
+			if s.combinator == child {
+				res.WriteString(" > ")
+			} else {
+				res.WriteString(" ")
+			}
+		}
+		res.WriteString(s.kind)
+		for _, f := range s.filters {
+			if f.op == "" {
+				fmt.Fprintf(&res, "[%s]", f.attr)
+				continue
+			}
+			fmt.Fprintf(&res, "[%s%s%q]", f.attr, f.op, f.value)
+		}
+	}
+	return res.String()
+}
+
+func TestParseSelector(t *testing.T) {
+	cases := []struct {
+		selector string
+		expected string
+	}{
+		{selector: "type", expected: "type"},
+		{selector: "  type  ", expected: "type"},
+		{selector: "*", expected: "*"},
+		{selector: "[exported]", expected: "*[exported]"},
+
+		// combinators
+		{selector: "type field", expected: "type field"},
+		{selector: "type \t\n field", expected: "type field"},
+		{selector: "type > field", expected: "type > field"},
+		{selector: "type>field", expected: "type > field"},
+		{selector: "type>field method", expected: "type > field method"},
+		{selector: "type[exported] > field > field", expected: "type[exported] > field > field"},
+
+		// operators
+		{selector: "type[name=Foo]", expected: `type[name="Foo"]`},
+		{selector: "type[name!=Foo]", expected: `type[name!="Foo"]`},
+		{selector: "type[name^=Foo]", expected: `type[name^="Foo"]`},
+		{selector: "type[name$=Foo]", expected: `type[name$="Foo"]`},
+		{selector: "type[name*=Foo]", expected: `type[name*="Foo"]`},
+		{selector: "type[name~=^Foo.*$]", expected: `type[name~="^Foo.*$"]`},
+		{selector: "type[ name = Foo ]", expected: `type[name="Foo"]`},
+		{selector: "type[kind=struct][marker=+gen:deepcopy]", expected: `type[kind="struct"][marker="+gen:deepcopy"]`},
+		{selector: "field[tag.json=name,omitempty]", expected: `field[tag.json="name,omitempty"]`},
+
+		// quoted values
+		{selector: `field[tag.json="a]b"]`, expected: `field[tag.json="a]b"]`},
+		{selector: "field[tag.json=`a]b`]", expected: `field[tag.json="a]b"]`},
+		{selector: `field[doc*="say \"hi\" ]"]`, expected: `field[doc*="say \"hi\" ]"]`},
+		{selector: `type[name~="[A-Z]+"]`, expected: `type[name~="[A-Z]+"]`},
+	}
+	for _, c := range cases {
+		t.Run(c.selector, func(t *testing.T) {
+			steps, err := parseSelector(c.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := describeSteps(steps); actual != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	cases := []struct {
+		selector string
+		offset   int
+		message  string
+	}{
+		{selector: "", offset: 0, message: "empty selector"},
+		{selector: "   ", offset: 3, message: "empty selector"},
+		{selector: "struct", offset: 0, message: `unknown kind of node "struct"`},
+		{selector: "type > struct", offset: 7, message: `unknown kind of node "struct"`},
+		{selector: "type >", offset: 6, message: "missing step after combinator"},
+		{selector: "type >   ", offset: 9, message: "missing step after combinator"},
+		{selector: "type,field", offset: 4, message: "expected `>` or whitespace"},
+		{selector: "type > ,", offset: 7, message: "expected a kind of node"},
+		{selector: "type[]", offset: 5, message: "expected an attribute name"},
+		{selector: "type[size=3]", offset: 5, message: `unknown attribute "size"`},
+		{selector: "type[tag.]", offset: 5, message: `unknown attribute "tag."`},
+		{selector: "type[name=x]y", offset: 12, message: "expected `>` or whitespace"},
+		{selector: "type[name<x]", offset: 9, message: "expected an operator"},
+		{selector: "type[name=x", offset: 10, message: "unterminated filter"},
+		{selector: `type[name="x]`, offset: 10, message: "unterminated quoted value"},
+		{selector: `type[name="x" y]`, offset: 14, message: "expected `]` to close filter"},
+		{selector: `type[name="\q"]`, offset: 10, message: "bad quoted value"},
+		{selector: "type[name~=(]", offset: 11, message: "bad regular expression"},
+	}
+	for _, c := range cases {
+		t.Run(c.selector, func(t *testing.T) {
+			_, err := Compile(c.selector)
+			syntaxErr, isSyntaxErr := err.(*SyntaxError)
+			if !isSyntaxErr {
+				t.Fatalf("expected a *SyntaxError, got %v", err)
+			}
+			if syntaxErr.Offset != c.offset || !strings.Contains(syntaxErr.Message, c.message) {
+				t.Errorf("expected %q at offset %d, got %q at offset %d", c.message, c.offset, syntaxErr.Message, syntaxErr.Offset)
+			}
+			if syntaxErr.Selector != c.selector {
+				t.Errorf("expected the error to report selector %q, got %q", c.selector, syntaxErr.Selector)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// Package query finds declarations in the forms from "pkg/convert" using
+// CSS-like selectors, like
+//
+//	type[kind=struct][marker=+gen:deepcopy] > field[tag.json]
+//
+// which selects the fields with a json tag of struct types marked with
+// `+gen:deepcopy`.
+//
+// A selector is a list of steps, separated by `>` (the next step matches
+// direct children) or whitespace (the next step matches any descendant).
+// Each step is a kind of node, optionally followed by filters in brackets.
+// The kinds of nodes are:
+//
+// - type: type declarations
+// - func: function declarations (without receivers)
+// - method: method declarations, and methods of interface types
+// - field: struct fields
+// - embed: embedded interfaces and constraint elements of interface types
+// - const and var: value declarations
+// - *: any of the above
+//
+// Struct fields and interface elements are children of the type (or field)
+// whose type is the struct or interface.  Filters are either `[attr]`, which
+// checks that the attribute is present, or `[attr op value]`, where the
+// value may be bare or a quoted Go string, and op is one of `=`, `!=`, `^=`
+// (prefix), `$=` (suffix), `*=` (substring), or `~=` (regular expression).
+// The attributes are:
+//
+// - name: the name of the node (the type name, for embedded fields)
+// - kind: the shape of the node's type: struct, interface, func, map,
+//   slice, array, chan, pointer, union, tilde, or named
+// - type: the node's type, as Go source (see convert.TypeString)
+// - doc: the docs of the node
+// - marker: each marker comment in the docs, without the leading `+`.
+//   `[marker=name]` also matches markers with arguments, like
+//   `+name=value` or `+name:arg=value`.
+// - tag.KEY: the value of the given key in a field's struct tag
+// - receiver: the receiver type of a method declaration
+// - exported, embedded, alias, and generic: present if the node is exported,
+//   an embedded field, a type alias, or has type parameters.
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go/token"
+
+	"github.com/directxman12/envmap/pkg/convert"
+)
+
+// Selector is a compiled selector.
+type Selector struct {
+	text string
+	steps []step
+}
+
+// combinator relates a step to the previous one.
+type combinator int
+
+const (
+	// descendant steps match anywhere below the previous step
+	descendant combinator = iota
+	// child steps match directly below the previous step
+	child
+)
+
+// step is a single kind of node, along with its filters.
+type step struct {
+	combinator combinator
+	kind string
+	filters []filter
+}
+
+// Compile parses a selector.
+func Compile(selector string) (*Selector, error) {
+	steps, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return &Selector{text: selector, steps: steps}, nil
+}
+
+// MustCompile is like Compile, but panics if the selector is invalid,
+// for use in package-level variables.
+func MustCompile(selector string) *Selector {
+	sel, err := Compile(selector)
+	if err != nil {
+		panic(err)
+	}
+	return sel
+}
+
+func (s *Selector) String() string { return s.text }
+
+// Select returns the nodes of the given AST which match the selector,
+// in the order they're declared (types, then funcs, then values).
+func (s *Selector) Select(a convert.AST) []*Match {
+	var res []*Match
+	for _, node := range nodesOf(a) {
+		node.each(func(m *Match) {
+			if s.matches(m, len(s.steps)-1) {
+				res = append(res, m)
+			}
+		})
+	}
+	return res
+}
+
+// matches checks if the given node matches the steps up to and including
+// the given one.
+func (s *Selector) matches(m *Match, stepInd int) bool {
+	current := s.steps[stepInd]
+	if !current.matches(m) {
+		return false
+	}
+	if stepInd == 0 {
+		return true
+	}
+	if current.combinator == child {
+		return m.Parent != nil && s.matches(m.Parent, stepInd-1)
+	}
+	for ancestor := m.Parent; ancestor != nil; ancestor = ancestor.Parent {
+		if s.matches(ancestor, stepInd-1) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s step) matches(m *Match) bool {
+	if s.kind != "*" && s.kind != m.Kind {
+		return false
+	}
+	for _, f := range s.filters {
+		if !f.matches(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// Match is a node matched by a selector.
+type Match struct {
+	// Kind is the kind of node, like "type" or "field".
+	Kind string
+	// Name is the name of the node.  Embedded fields are named
+	// by their type name, and embeds by their type.
+	Name string
+	// Node is the matched node: a convert.TypeDeclaration,
+	// convert.FuncDeclaration, convert.ValueDeclaration,
+	// or convert.Field.
+	Node interface{}
+	// Parent is the enclosing node (e.g. the type of a
+	// field), or nil for declarations.
+	Parent *Match
+
+	// receiver is the name of the receiver type of method declarations
+	receiver string
+	children []*Match
+}
+
+// Position returns the position of the matched node, which is invalid
+// if the node isn't convert.Positioned.
+func (m *Match) Position() token.Position {
+	if positioned, isPositioned := m.Node.(convert.Positioned); isPositioned {
+		return positioned.Position()
+	}
+	return token.Position{}
+}
+
+// Path returns the names of the node and its ancestors, joined by
+// dots, like `Config.Spec.Replicas`.  Method declarations are
+// prefixed with the name of their receiver type.
+func (m *Match) Path() string {
+	if m.Parent == nil {
+		if m.receiver != "" {
+			return m.receiver + "." + m.Name
+		}
+		return m.Name
+	}
+	return m.Parent.Path() + "." + m.Name
+}
+
+// each calls fn on this node and its descendants, depth-first.
+func (m *Match) each(fn func(*Match)) {
+	fn(m)
+	for _, child := range m.children {
+		child.each(fn)
+	}
+}
+
+// SortByPosition sorts matches by their file and position
+// within the file, like when printing them.
+func SortByPosition(matches []*Match) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		posI, posJ := matches[i].Position(), matches[j].Position()
+		if posI.Filename != posJ.Filename {
+			return posI.Filename < posJ.Filename
+		}
+		return posI.Offset < posJ.Offset
+	})
+}
+
+// SyntaxError is an invalid selector.
+type SyntaxError struct {
+	Selector string
+	// Offset is the byte offset of the error in the selector.
+	Offset int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid selector %q at offset %d: %s", e.Selector, e.Offset, e.Message)
+}
+
+// kinds are the valid kinds of nodes.
+var kinds = map[string]bool{
+	"*": true,
+	"type": true,
+	"func": true,
+	"method": true,
+	"field": true,
+	"embed": true,
+	"const": true,
+	"var": true,
+}
+
+// attributes are the valid attributes, besides `tag.KEY`.
+var attributes = map[string]bool{
+	"name": true,
+	"kind": true,
+	"type": true,
+	"doc": true,
+	"marker": true,
+	"receiver": true,
+	"exported": true,
+	"embedded": true,
+	"alias": true,
+	"generic": true,
+}
+
+func isValidAttribute(name string) bool {
+	if key := strings.TrimPrefix(name, "tag."); key != name {
+		return key != ""
+	}
+	return attributes[name]
+}
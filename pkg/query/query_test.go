@@ -0,0 +1,145 @@
+package query_test
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/directxman12/envmap/pkg/convert"
+	"github.com/directxman12/envmap/pkg/query"
+)
+
+const selectSrc = `package x
+
+// Config configures things.
+// +gen:deepcopy
+// +kubebuilder:object:root=true
+type Config struct {
+	// Name is the name.
+	Name string ` + "`json:\"name\"`" + `
+	Spec Spec ` + "`json:\"spec,omitempty\"`" + `
+	internal int
+	Inline struct {
+		Replicas *int32 ` + "`json:\"replicas\"`" + `
+	} ` + "`json:\",inline\"`" + `
+}
+
+// Spec is the spec.
+// +gen:deepcopy=false
+type Spec struct {
+	Items []string ` + "`json:\"items\"`" + `
+}
+
+// +gen:deepcopyish
+type Other map[string]int
+
+type Getter interface {
+	fmt.Stringer
+	Get() string
+}
+
+func (c *Config) Validate() error { return nil }
+
+func NewConfig() *Config { return nil }
+
+const Version = "v1"
+
+var defaultConfig Config
+`
+
+func TestSelect(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", selectSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := convert.FromRawWithFileSet(fset, file)
+
+	cases := []struct {
+		selector string
+		expected []string
+	}{
+		{selector: "type", expected: []string{"Config", "Spec", "Other", "Getter"}},
+		{selector: "type[kind=struct]", expected: []string{"Config", "Spec"}},
+		{selector: "type[kind!=struct]", expected: []string{"Other", "Getter"}},
+
+		// markers match by name, with or without arguments
+		{selector: "type[marker=gen:deepcopy]", expected: []string{"Config", "Spec"}},
+		{selector: "type[marker=+gen:deepcopy]", expected: []string{"Config", "Spec"}},
+		{selector: "type[marker=kubebuilder:object:root]", expected: []string{"Config"}},
+		// `+gen:deepcopy` is a `+gen` marker with an argument
+		{selector: "type[marker=gen]", expected: []string{"Config", "Spec", "Other"}},
+		{selector: "type[marker=gen:deep]", expected: nil},
+		{selector: "type[marker=gen:deepcopy=false]", expected: []string{"Spec"}},
+		{selector: "type[marker^=gen:]", expected: []string{"Config", "Spec", "Other"}},
+		{selector: "type[marker]", expected: []string{"Config", "Spec", "Other"}},
+
+		// struct tags
+		{selector: "field[tag.json]", expected: []string{"Config.Name", "Config.Spec", "Config.Inline", "Config.Inline.Replicas", "Spec.Items"}},
+		{selector: "field[tag.json=name]", expected: []string{"Config.Name"}},
+		{selector: "field[tag.json^=spec]", expected: []string{"Config.Spec"}},
+		{selector: `field[tag.json=",inline"]`, expected: []string{"Config.Inline"}},
+		{selector: "field[tag.json~=omitempty$]", expected: []string{"Config.Spec"}},
+
+		// combinators
+		{selector: "type > field", expected: []string{"Config.Name", "Config.Spec", "Config.internal", "Config.Inline", "Spec.Items"}},
+		{selector: "type field", expected: []string{"Config.Name", "Config.Spec", "Config.internal", "Config.Inline", "Config.Inline.Replicas", "Spec.Items"}},
+		{selector: "type[name=Config] > field > field", expected: []string{"Config.Inline.Replicas"}},
+		{selector: "field > field[kind=pointer]", expected: []string{"Config.Inline.Replicas"}},
+
+		// other attributes and kinds
+		{selector: "field[exported][type=*int32]", expected: []string{"Config.Inline.Replicas"}},
+		{selector: "field[exported][type*=int]", expected: []string{"Config.Inline", "Config.Inline.Replicas"}},
+		{selector: "field[doc*=name]", expected: []string{"Config.Name"}},
+		{selector: "type[name=Getter] > *", expected: []string{"Getter.Stringer", "Getter.Get"}},
+		{selector: "method", expected: []string{"Getter.Get", "Config.Validate"}},
+		{selector: "method[receiver=*Config]", expected: []string{"Config.Validate"}},
+		{selector: "func", expected: []string{"NewConfig"}},
+		{selector: "const", expected: []string{"Version"}},
+		{selector: "var[exported]", expected: nil},
+		{selector: "var[type=Config]", expected: []string{"defaultConfig"}},
+	}
+	for _, c := range cases {
+		t.Run(c.selector, func(t *testing.T) {
+			var actual []string
+			for _, match := range query.MustCompile(c.selector).Select(a) {
+				actual = append(actual, match.Path())
+			}
+			if !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMatchPosition(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", selectSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := query.MustCompile("*[name~=Config]").Select(convert.FromRawWithFileSet(fset, file))
+	// values are selected after funcs, but declared before them
+	query.SortByPosition(matches)
+
+	// lineOf returns the line of the given declaration in selectSrc
+	lineOf := func(decl string) int {
+		return strings.Count(selectSrc[:strings.Index(selectSrc, decl)], "\n") + 1
+	}
+	expected := []string{
+		fmt.Sprintf("x.go:%d: type Config", lineOf("type Config")),
+		fmt.Sprintf("x.go:%d: func NewConfig", lineOf("func NewConfig")),
+		fmt.Sprintf("x.go:%d: var defaultConfig", lineOf("var defaultConfig")),
+	}
+	var actual []string
+	for _, match := range matches {
+		pos := match.Position()
+		actual = append(actual, fmt.Sprintf("%s:%d: %s %s", pos.Filename, pos.Line, match.Kind, match.Path()))
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}